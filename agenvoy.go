@@ -0,0 +1,99 @@
+// Package agenvoy embeds the agenvoy agent loop into other Go programs.
+//
+// An Engine holds a registry of agents, the skills and custom tools to attach,
+// and the directories / secrets the CLI would otherwise read from cwd, the home
+// directory and the OS keychain.
+package agenvoy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/claude"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/compat"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/copilot"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/gemini"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/mock"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/nvidia"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/openai"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+type (
+	Agent          = agentTypes.Agent
+	AgentEntry     = agentTypes.AgentEntry
+	Embedder       = agentTypes.Embedder
+	Message        = agentTypes.Message
+	Output         = agentTypes.Output
+	Usage          = agentTypes.Usage
+	ModelUsage     = agentTypes.ModelUsage
+	Event          = agentTypes.Event
+	EventType      = agentTypes.EventType
+	ProviderConfig = agentTypes.ProviderConfig
+	Skill          = skill.Skill
+	Tool           = toolTypes.Tool
+	ToolDef        = toolTypes.ToolDef
+	ToolFunction   = toolTypes.ToolFunction
+	ToolFlag       = toolTypes.ToolFlag
+	Executor       = toolTypes.Executor
+	Handler        = toolTypes.Handler
+)
+
+const (
+	EventText          = agentTypes.EventText
+	EventTextDelta     = agentTypes.EventTextDelta
+	EventAgentSelect   = agentTypes.EventAgentSelect
+	EventAgentResult   = agentTypes.EventAgentResult
+	EventSkillSelect   = agentTypes.EventSkillSelect
+	EventSkillResult   = agentTypes.EventSkillResult
+	EventToolCall      = agentTypes.EventToolCall
+	EventToolCallStart = agentTypes.EventToolCallStart
+	EventToolCallText  = agentTypes.EventToolCallText
+	EventToolCallEnd   = agentTypes.EventToolCallEnd
+	EventToolResult    = agentTypes.EventToolResult
+	EventToolSkipped   = agentTypes.EventToolSkipped
+	EventToolConfirm   = agentTypes.EventToolConfirm
+	EventCompact       = agentTypes.EventCompact
+	EventRetry         = agentTypes.EventRetry
+	EventFailover      = agentTypes.EventFailover
+	EventError         = agentTypes.EventError
+	EventDone          = agentTypes.EventDone
+)
+
+const (
+	FlagReadOnly = toolTypes.FlagReadOnly
+	FlagNetwork  = toolTypes.FlagNetwork
+	FlagConfirm  = toolTypes.FlagConfirm
+)
+
+var providers = map[string]func(string, ProviderConfig) (Agent, error){
+	"copilot": func(m string, c ProviderConfig) (Agent, error) { return copilot.NewWithConfig(m, c) },
+	"openai":  func(m string, c ProviderConfig) (Agent, error) { return openai.NewWithConfig(m, c) },
+	"compat":  func(m string, c ProviderConfig) (Agent, error) { return compat.NewWithConfig(m, c) },
+	"claude":  func(m string, c ProviderConfig) (Agent, error) { return claude.NewWithConfig(m, c) },
+	"gemini":  func(m string, c ProviderConfig) (Agent, error) { return gemini.NewWithConfig(m, c) },
+	"nvidia":  func(m string, c ProviderConfig) (Agent, error) { return nvidia.NewWithConfig(m, c) },
+	"mock":    func(m string, c ProviderConfig) (Agent, error) { return mock.NewWithConfig(m, c) },
+}
+
+// NewTool builds a Tool from a schema and a handler.
+func NewTool(def ToolDef, flags ToolFlag, handler Handler) Tool {
+	return toolTypes.NewTool(def, flags, handler)
+}
+
+// NewAgent creates a provider agent from a model entry name such as
+// "claude@claude-sonnet-4-5" or "compat[ollama]@qwen3:8b".
+func NewAgent(name string, cfg ProviderConfig) (Agent, error) {
+	provider := strings.SplitN(name, "@", 2)[0]
+	if idx := strings.Index(provider, "["); idx != -1 {
+		provider = provider[:idx]
+	}
+
+	fn, ok := providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", provider)
+	}
+	return fn(name, cfg)
+}
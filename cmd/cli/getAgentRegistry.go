@@ -3,29 +3,15 @@ package main
 import (
 	"log/slog"
 	"os"
-	"strings"
 
+	"github.com/pardnchiu/agenvoy"
 	"github.com/pardnchiu/agenvoy/internal/agents/exec"
-	"github.com/pardnchiu/agenvoy/internal/agents/provider/claude"
-	"github.com/pardnchiu/agenvoy/internal/agents/provider/compat"
-	"github.com/pardnchiu/agenvoy/internal/agents/provider/copilot"
-	"github.com/pardnchiu/agenvoy/internal/agents/provider/gemini"
-	"github.com/pardnchiu/agenvoy/internal/agents/provider/nvidia"
-	"github.com/pardnchiu/agenvoy/internal/agents/provider/openai"
+	"github.com/pardnchiu/agenvoy/internal/agents/router"
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 )
 
-func getAgentRegistry() agentTypes.AgentRegistry {
-	newFn := map[string]func(string) (agentTypes.Agent, error){
-		"copilot": func(m string) (agentTypes.Agent, error) { return copilot.New(m) },
-		"openai":  func(m string) (agentTypes.Agent, error) { return openai.New(m) },
-		"compat":  func(m string) (agentTypes.Agent, error) { return compat.New(m) },
-		"claude":  func(m string) (agentTypes.Agent, error) { return claude.New(m) },
-		"gemini":  func(m string) (agentTypes.Agent, error) { return gemini.New(m) },
-		"nvidia":  func(m string) (agentTypes.Agent, error) { return nvidia.New(m) },
-	}
-
-	agentEntries := exec.GetAgentEntries()
+func getAgentRegistry(cfg *exec.Config) agentTypes.AgentRegistry {
+	agentEntries := exec.GetAgentEntries(cfg.ConfigDir)
 	// var fallback exec.Agent
 	// registry := make(map[string]exec.Agent, len(agentEntries))
 	// entries := make([]exec.AgentEntryData, 0, len(agentEntries))
@@ -33,18 +19,14 @@ func getAgentRegistry() agentTypes.AgentRegistry {
 	agentRegistry := agentTypes.AgentRegistry{
 		Registry: make(map[string]agentTypes.Agent, len(agentEntries)),
 		Entries:  make([]agentTypes.AgentEntry, 0, len(agentEntries)),
+		Failover: exec.GetFailover(cfg.ConfigDir),
 	}
 	for _, e := range agentEntries {
-		providerFull := strings.SplitN(e.Name, "@", 2)[0]
-		provider := providerFull
-		if idx := strings.Index(providerFull, "["); idx != -1 {
-			provider = providerFull[:idx]
-		}
-		fn, ok := newFn[provider]
-		if !ok {
-			continue
-		}
-		a, err := fn(e.Name)
+		a, err := agenvoy.NewAgent(e.Name, agenvoy.ProviderConfig{
+			WorkDir:        cfg.WorkDir,
+			ContextWindow:  e.ContextWindow,
+			ThinkingBudget: e.ThinkingBudget,
+		})
 		if err != nil {
 			slog.Warn("failed to initialize agent", slog.String("name", e.Name), slog.String("error", err.Error()))
 			continue
@@ -63,3 +45,25 @@ func getAgentRegistry() agentTypes.AgentRegistry {
 
 	return agentRegistry
 }
+
+// * nil without "embedding" in config.json, or when its provider has no embeddings endpoint
+func getEmbedding(cfg *exec.Config) *router.Embedding {
+	embeddingCfg := exec.GetEmbedding(cfg.ConfigDir)
+	if embeddingCfg == nil {
+		return nil
+	}
+
+	a, err := agenvoy.NewAgent(embeddingCfg.Model, agenvoy.ProviderConfig{
+		WorkDir: cfg.WorkDir,
+	})
+	if err != nil {
+		slog.Warn("failed to initialize embedding", slog.String("name", embeddingCfg.Model), slog.String("error", err.Error()))
+		return nil
+	}
+	embedder, ok := a.(agentTypes.Embedder)
+	if !ok {
+		slog.Warn("failed to initialize embedding", slog.String("name", embeddingCfg.Model), slog.String("error", "provider has no embeddings"))
+		return nil
+	}
+	return router.NewEmbedding(embedder, *embeddingCfg, cfg.ConfigDir.Home)
+}
@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"slices"
 	"sort"
+	"strings"
 
 	"github.com/pardnchiu/agenvoy/internal/agents/exec"
 	"github.com/pardnchiu/agenvoy/internal/agents/provider/copilot"
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/replay"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
 	"github.com/pardnchiu/agenvoy/internal/skill"
+	"github.com/pardnchiu/agenvoy/internal/tools"
+	"github.com/pardnchiu/agenvoy/internal/utils"
 )
 
 func main() {
@@ -19,7 +23,12 @@ func main() {
 		fmt.Println("Usage:")
 		fmt.Println("  go run cmd/cli/main.go add")
 		fmt.Println("  go run cmd/cli/main.go list")
-		fmt.Println("  go run cmd/cli/main.go run <skill_name> <input> [--allow]")
+		fmt.Println("  go run cmd/cli/main.go run [skill_name] <input> [--allow] [--skill <name>] [--agent <name>] [--session <name>] [--record <file>]")
+		fmt.Println("  go run cmd/cli/main.go session [list|create|switch|rename|delete]")
+		fmt.Println("  go run cmd/cli/main.go usage [name] [--all] [--since YYYY-MM-DD]")
+		fmt.Println("  go run cmd/cli/main.go mcp [trust]")
+		fmt.Println("  go run cmd/cli/main.go serve [--addr 127.0.0.1:8080] [--allow]")
+		fmt.Println("  go run cmd/cli/main.go discord")
 		os.Exit(1)
 	}
 
@@ -28,24 +37,56 @@ func main() {
 		return
 	}
 
+	if os.Args[1] == "session" {
+		runSession(os.Args[2:])
+		return
+	}
+
+	if os.Args[1] == "usage" {
+		runUsage(os.Args[2:])
+		return
+	}
+
+	if os.Args[1] == "mcp" {
+		if len(os.Args) > 2 && os.Args[2] == "trust" {
+			runMCPTrust()
+			return
+		}
+		runMCP()
+		return
+	}
+
+	if os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+
+	if os.Args[1] == "discord" {
+		runDiscord()
+		return
+	}
+
 	if os.Args[1] == "list" {
-		scanner := skill.NewScanner()
+		skills := skill.NewScanner().Snapshot()
 
-		if len(scanner.Skills.ByName) == 0 {
+		if len(skills.ByName) == 0 {
 			fmt.Println("No skills found")
 			fmt.Println("\nScanned paths:")
-			for _, path := range scanner.Skills.Paths {
+			for _, path := range skills.Paths {
 				fmt.Printf("  - %s\n", path)
 			}
 			return
 		}
 
-		names := scanner.List()
+		names := make([]string, 0, len(skills.ByName))
+		for name := range skills.ByName {
+			names = append(names, name)
+		}
 		sort.Strings(names)
 
 		fmt.Printf("Found %d skill(s):\n\n", len(names))
 		for _, name := range names {
-			s := scanner.Skills.ByName[name]
+			s := skills.ByName[name]
 			fmt.Printf("• %s\n", name)
 			if s.Description != "" {
 				fmt.Printf("  %s\n", s.Description)
@@ -56,18 +97,74 @@ func main() {
 	}
 
 	if os.Args[1] == "run" {
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: go run cmd/cli/main.go run <input> [--allow]")
-			fmt.Println("       go run cmd/cli/main.go run <skill_name> <input> [--allow]")
+		defer tools.CloseMCP()
+		defer sessions.Close()
+
+		usage := func() {
+			fmt.Println("Usage: go run cmd/cli/main.go run <input> [--allow] [--skill <name>] [--agent <name>] [--session <name>] [--record <file>]")
+			fmt.Println("       go run cmd/cli/main.go run <skill_name> <input> [--allow] [--agent <name>] [--session <name>] [--record <file>]")
+			os.Exit(1)
+		}
+
+		cfg, err := exec.NewConfig("")
+		if err != nil {
+			slog.Error("failed to initialize", slog.String("error", err.Error()))
 			os.Exit(1)
 		}
+		var record string
+		var args []string
+		for i := 2; i < len(os.Args); i++ {
+			value := func() string {
+				i++
+				if i >= len(os.Args) {
+					usage()
+				}
+				return os.Args[i]
+			}
+			switch os.Args[i] {
+			case "--allow":
+				cfg.AllowAll = true
+			case "--session":
+				cfg.SessionID = value()
+			case "--record":
+				record = value()
+			case "--skill":
+				cfg.Skill = value()
+			case "--agent":
+				cfg.Agent = value()
+			default:
+				args = append(args, os.Args[i])
+			}
+		}
+		if len(args) == 0 {
+			usage()
+		}
 
-		allowAll := slices.Contains(os.Args[3:], "--allow")
+		// * every http call of the run, selector and token refresh included, goes into the cassette
+		saveRecord := func() {}
+		if record != "" {
+			recorder := replay.NewRecorder(nil)
+			utils.SetTransport(recorder)
+			saveRecord = func() {
+				if err := recorder.Save(record); err != nil {
+					slog.Warn("failed to save record", slog.String("error", err.Error()))
+				}
+			}
+		}
 
-		agentRegistry := getAgentRegistry()
+		agentRegistry := getAgentRegistry(cfg)
+		cfg.Embedding = getEmbedding(cfg)
 		scanner := skill.NewScanner()
 
-		userInput := os.Args[2]
+		// * run <skill_name> <input>, a first word that is not a skill is part of the input
+		userInput := args[0]
+		if len(args) > 1 {
+			if scanner.Get(args[0]) != nil && cfg.Skill == "" {
+				cfg.Skill = args[0]
+				args = args[1:]
+			}
+			userInput = strings.Join(args, " ")
+		}
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
@@ -77,9 +174,11 @@ func main() {
 			os.Exit(1)
 		}
 
-		if err := runEvents(ctx, cancel, func(ch chan<- agentTypes.Event) error {
-			return exec.Run(ctx, selectorBot, agentRegistry, scanner, userInput, ch, allowAll)
-		}); err != nil && ctx.Err() == nil {
+		err = runEvents(ctx, cancel, func(ch chan<- agentTypes.Event) error {
+			return exec.Run(ctx, cfg, selectorBot, agentRegistry, scanner, userInput, ch)
+		})
+		saveRecord()
+		if err != nil && ctx.Err() == nil {
 			slog.Error("failed to execute", slog.String("error", err.Error()))
 			os.Exit(1)
 		}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/copilot"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/discord"
+	"github.com/pardnchiu/agenvoy/internal/keychain"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	"github.com/pardnchiu/agenvoy/internal/tools"
+)
+
+// * DISCORD_TOKEN is read from the keychain, then the environment;
+// * who is answered and who confirms tools comes from "discord" in the home config.json
+func runDiscord() {
+	defer tools.CloseMCP()
+	defer sessions.Close()
+
+	token := keychain.Get("DISCORD_TOKEN")
+	if token == "" {
+		slog.Error("DISCORD_TOKEN is required")
+		os.Exit(1)
+	}
+
+	cfg, err := exec.NewConfig("")
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	selectorBot, err := copilot.New()
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	agentRegistry := getAgentRegistry(cfg)
+	cfg.Embedding = getEmbedding(cfg)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scanner := skill.NewScanner()
+	go watchSkills(ctx, scanner)
+
+	session, err := discord.NewSession(token)
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	bot := discord.New(session, func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error {
+		runCfg := *cfg
+		runCfg.SessionID = sessionID
+		return exec.Run(ctx, &runCfg, selectorBot, agentRegistry, scanner, input, events)
+	})
+	bot.Access = discord.LoadAccess(cfg.ConfigDir.Home)
+	if len(bot.Access.Users) == 0 && len(bot.Access.Guilds) == 0 && bot.Access.Owner == "" {
+		slog.Warn("no discord users or guilds are allowed, every message is ignored")
+	}
+	if err := bot.Start(); err != nil {
+		slog.Error("failed to connect", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	defer bot.Close()
+
+	slog.Info("discord bot is running")
+	<-ctx.Done()
+}
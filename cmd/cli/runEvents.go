@@ -22,7 +22,14 @@ func runEvents(_ context.Context, cancel context.CancelFunc, fn func(chan<- agen
 	}()
 
 	skillNone := false
+	streaming := false
 	for ev := range ch {
+		// * close the streamed line before any other output
+		if streaming && ev.Type != agentTypes.EventTextDelta && ev.Type != agentTypes.EventText {
+			fmt.Println()
+			streaming = false
+		}
+
 		switch ev.Type {
 		case agentTypes.EventSkillSelect:
 			fmt.Printf("[~] Selecting skill...")
@@ -45,7 +52,19 @@ func runEvents(_ context.Context, cancel context.CancelFunc, fn func(chan<- agen
 		case agentTypes.EventAgentResult:
 			fmt.Printf("\033[2K\r[*] Agent: %s\n", ev.Text)
 
+		case agentTypes.EventTextDelta:
+			if !streaming {
+				fmt.Print("[*] ")
+				streaming = true
+			}
+			fmt.Print(ev.Text)
 		case agentTypes.EventText:
+			// * already shown by deltas
+			if streaming {
+				fmt.Println()
+				streaming = false
+				continue
+			}
 			fmt.Printf("[*] %s\n", ev.Text)
 
 		case agentTypes.EventToolCall:
@@ -79,6 +98,15 @@ func runEvents(_ context.Context, cancel context.CancelFunc, fn func(chan<- agen
 		case agentTypes.EventToolSkipped:
 			fmt.Printf("[x] Skipped: %s\n", ev.ToolName)
 
+		case agentTypes.EventCompact:
+			fmt.Printf("[-] %s\n", ev.Text)
+
+		case agentTypes.EventRetry:
+			fmt.Printf("[~] %s\n", ev.Text)
+
+		case agentTypes.EventFailover:
+			fmt.Printf("[!] %s\n", ev.Text)
+
 		case agentTypes.EventToolResult:
 			fmt.Printf("[*] Result: %s\n", strings.TrimSpace(ev.Result))
 
@@ -88,7 +116,7 @@ func runEvents(_ context.Context, cancel context.CancelFunc, fn func(chan<- agen
 			}
 
 		case agentTypes.EventDone:
-			fmt.Printf(" (%s)", time.Since(start).Round(time.Millisecond))
+			fmt.Printf(" (%s%s)", time.Since(start).Round(time.Millisecond), usageSummary(ev.Usage))
 			fmt.Println()
 		}
 	}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	"github.com/pardnchiu/agenvoy/internal/mcp"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	"github.com/pardnchiu/agenvoy/internal/tools"
+)
+
+// * stdout carries json-rpc only, logs go to stderr
+func runMCP() {
+	cfg, err := exec.NewConfig("")
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	executor, err := tools.NewLocalExecutor(cfg.WorkDir, cfg.ConfigDir)
+	if err != nil {
+		slog.Error("tools.NewLocalExecutor", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	server := mcp.NewServer("agenvoy", "0.1.0")
+	server.Instructions = fmt.Sprintf("Tools operate inside %s. Prompts are agent skills.", cfg.WorkDir)
+	tools.ServeTools(server, executor)
+	serveSkills(server, skill.NewScanner())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+		slog.Error("failed to serve", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+// * every skill becomes a prompt with an optional input argument
+func serveSkills(server *mcp.Server, scanner *skill.Scanner) {
+	list := scanner.Snapshot()
+	names := make([]string, 0, len(list.ByName))
+	for name := range list.ByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := list.ByName[name]
+		server.AddPrompt(mcp.Prompt{
+			Name:        s.Name,
+			Description: s.Description,
+			Arguments: []mcp.PromptArgument{
+				{Name: "input", Description: "task for the skill"},
+			},
+		}, func(ctx context.Context, args map[string]string) (*mcp.GetPromptResult, error) {
+			var sb strings.Builder
+			sb.WriteString(s.Resolve(s.Body))
+			if input := strings.TrimSpace(args["input"]); input != "" {
+				sb.WriteString("\n\n---\n\n")
+				sb.WriteString(input)
+			}
+			return &mcp.GetPromptResult{
+				Description: s.Description,
+				Messages: []mcp.PromptMessage{
+					{Role: "user", Content: mcp.Content{Type: "text", Text: sb.String()}},
+				},
+			}, nil
+		})
+	}
+}
+
+// * project mcp.json starts processes, it is only loaded after the user trusted its current content
+func runMCPTrust() {
+	cfg, err := exec.NewConfig("")
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	servers := mcp.LoadConfig(cfg.ConfigDir.Work)
+	if len(servers) == 0 {
+		fmt.Printf("No servers in %s/mcp.json\n", cfg.ConfigDir.Work)
+		return
+	}
+	if err := mcp.Trust(cfg.ConfigDir.Home, cfg.ConfigDir.Work); err != nil {
+		slog.Error("failed to trust mcp.json", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Trusted %s/mcp.json, editing it revokes the trust:\n", cfg.ConfigDir.Work)
+	for _, name := range names {
+		server := servers[name]
+		if server.URL != "" {
+			fmt.Printf("  • %s: %s\n", name, server.URL)
+			continue
+		}
+		fmt.Printf("  • %s: %s\n", name, strings.Join(append([]string{server.Command}, server.Args...), " "))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/copilot"
+	"github.com/pardnchiu/agenvoy/internal/server"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	"github.com/pardnchiu/agenvoy/internal/tools"
+)
+
+// * AGENVOY_TOKEN, when set, is required as a bearer token, and must be set for a non-loopback addr or --allow;
+// * --allow skips confirmation for every run, including /v1/chat/completions
+func runServe(args []string) {
+	defer tools.CloseMCP()
+	defer sessions.Close()
+
+	addr := "127.0.0.1:8080"
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "--addr" {
+			addr = args[i+1]
+		}
+	}
+
+	allowAll := slices.Contains(args, "--allow")
+	token := os.Getenv("AGENVOY_TOKEN")
+	if token == "" && server.NeedsToken(addr, allowAll) {
+		slog.Error("AGENVOY_TOKEN is required when serving beyond loopback or with --allow", slog.String("addr", addr))
+		os.Exit(1)
+	}
+
+	cfg, err := exec.NewConfig("")
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	cfg.AllowAll = allowAll
+
+	selectorBot, err := copilot.New()
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	cfg.Embedding = getEmbedding(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scanner := skill.NewScanner()
+	go watchSkills(ctx, scanner)
+
+	s := server.New(cfg, selectorBot, getAgentRegistry(cfg), scanner)
+	s.Token = token
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("serving", slog.String("addr", addr))
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("failed to serve", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+)
+
+// * sessions are bound per working directory, switch only changes the binding of cwd
+func runSession(args []string) {
+	defer sessions.Close()
+
+	cfg, err := exec.NewConfig("")
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	args, flags := sessionFlags(args)
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch {
+	case action == "list":
+		err = listSessions(cfg)
+
+	case action == "create" && len(args) == 2:
+		if err = sessions.Create(cfg.ConfigDir, args[1]); err == nil {
+			err = sessions.Switch(cfg.ConfigDir, cfg.WorkDir, args[1])
+		}
+		if err == nil {
+			fmt.Printf("[*] Created session: %s\n", args[1])
+		}
+
+	case action == "switch" && len(args) == 2:
+		if err = sessions.Switch(cfg.ConfigDir, cfg.WorkDir, args[1]); err == nil {
+			fmt.Printf("[*] Switched to session: %s\n", args[1])
+		}
+
+	case action == "rename" && len(args) == 3:
+		if err = sessions.Rename(cfg.ConfigDir, args[1], args[2]); err == nil {
+			fmt.Printf("[*] Renamed session: %s -> %s\n", args[1], args[2])
+		}
+
+	case action == "delete" && len(args) == 2:
+		if err = sessions.Delete(cfg.ConfigDir, args[1]); err == nil {
+			fmt.Printf("[*] Deleted session: %s\n", args[1])
+		}
+
+	case action == "show" && len(args) <= 2:
+		err = showSession(cfg, args[1:], flags)
+
+	case action == "export" && len(args) <= 2:
+		err = exportSession(cfg, args[1:], flags)
+
+	case action == "fork" && (len(args) == 2 || len(args) == 3):
+		err = forkSession(cfg, args[1:], flags["--at"])
+
+	case action == "rewind" && len(args) <= 2 && flags["--at"] != "":
+		err = rewindSession(cfg, args[1:], flags["--at"])
+
+	case action == "import" && len(args) == 2:
+		err = importSession(cfg, args[1], flags["--as"])
+
+	case action == "migrate" && len(args) == 1:
+		err = migrateSessions(cfg)
+
+	default:
+		fmt.Println("Usage: go run cmd/cli/main.go session [list]")
+		fmt.Println("       go run cmd/cli/main.go session create <name>")
+		fmt.Println("       go run cmd/cli/main.go session switch <name>")
+		fmt.Println("       go run cmd/cli/main.go session rename <old> <new>")
+		fmt.Println("       go run cmd/cli/main.go session delete <name>")
+		fmt.Println("       go run cmd/cli/main.go session fork [name] <new name> [--at index]")
+		fmt.Println("       go run cmd/cli/main.go session rewind [name] --at <index>")
+		fmt.Println("       go run cmd/cli/main.go session show [name] [--format md|json|html]")
+		fmt.Println("       go run cmd/cli/main.go session export [name] [--format md|json|html] [--output file]")
+		fmt.Println("       go run cmd/cli/main.go session import <file> [--as name]")
+		fmt.Println("       go run cmd/cli/main.go session migrate")
+		os.Exit(1)
+	}
+
+	if err != nil {
+		slog.Error("failed to manage session", slog.String("error", err.Error()))
+		sessions.Close()
+		os.Exit(1)
+	}
+}
+
+func listSessions(cfg *exec.Config) error {
+	list, err := sessions.List(cfg.ConfigDir, cfg.WorkDir)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println("No sessions found")
+		return nil
+	}
+
+	fmt.Printf("Found %d session(s):\n\n", len(list))
+	for _, s := range list {
+		mark := " "
+		if s.Current {
+			mark = "*"
+		}
+		fmt.Printf("%s %s\n", mark, s.ID)
+		fmt.Printf("  Updated: %s\n", s.UpdatedAt.Format("2006-01-02 15:04"))
+		for _, dir := range s.WorkDirs {
+			fmt.Printf("  Bound: %s\n", dir)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// * --format, --output, --as and --at take a value, everything else is positional
+func sessionFlags(args []string) ([]string, map[string]string) {
+	var positional []string
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format", "--output", "--as", "--at":
+			if i+1 < len(args) {
+				flags[args[i]] = args[i+1]
+				i++
+			}
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	return positional, flags
+}
+
+// * name from args, or the session bound to cwd
+func sessionID(cfg *exec.Config, args []string) (string, error) {
+	if len(args) > 0 && args[0] != "" {
+		return args[0], nil
+	}
+	return sessions.Current(cfg.ConfigDir, cfg.WorkDir)
+}
+
+func loadSession(cfg *exec.Config, args []string) (*sessions.Export, error) {
+	id, err := sessionID(cfg, args)
+	if err != nil {
+		return nil, err
+	}
+	return sessions.Load(cfg.ConfigDir, cfg.WorkDir, id)
+}
+
+func showSession(cfg *exec.Config, args []string, flags map[string]string) error {
+	export, err := loadSession(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	format := flags["--format"]
+	if format == "" {
+		format = sessions.FormatMarkdown
+	}
+	return sessions.Render(os.Stdout, export, format, false)
+}
+
+// * format defaults to the --output extension, then md; output defaults to {name}.{format}
+func exportSession(cfg *exec.Config, args []string, flags map[string]string) error {
+	export, err := loadSession(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	output := flags["--output"]
+	format := flags["--format"]
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(output), ".")
+	}
+	if !slices.Contains(sessions.Formats, format) {
+		if flags["--format"] != "" {
+			return fmt.Errorf("unsupported format: %s", format)
+		}
+		format = sessions.FormatMarkdown
+	}
+	if output == "" {
+		output = export.ID + "." + format
+	}
+
+	var buf bytes.Buffer
+	if err := sessions.Render(&buf, export, format, true); err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	fmt.Printf("[*] Exported session %s to %s\n", export.ID, output)
+	return nil
+}
+
+func importSession(cfg *exec.Config, path, as string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	export, err := sessions.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	id, err := sessions.Import(cfg.ConfigDir, cfg.WorkDir, export, as)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[*] Imported session: %s\n", id)
+	fmt.Printf("    Use it with: agenvoy session switch %s\n", id)
+	return nil
+}
+
+// * index is the first history entry dropped, as shown by session show; negative counts from the end
+func parseIndex(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	index, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index: %s", value)
+	}
+	return index, nil
+}
+
+// * [name] <new name>, name empty means the session bound to cwd; cwd is switched to the fork
+func forkSession(cfg *exec.Config, args []string, at string) error {
+	export, err := loadSession(cfg, args[:len(args)-1])
+	if err != nil {
+		return err
+	}
+	id, newID := export.ID, args[len(args)-1]
+
+	index, err := parseIndex(at, len(export.History))
+	if err != nil {
+		return err
+	}
+
+	if err := sessions.Fork(cfg.ConfigDir, id, newID, index); err != nil {
+		return err
+	}
+	if err := sessions.Switch(cfg.ConfigDir, cfg.WorkDir, newID); err != nil {
+		return err
+	}
+	fmt.Printf("[*] Forked session %s into %s\n", id, newID)
+	return nil
+}
+
+func rewindSession(cfg *exec.Config, args []string, at string) error {
+	id, err := sessionID(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	index, err := parseIndex(at, 0)
+	if err != nil {
+		return err
+	}
+	if err := sessions.Rewind(cfg.ConfigDir, id, index); err != nil {
+		return err
+	}
+	fmt.Printf("[*] Rewound session %s\n", id)
+	return nil
+}
+
+// * json sessions into sessions.db, later runs use the sqlite store
+func migrateSessions(cfg *exec.Config) error {
+	migrated, err := sessions.Migrate(cfg.ConfigDir)
+	for _, id := range migrated {
+		fmt.Printf("[*] Migrated session: %s\n", id)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[*] Session store: %s (%d migrated)\n", sessions.BackendSQLite, len(migrated))
+	return nil
+}
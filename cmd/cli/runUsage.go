@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+)
+
+// * usage [name] [--all] [--since YYYY-MM-DD], the session bound to cwd by default
+func runUsage(args []string) {
+	defer sessions.Close()
+
+	cfg, err := exec.NewConfig("")
+	if err != nil {
+		slog.Error("failed to initialize", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	all := false
+	var since time.Time
+	var ids []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--all":
+			all = true
+		case "--since":
+			if i+1 < len(args) {
+				i++
+				since, err = time.ParseInLocation("2006-01-02", args[i], time.Local)
+			}
+		default:
+			ids = append(ids, args[i])
+		}
+	}
+	if err != nil || (all && len(ids) > 0) || len(ids) > 1 {
+		fmt.Println("Usage: go run cmd/cli/main.go usage [name] [--since YYYY-MM-DD]")
+		fmt.Println("       go run cmd/cli/main.go usage --all [--since YYYY-MM-DD]")
+		os.Exit(1)
+	}
+
+	if !all && len(ids) == 0 {
+		id, err := sessions.Current(cfg.ConfigDir, cfg.WorkDir)
+		if err != nil {
+			slog.Error("failed to report usage", slog.String("error", err.Error()))
+			sessions.Close()
+			os.Exit(1)
+		}
+		ids = []string{id}
+	}
+
+	reports, err := sessions.Usage(cfg.ConfigDir, ids, since)
+	if err != nil {
+		slog.Error("failed to report usage", slog.String("error", err.Error()))
+		sessions.Close()
+		os.Exit(1)
+	}
+	printUsage(reports, all)
+}
+
+func printUsage(reports []sessions.UsageReport, all bool) {
+	var runs int
+	var models []agentTypes.ModelUsage
+	printed := 0
+	for _, report := range reports {
+		if report.Runs == 0 {
+			if !all {
+				fmt.Printf("No usage recorded for session: %s\n", report.ID)
+			}
+			continue
+		}
+		if printed > 0 {
+			fmt.Println()
+		}
+		printed++
+
+		fmt.Printf("Session: %s (%d run(s), %s - %s)\n", report.ID, report.Runs,
+			report.First.Local().Format("2006-01-02 15:04"), report.Last.Local().Format("2006-01-02 15:04"))
+		printModels(report.Models)
+
+		runs += report.Runs
+		models = append(models, report.Models...)
+	}
+
+	if all && printed == 0 {
+		fmt.Println("No usage recorded")
+		return
+	}
+	if printed > 1 {
+		total := sessions.SumUsage("", []sessions.UsageLog{{Models: models}})
+		fmt.Printf("\nAll sessions (%d run(s))\n", runs)
+		printModels(total.Models)
+	}
+}
+
+func printModels(models []agentTypes.ModelUsage) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  MODEL\tINPUT\tOUTPUT\tCACHE READ\tCACHE WRITE\tCOST")
+
+	var total agentTypes.Usage
+	var cost float64
+	for _, m := range models {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", m.Model,
+			formatCount(m.Usage.InputTokens), formatCount(m.Usage.OutputTokens),
+			formatCount(m.Usage.CacheReadTokens), formatCount(m.Usage.CacheWriteTokens), formatCost(m.Cost))
+		total.Add(&m.Usage)
+		cost += m.Cost
+	}
+	if len(models) > 1 {
+		fmt.Fprintf(w, "  Total\t%s\t%s\t%s\t%s\t%s\n",
+			formatCount(total.InputTokens), formatCount(total.OutputTokens),
+			formatCount(total.CacheReadTokens), formatCount(total.CacheWriteTokens), formatCost(cost))
+	}
+	w.Flush()
+}
+
+// * shown after the elapsed time of a run, ex. ", 12,345 tokens, ~$0.0123"
+func usageSummary(models []agentTypes.ModelUsage) string {
+	var total agentTypes.Usage
+	var cost float64
+	for _, m := range models {
+		total.Add(&m.Usage)
+		cost += m.Cost
+	}
+	if total.IsZero() {
+		return ""
+	}
+	text := fmt.Sprintf(", %s tokens", formatCount(total.Total()))
+	if cost > 0 {
+		text += ", ~" + formatCost(cost)
+	}
+	return text
+}
+
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// * unpriced models show "-"
+func formatCost(cost float64) string {
+	if cost == 0 {
+		return "-"
+	}
+	if cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pardnchiu/agenvoy/internal/skill"
+)
+
+// * long-running commands pick up installed or edited skills without a restart
+func watchSkills(ctx context.Context, scanner *skill.Scanner) {
+	if err := scanner.Watch(ctx); err != nil {
+		slog.Warn("failed to watch skills",
+			slog.String("error", err.Error()))
+	}
+}
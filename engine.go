@@ -0,0 +1,323 @@
+package agenvoy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	"github.com/pardnchiu/agenvoy/internal/agents/router"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	"github.com/pardnchiu/agenvoy/internal/tools"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// ToolHandler receives the raw JSON arguments produced by the model.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+type engineAgent struct {
+	entry AgentEntry
+	agent Agent // * nil until built from provider name
+}
+
+// Engine is configured with the With* methods. The configuration is frozen on
+// the first Run, after which Run is safe to call concurrently.
+type Engine struct {
+	mu         sync.Mutex
+	workDir    string
+	configDir  string
+	secrets    func(key string) string
+	httpClient *http.Client
+	selector   Agent
+	agents     []engineAgent
+	failover   []string
+	embedding  string
+	skillPaths []string
+	skills     []*Skill
+	tools      []Tool
+	allowAll   bool
+	sessionID  string
+	err        error
+
+	built    bool
+	cfg      *exec.Config
+	registry agentTypes.AgentRegistry
+	scanner  *skill.Scanner
+}
+
+// New returns an engine using cwd, ~/.config/agenvoy and the OS keychain
+// unless overridden.
+func New() *Engine {
+	return &Engine{}
+}
+
+// WithWorkDir sets the folder file tools and commands operate in.
+func (e *Engine) WithWorkDir(dir string) *Engine {
+	e.workDir = dir
+	return e
+}
+
+// WithConfigDir replaces ~/.config/agenvoy for sessions, api tools, and tokens.
+func (e *Engine) WithConfigDir(dir string) *Engine {
+	e.configDir = dir
+	return e
+}
+
+// WithSecrets replaces the OS keychain lookup used by WithProvider.
+func (e *Engine) WithSecrets(fn func(key string) string) *Engine {
+	e.secrets = fn
+	return e
+}
+
+// WithHTTPClient sets the client used by agents created with WithProvider.
+func (e *Engine) WithHTTPClient(client *http.Client) *Engine {
+	e.httpClient = client
+	return e
+}
+
+// WithAgent registers an agent instance. The first registered agent is the fallback.
+func (e *Engine) WithAgent(name, description string, agent Agent) *Engine {
+	if agent == nil {
+		e.setErr(fmt.Errorf("agent %s is nil", name))
+		return e
+	}
+	e.agents = append(e.agents, engineAgent{
+		entry: AgentEntry{Name: name, Description: description},
+		agent: agent,
+	})
+	return e
+}
+
+// WithProvider registers an agent created from a model entry name, ex.
+// "claude@claude-sonnet-4-5", using the engine's secrets and directories.
+func (e *Engine) WithProvider(name, description string) *Engine {
+	e.agents = append(e.agents, engineAgent{
+		entry: AgentEntry{Name: name, Description: description},
+	})
+	return e
+}
+
+// WithSelector sets the agent used to choose a skill and an agent, default is the fallback.
+func (e *Engine) WithSelector(agent Agent) *Engine {
+	e.selector = agent
+	return e
+}
+
+// WithFailover sets the agents, by registered name, that take over in order
+// when the chosen one is rate limited or unavailable. The default is the
+// registration order; calling it with no names disables failover.
+func (e *Engine) WithFailover(names ...string) *Engine {
+	if names == nil {
+		names = []string{}
+	}
+	e.failover = names
+	return e
+}
+
+// WithEmbedding narrows skills and agents by embedding similarity before the
+// selector, ex. "compat@nomic-embed-text"; the selector only breaks close ties.
+// Vectors are cached under the config dir.
+func (e *Engine) WithEmbedding(model string) *Engine {
+	e.embedding = model
+	return e
+}
+
+// WithSkillPaths replaces the default skill folders, each holding {name}/SKILL.md.
+func (e *Engine) WithSkillPaths(paths ...string) *Engine {
+	e.skillPaths = append(e.skillPaths, paths...)
+	return e
+}
+
+// WithSkills attaches skills directly, they win over scanned skills with the same name.
+func (e *Engine) WithSkills(skills ...*Skill) *Engine {
+	e.skills = append(e.skills, skills...)
+	return e
+}
+
+// WithTool exposes a custom tool to every agent, parameters is a JSON schema
+// object. The tool has no flags, so it always asks for confirmation.
+func (e *Engine) WithTool(name, description string, parameters json.RawMessage, handler ToolHandler) *Engine {
+	if name == "" || handler == nil {
+		e.setErr(fmt.Errorf("tool requires name and handler"))
+		return e
+	}
+	return e.WithTools(NewTool(ToolDef{
+		Function: ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}, 0, func(ctx context.Context, _ *Executor, args json.RawMessage) (string, error) {
+		return handler(ctx, args)
+	}))
+}
+
+// WithTools registers Tool implementations next to the built-in tools.
+// FlagReadOnly tools skip the confirmation prompt.
+func (e *Engine) WithTools(tools ...Tool) *Engine {
+	e.tools = append(e.tools, tools...)
+	return e
+}
+
+// AllowAll skips EventToolConfirm. Without it the caller must answer every
+// EventToolConfirm on its ReplyCh.
+func (e *Engine) AllowAll(allow bool) *Engine {
+	e.allowAll = allow
+	return e
+}
+
+// WithSession keeps history and summary in the named session instead of the
+// one bound to the work dir.
+func (e *Engine) WithSession(id string) *Engine {
+	if !sessions.Valid(id) {
+		e.setErr(fmt.Errorf("invalid session id: %s", id))
+		return e
+	}
+	e.sessionID = id
+	return e
+}
+
+// Run selects a skill and an agent for input and runs the tool loop. The
+// returned channel is closed after EventDone or EventError.
+func (e *Engine) Run(ctx context.Context, input string) (<-chan Event, error) {
+	if err := e.build(); err != nil {
+		return nil, err
+	}
+
+	selector := e.selector
+	if selector == nil {
+		selector = e.registry.Fallback
+	}
+
+	events := make(chan Event, 16)
+	go func() {
+		defer close(events)
+		if err := exec.Run(ctx, e.cfg, selector, e.registry, e.scanner, input, events); err != nil {
+			events <- Event{Type: EventError, Err: err}
+		}
+	}()
+	return events, nil
+}
+
+// Close stops the MCP servers started for this engine's config dirs.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cfg != nil {
+		tools.CloseMCP(e.cfg.ConfigDir)
+	}
+	return nil
+}
+
+func (e *Engine) setErr(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *Engine) build() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		return e.err
+	}
+	if e.built {
+		return nil
+	}
+
+	workDir := e.workDir
+	if workDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("os.Getwd: %w", err)
+		}
+		workDir = wd
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("os.UserHomeDir: %w", err)
+	}
+
+	configHome := e.configDir
+	if configHome == "" {
+		configHome = utils.ProjectDir(homeDir)
+	}
+
+	configDir, err := utils.NewConfigDir(configHome, utils.ProjectDir(workDir))
+	if err != nil {
+		return fmt.Errorf("utils.NewConfigDir: %w", err)
+	}
+
+	providerConfig := ProviderConfig{
+		Secrets:    e.secrets,
+		WorkDir:    workDir,
+		ConfigDir:  configHome,
+		HTTPClient: e.httpClient,
+	}
+
+	registry := agentTypes.AgentRegistry{
+		Registry: make(map[string]Agent, len(e.agents)),
+		Entries:  make([]AgentEntry, 0, len(e.agents)),
+		Failover: e.failover,
+	}
+	for i, a := range e.agents {
+		if a.agent == nil {
+			agent, err := NewAgent(a.entry.Name, providerConfig)
+			if err != nil {
+				return fmt.Errorf("NewAgent %s: %w", a.entry.Name, err)
+			}
+			e.agents[i].agent = agent
+			a.agent = agent
+		}
+		registry.Registry[a.entry.Name] = a.agent
+		registry.Entries = append(registry.Entries, a.entry)
+		if registry.Fallback == nil {
+			registry.Fallback = a.agent
+		}
+	}
+	if registry.Fallback == nil {
+		return fmt.Errorf("no agent registered")
+	}
+
+	paths := e.skillPaths
+	if len(paths) == 0 {
+		paths = skill.DefaultPaths(workDir, homeDir)
+	}
+	scanner := skill.NewScannerWithPaths(paths...)
+	scanner.Add(e.skills...)
+
+	var embedding *router.Embedding
+	if e.embedding != "" {
+		agent, err := NewAgent(e.embedding, providerConfig)
+		if err != nil {
+			return fmt.Errorf("NewAgent %s: %w", e.embedding, err)
+		}
+		embedder, ok := agent.(agentTypes.Embedder)
+		if !ok {
+			return fmt.Errorf("%s has no embeddings", e.embedding)
+		}
+		embedding = router.NewEmbedding(embedder, router.EmbeddingConfig{Model: e.embedding}, configHome)
+	}
+
+	e.cfg = &exec.Config{
+		WorkDir:         workDir,
+		ConfigDir:       configDir,
+		AllowAll:        e.allowAll,
+		Tools:           e.tools,
+		SessionID:       e.sessionID,
+		Embedding:       embedding,
+		ToolConcurrency: exec.GetToolConcurrency(configDir),
+	}
+	e.registry = registry
+	e.scanner = scanner
+	e.built = true
+	return nil
+}
@@ -0,0 +1,262 @@
+package agenvoy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * first Stream call asks for the echo tool, the second answers with its result
+type scriptedAgent struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (a *scriptedAgent) Send(ctx context.Context, messages []Message, toolDefs []toolTypes.ToolDef) (*Output, error) {
+	return &Output{Choices: []agentTypes.OutputChoices{textChoice("none")}}, nil
+}
+
+func (a *scriptedAgent) Stream(ctx context.Context, messages []Message, toolDefs []toolTypes.ToolDef, onDelta func(text string)) (*Output, error) {
+	a.mu.Lock()
+	a.calls++
+	call := a.calls
+	a.mu.Unlock()
+
+	out := &Output{}
+	if call == 1 {
+		found := false
+		for _, def := range toolDefs {
+			if def.Function.Name == "echo" {
+				found = true
+			}
+		}
+		if !found {
+			out.Choices = append(out.Choices, textChoice("echo tool missing"))
+			return out, nil
+		}
+		choice := textChoice("")
+		choice.Message.ToolCalls = []agentTypes.ToolCall{{ID: "call_1", Type: "function"}}
+		choice.Message.ToolCalls[0].Function.Name = "echo"
+		choice.Message.ToolCalls[0].Function.Arguments = `{"text":"hi"}`
+		out.Choices = append(out.Choices, choice)
+		return out, nil
+	}
+
+	last := messages[len(messages)-1]
+	text, _ := last.Content.(string)
+	onDelta(text)
+	out.Choices = append(out.Choices, textChoice(text))
+	return out, nil
+}
+
+func (a *scriptedAgent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- Event, allowAll bool) error {
+	return nil
+}
+
+func textChoice(text string) agentTypes.OutputChoices {
+	return agentTypes.OutputChoices{
+		Message: Message{Role: "assistant", Content: text},
+	}
+}
+
+func TestEngine_RunWithCustomTool(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := t.TempDir()
+
+	engine := New().
+		WithWorkDir(workDir).
+		WithConfigDir(filepath.Join(home, "agenvoy")).
+		WithSkillPaths(t.TempDir()).
+		WithAgent("fake@model", "test agent", &scriptedAgent{}).
+		WithTool("echo", "echo text back", json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+			func(ctx context.Context, args json.RawMessage) (string, error) {
+				var params struct {
+					Text string `json:"text"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", err
+				}
+				return "echo:" + params.Text, nil
+			}).
+		AllowAll(true)
+
+	events, err := engine.Run(context.Background(), "say hi")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var text string
+	var toolResult string
+	var done bool
+	for ev := range events {
+		switch ev.Type {
+		case EventToolResult:
+			toolResult = ev.Result
+		case EventText:
+			text = ev.Text
+		case EventDone:
+			done = true
+		case EventError:
+			t.Fatalf("unexpected error event: %v", ev.Err)
+		}
+	}
+
+	if toolResult != "echo:hi" {
+		t.Errorf("tool result = %q, want echo:hi", toolResult)
+	}
+	if !strings.Contains(text, "echo:hi") {
+		t.Errorf("final text = %q, want it to contain echo:hi", text)
+	}
+	if !done {
+		t.Error("missing EventDone")
+	}
+}
+
+func TestEngine_RunWithoutAgent(t *testing.T) {
+	if _, err := New().WithWorkDir(t.TempDir()).Run(context.Background(), "hi"); err == nil {
+		t.Fatal("expected error without any agent")
+	}
+}
+
+func TestNewAgent_UnknownProvider(t *testing.T) {
+	if _, err := NewAgent("unknown@model", ProviderConfig{}); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+}
+
+func TestNewAgent_SecretsInjected(t *testing.T) {
+	var asked []string
+	cfg := ProviderConfig{
+		WorkDir: t.TempDir(),
+		Secrets: func(key string) string {
+			asked = append(asked, key)
+			return "test-key"
+		},
+	}
+	if _, err := NewAgent("claude@claude-sonnet-4-5", cfg); err != nil {
+		t.Fatalf("NewAgent: %v", err)
+	}
+	if len(asked) != 1 || asked[0] != "ANTHROPIC_API_KEY" {
+		t.Errorf("asked secrets = %v, want [ANTHROPIC_API_KEY]", asked)
+	}
+}
+
+// * every Stream call fails with a rate limit that asks for a long wait
+type limitedAgent struct {
+	calls int
+}
+
+func (a *limitedAgent) Send(ctx context.Context, messages []Message, toolDefs []toolTypes.ToolDef) (*Output, error) {
+	return &Output{Choices: []agentTypes.OutputChoices{textChoice("none")}}, nil
+}
+
+func (a *limitedAgent) Stream(ctx context.Context, messages []Message, toolDefs []toolTypes.ToolDef, onDelta func(text string)) (*Output, error) {
+	a.calls++
+	return nil, fmt.Errorf("utils.POSTStream: %w", &utils.HTTPError{StatusCode: 429, RetryAfter: time.Minute})
+}
+
+func (a *limitedAgent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- Event, allowAll bool) error {
+	return nil
+}
+
+func TestEngine_Failover(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	limited := &limitedAgent{}
+	engine := New().
+		WithWorkDir(t.TempDir()).
+		WithConfigDir(filepath.Join(home, "agenvoy")).
+		WithSkillPaths(t.TempDir()).
+		WithAgent("limited@model", "rate limited agent", limited).
+		WithAgent("fake@model", "test agent", &scriptedAgent{}).
+		WithTool("echo", "echo text back", json.RawMessage(`{"type":"object"}`),
+			func(ctx context.Context, args json.RawMessage) (string, error) {
+				return "echo:hi", nil
+			}).
+		AllowAll(true)
+
+	events, err := engine.Run(context.Background(), "say hi")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var failover, text string
+	for ev := range events {
+		switch ev.Type {
+		case EventFailover:
+			failover = ev.Text
+		case EventRetry:
+			t.Errorf("a long Retry-After should switch agents without waiting: %s", ev.Text)
+		case EventText:
+			text = ev.Text
+		case EventError:
+			t.Fatalf("unexpected error event: %v", ev.Err)
+		}
+	}
+
+	if failover != "limited@model rate limited, switching to fake@model" {
+		t.Errorf("failover = %q", failover)
+	}
+	// * the whole run stays on the next agent once switched
+	if limited.calls != 1 || !strings.Contains(text, "echo:hi") {
+		t.Errorf("calls = %d, text = %q", limited.calls, text)
+	}
+}
+
+func TestEngine_MockProvider(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := t.TempDir()
+	script := `[{"tool_calls":[{"name":"echo","arguments":{"text":"hi"}}]},{"text":"said hi"}]`
+	if err := os.WriteFile(filepath.Join(workDir, "script.json"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := New().
+		WithWorkDir(workDir).
+		WithConfigDir(filepath.Join(home, "agenvoy")).
+		WithSkillPaths(t.TempDir()).
+		WithSelector(&scriptedAgent{}).
+		WithProvider("mock@script.json", "scripted agent").
+		WithTool("echo", "echo text back", json.RawMessage(`{"type":"object"}`),
+			func(ctx context.Context, args json.RawMessage) (string, error) {
+				return "echo:" + string(args), nil
+			}).
+		AllowAll(true)
+
+	events, err := engine.Run(context.Background(), "say hi")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var result, text string
+	for ev := range events {
+		switch ev.Type {
+		case EventToolResult:
+			result = ev.Result
+		case EventText:
+			text = ev.Text
+		case EventError:
+			t.Fatalf("unexpected error event: %v", ev.Err)
+		}
+	}
+	if result != `echo:{"text":"hi"}` || text != "said hi" {
+		t.Errorf("result = %q, text = %q", result, text)
+	}
+
+	if _, err := NewAgent("mock@", ProviderConfig{WorkDir: workDir}); err == nil {
+		t.Error("expected error without a script path")
+	}
+}
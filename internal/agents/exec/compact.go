@@ -0,0 +1,177 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+const (
+	// * 1/replyShare of the window is left for the reply
+	replyShare = 4
+	// * tool outputs of earlier iterations keep this many characters
+	toolKeep = 1024
+	// * floor when every tool output has to shrink
+	toolKeepMin = 256
+	// * role, separators and tool call framing per message
+	messageOverhead = 4
+)
+
+type compactStats struct {
+	Before    int
+	After     int
+	Truncated int
+	Dropped   int
+}
+
+func (s compactStats) changed() bool {
+	return s.Truncated > 0 || s.Dropped > 0
+}
+
+func (s compactStats) String() string {
+	return fmt.Sprintf("Context compacted: ~%d -> ~%d tokens, truncated %d tool output(s), dropped %d history message(s)",
+		s.Before, s.After, s.Truncated, s.Dropped)
+}
+
+// * tokens left for messages once the reply share and the tool schemas are taken out
+func inputBudget(window int, toolDefs []toolTypes.ToolDef) int {
+	budget := window - window/replyShare
+	if data, err := json.Marshal(toolDefs); err == nil && len(toolDefs) > 0 {
+		budget -= estimateText(string(data))
+	}
+	return budget
+}
+
+// * ascii runs about 4 characters a token, other scripts (CJK) about one character a token
+func estimateText(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+func estimateMessage(m agentTypes.Message) int {
+	tokens := messageOverhead + estimateText(messageText(m.Content))
+	for _, tc := range m.ToolCalls {
+		tokens += messageOverhead + estimateText(tc.Function.Name) + estimateText(tc.Function.Arguments)
+	}
+	return tokens
+}
+
+func estimateTokens(messages []agentTypes.Message) int {
+	total := 0
+	for _, m := range messages {
+		total += estimateMessage(m)
+	}
+	return total
+}
+
+func messageText(content any) string {
+	switch v := content.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+// * brings messages under limit, in order:
+// * 1. truncate tool outputs of earlier iterations, oldest first
+// * 2. drop history loaded before the current input, oldest first
+// * 3. shrink every tool output, the latest ones included
+// * system prompts, the current input and tool call / result pairing are kept
+func compact(messages []agentTypes.Message, limit int) ([]agentTypes.Message, compactStats) {
+	total := estimateTokens(messages)
+	stats := compactStats{Before: total, After: total}
+	if total <= limit {
+		return messages, stats
+	}
+
+	out := make([]agentTypes.Message, len(messages))
+	copy(out, messages)
+
+	input, lastCall := -1, -1
+	for i, m := range out {
+		switch {
+		case m.Role == "user":
+			input = i
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			lastCall = i
+		}
+	}
+
+	truncated := make(map[int]bool)
+	shrink := func(i, keep int) {
+		before := estimateMessage(out[i])
+		text, ok := truncateText(messageText(out[i].Content), keep)
+		// * the marker can outweigh what a short output loses
+		if !ok || estimateText(text) >= before-messageOverhead {
+			return
+		}
+		out[i].Content = text
+		total -= before - estimateMessage(out[i])
+		truncated[i] = true
+	}
+
+	for i := 0; i < lastCall && total > limit; i++ {
+		if out[i].Role == "tool" {
+			shrink(i, toolKeep)
+		}
+	}
+
+	dropped := make(map[int]bool)
+	for i := 0; i < input && total > limit; i++ {
+		if out[i].Role != "user" && out[i].Role != "assistant" || len(out[i].ToolCalls) > 0 {
+			continue
+		}
+		total -= estimateMessage(out[i])
+		dropped[i] = true
+	}
+
+	for keep := toolKeep; keep >= toolKeepMin && total > limit; keep /= 2 {
+		for i := range out {
+			if out[i].Role == "tool" && total > limit {
+				shrink(i, keep)
+			}
+		}
+	}
+
+	if len(dropped) > 0 {
+		kept := out[:0]
+		for i, m := range out {
+			if !dropped[i] {
+				kept = append(kept, m)
+			}
+		}
+		out = kept
+	}
+
+	stats.After = total
+	stats.Truncated = len(truncated)
+	stats.Dropped = len(dropped)
+	return out, stats
+}
+
+// * keeps the first keep characters, false when text is already short enough
+func truncateText(text string, keep int) (string, bool) {
+	n := utf8.RuneCountInString(text)
+	if n <= keep {
+		return text, false
+	}
+	runes := []rune(text)
+	return fmt.Sprintf("%s\n...[truncated %d characters to fit the context window]", string(runes[:keep]), n-keep), true
+}
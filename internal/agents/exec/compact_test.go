@@ -0,0 +1,88 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		model      string
+		configured int
+		want       int
+	}{
+		{"compat[ollama]@qwen3:8b", 0, 32768},
+		{"claude@claude-sonnet-4-5", 0, 200000},
+		{"nvidia@meta/llama-3.3-70b-instruct", 0, 128000},
+		{"gemini-2.5-pro", 0, 1048576},
+		{"compat@mystery", 0, DefaultContextWindow},
+		{"compat[ollama]@qwen3:8b", 8192, 8192},
+	}
+	for _, tt := range tests {
+		if got := ContextWindow(tt.model, tt.configured); got != tt.want {
+			t.Errorf("ContextWindow(%q, %d) = %d, want %d", tt.model, tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateText(t *testing.T) {
+	if got := estimateText(strings.Repeat("a", 400)); got != 100 {
+		t.Errorf("ascii = %d", got)
+	}
+	if got := estimateText("台積電股價"); got != 5 {
+		t.Errorf("cjk = %d", got)
+	}
+}
+
+func toolTurn(id, output string) []agentTypes.Message {
+	call := agentTypes.ToolCall{ID: id, Type: "function"}
+	call.Function.Name = "fetch_page"
+	return []agentTypes.Message{
+		{Role: "assistant", ToolCalls: []agentTypes.ToolCall{call}},
+		{Role: "tool", Content: output, ToolCallID: id},
+	}
+}
+
+func TestCompact(t *testing.T) {
+	page := strings.Repeat("x", 40000) // * ~10000 tokens
+	messages := []agentTypes.Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "old question " + strings.Repeat("y", 8000)},
+		{Role: "assistant", Content: "old answer"},
+		{Role: "user", Content: "current question"},
+	}
+	messages = append(messages, toolTurn("call_1", page)...)
+	messages = append(messages, toolTurn("call_2", page)...)
+
+	if out, stats := compact(messages, 1000000); stats.changed() || len(out) != len(messages) {
+		t.Fatalf("under the limit should not change: %+v", stats)
+	}
+
+	// * truncating the first page is enough
+	out, stats := compact(messages, 13000)
+	if stats.Truncated != 1 || stats.Dropped != 0 || stats.After > 13000 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if !strings.Contains(out[5].Content.(string), "truncated") || out[7].Content != page {
+		t.Error("only the earlier tool output should be truncated")
+	}
+	if messages[5].Content != page {
+		t.Error("input messages were modified")
+	}
+
+	// * then older history goes, then the latest output shrinks
+	out, stats = compact(messages, 1500)
+	if stats.Dropped != 2 || stats.Truncated != 2 || stats.After > 1500 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if out[1].Content != "current question" {
+		t.Errorf("current input lost: %+v", out[1])
+	}
+	for i, m := range out {
+		if m.Role == "tool" && out[i-1].ToolCalls[0].ID != m.ToolCallID {
+			t.Errorf("tool result %d lost its call", i)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/router"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * everything Run and Execute used to read from cwd and home
+type Config struct {
+	WorkDir   string
+	ConfigDir *utils.ConfigDirData // root config dirs, ex. ~/.config/agenvoy and {WorkDir}/.config/agenvoy
+	AllowAll  bool
+	Tools     []toolTypes.Tool
+	SessionID string            // empty means the session bound to WorkDir
+	Skill     string            // skill name used as is, empty means the selector picks one
+	Agent     string            // agent name used as is, empty means the selector picks one
+	Embedding *router.Embedding // narrows skills and agents before the selector, nil sends all of them
+	// tools run at once in one turn, zero means MaxToolConcurrency
+	ToolConcurrency int
+}
+
+// * workDir empty means cwd, config dir is ~/.config/agenvoy and {workDir}/.config/agenvoy
+func NewConfig(workDir string) (*Config, error) {
+	if workDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("os.Getwd: %w", err)
+		}
+		workDir = wd
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("os.UserHomeDir: %w", err)
+	}
+
+	configDir, err := utils.NewConfigDir(utils.ProjectDir(homeDir), utils.ProjectDir(workDir))
+	if err != nil {
+		return nil, fmt.Errorf("utils.NewConfigDir: %w", err)
+	}
+
+	return &Config{
+		WorkDir:         workDir,
+		ConfigDir:       configDir,
+		ToolConcurrency: GetToolConcurrency(configDir),
+	}, nil
+}
+
+// * "tool_concurrency" in config.json, the first config found wins
+func GetToolConcurrency(configDir *utils.ConfigDirData) int {
+	for _, dir := range configDir.Dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+		if err != nil {
+			continue
+		}
+		var cfg struct {
+			ToolConcurrency int `json:"tool_concurrency"`
+		}
+		if json.Unmarshal(data, &cfg) != nil || cfg.ToolConcurrency <= 0 {
+			continue
+		}
+		return cfg.ToolConcurrency
+	}
+	return MaxToolConcurrency
+}
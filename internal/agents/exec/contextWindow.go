@@ -0,0 +1,59 @@
+package exec
+
+import (
+	"strings"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+// * unknown models get a small window, compaction only starts when it is nearly full
+const DefaultContextWindow = 32768
+
+// * first matching prefix wins, the name is lowercased and stripped of provider and vendor path
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude-", 200000},
+	{"gpt-4.1", 1047576},
+	{"gpt-5", 400000},
+	{"gpt-4o", 128000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"gemini-", 1048576},
+	{"qwen3", 32768},
+	{"llama-3", 128000},
+	{"llama3", 128000},
+	{"deepseek", 128000},
+}
+
+// * configured comes from context_window of the models entry in config.json, zero picks from the name,
+// * ex. "compat[ollama]@qwen3:8b" or "nvidia@meta/llama-3.3-70b-instruct"
+func ContextWindow(model string, configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	name := strings.ToLower(model)
+	if at := strings.LastIndex(name, "@"); at != -1 {
+		name = name[at+1:]
+	}
+	if slash := strings.LastIndex(name, "/"); slash != -1 {
+		name = name[slash+1:]
+	}
+	for _, w := range contextWindows {
+		if strings.HasPrefix(name, w.prefix) {
+			return w.tokens
+		}
+	}
+	return DefaultContextWindow
+}
+
+func contextWindow(agent agentTypes.Agent) int {
+	if sizer, ok := agent.(agentTypes.ContextSizer); ok {
+		if n := sizer.ContextWindow(); n > 0 {
+			return n
+		}
+	}
+	return DefaultContextWindow
+}
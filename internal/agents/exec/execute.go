@@ -3,18 +3,15 @@ package exec
 import (
 	"context"
 	_ "embed"
-	"encoding/json"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
 	"github.com/pardnchiu/agenvoy/internal/skill"
 	"github.com/pardnchiu/agenvoy/internal/tools"
-	"github.com/pardnchiu/agenvoy/internal/utils"
 )
 
 //go:embed prompt/systemPrompt.md
@@ -28,24 +25,46 @@ const (
 	MaxSkillIterations = 128
 )
 
-func Execute(ctx context.Context, agent agentTypes.Agent, workDir string, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
+func Execute(ctx context.Context, cfg *Config, agent agentTypes.Agent, skill *skill.Skill, userInput string, events chan<- agentTypes.Event) error {
+	return execute(ctx, cfg, newAgentChain(agent), skill, userInput, events, newUsageMeter())
+}
+
+// * usage already holds the selector calls when coming from Run, agents take over from one another down the chain
+func execute(ctx context.Context, cfg *Config, agents *agentChain, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, usage *usageMeter) error {
 	// if skill is empty, then treat as no skill
 	if skill != nil && skill.Content == "" {
 		skill = nil
 	}
 
-	configDir, err := utils.GetConfigDir("sessions")
+	store, err := sessions.Open(cfg.ConfigDir)
 	if err != nil {
-		return fmt.Errorf("utils.ConfigDir: %w", err)
+		return fmt.Errorf("sessions.Open: %w", err)
 	}
 
-	prompt := getSystemPrompt(workDir, skill)
-	session, err := getSession(prompt, userInput)
+	sessionID := cfg.SessionID
+	if sessionID == "" {
+		sessionID, err = sessions.Current(cfg.ConfigDir, cfg.WorkDir)
+		if err != nil {
+			return fmt.Errorf("sessions.Current: %w", err)
+		}
+	}
+
+	prompt := getSystemPrompt(cfg.WorkDir, skill)
+	session, err := getSession(store, sessionID, prompt, userInput)
 	if err != nil {
 		return fmt.Errorf("getSession: %w", err)
 	}
 
-	exec, err := tools.NewExecutor(workDir, session.ID)
+	// * tokens are spent whether or not the run gets to the end
+	prices := GetPrices(cfg.ConfigDir)
+	defer func() {
+		recordUsage(store, session.ID, usage.report(prices))
+	}()
+	done := func() {
+		events <- agentTypes.Event{Type: agentTypes.EventDone, Usage: usage.report(prices)}
+	}
+
+	exec, err := tools.NewExecutor(cfg.WorkDir, cfg.ConfigDir, session.ID, cfg.Tools...)
 	if err != nil {
 		return fmt.Errorf("tools.NewExecutor: %w", err)
 	}
@@ -53,13 +72,32 @@ func Execute(ctx context.Context, agent agentTypes.Agent, workDir string, skill
 	limit := MaxToolIterations
 	if skill != nil {
 		limit = MaxSkillIterations
+		if skill.MaxIterations > 0 {
+			limit = skill.MaxIterations
+		}
+		if len(skill.AllowedTools) > 0 {
+			exec.Registry.Filter(skill.AllowsTool)
+		}
 	}
 
-	alreadyCall := make(map[string]string)
+	toolDefs := exec.Registry.Definitions()
+	alreadyCall := newToolCache()
 	emptyCount := 0
 	const maxEmpty = 3
+	writer := newStreamWriter(events)
 	for i := 0; i < limit; i++ {
-		resp, err := agent.Send(ctx, session.Messages, exec.Tools)
+		// * the budget follows the agent, a failover may land on a smaller window
+		resp, err := agents.call(ctx, events, func(agent agentTypes.Agent) (*agentTypes.Output, error) {
+			compactMessages(session, inputBudget(contextWindow(agent), toolDefs), events)
+			resp, err := agent.Stream(ctx, session.Messages, toolDefs, writer.write)
+			if writer.flush() && err != nil {
+				return nil, &partialError{err: err}
+			}
+			if err == nil {
+				usage.add(agent, resp)
+			}
+			return resp, err
+		})
 		if err != nil {
 			return err
 		}
@@ -68,7 +106,7 @@ func Execute(ctx context.Context, agent agentTypes.Agent, workDir string, skill
 			emptyCount++
 			if emptyCount >= maxEmpty {
 				events <- agentTypes.Event{Type: agentTypes.EventText, Text: "工具無法取得資料，請稍後再試或改用其他方式查詢。"}
-				events <- agentTypes.Event{Type: agentTypes.EventDone}
+				done()
 				return nil
 			}
 			continue
@@ -77,10 +115,12 @@ func Execute(ctx context.Context, agent agentTypes.Agent, workDir string, skill
 
 		choice := resp.Choices[0]
 		if len(choice.Message.ToolCalls) > 0 {
-			session, alreadyCall, err = toolCall(ctx, exec, choice, session, events, allowAll, alreadyCall)
+			// * session stays set for the deferred usage record when toolCall fails
+			next, err := toolCall(ctx, cfg, exec, choice, session, events, alreadyCall)
 			if err != nil {
 				return err
 			}
+			session = next
 			continue
 		}
 
@@ -90,7 +130,7 @@ func Execute(ctx context.Context, agent agentTypes.Agent, workDir string, skill
 			if text == "" {
 				text = "工具無法取得資料，請稍後再試或改用其他方式查詢。"
 			}
-			cleaned := extractSummary(configDir, session.ID, text)
+			cleaned := extractSummary(store, session.ID, text)
 
 			events <- agentTypes.Event{Type: agentTypes.EventText, Text: cleaned}
 
@@ -98,7 +138,7 @@ func Execute(ctx context.Context, agent agentTypes.Agent, workDir string, skill
 
 			session.Messages = append(session.Messages, choice.Message)
 
-			err := writeHistory(choice, configDir, session)
+			err := writeHistory(choice, store, session)
 			if err != nil {
 				slog.Warn("Failed to write history",
 					slog.String("error", err.Error()))
@@ -109,43 +149,64 @@ func Execute(ctx context.Context, agent agentTypes.Agent, workDir string, skill
 			return fmt.Errorf("unexpected content type: %T", choice.Message.Content)
 		}
 
-		events <- agentTypes.Event{Type: agentTypes.EventDone}
+		done()
 
 		if len(session.Tools) > 0 {
-			now := time.Now()
-			date := now.Format("2006-01-02")
-			dateWithSec := now.Format("2006-01-02-15-04-05")
-			toolActionsDir := filepath.Join(configDir.Work, session.ID, date)
-			if err := os.MkdirAll(toolActionsDir, 0755); err == nil {
-				filename := dateWithSec + ".json"
-				toolActionsPath := filepath.Join(toolActionsDir, filename)
-				if data, err := json.Marshal(session.Tools); err == nil {
-					os.WriteFile(toolActionsPath, data, 0644)
-				}
+			log := sessions.ToolLog{Time: time.Now(), WorkDir: cfg.WorkDir, Messages: session.Tools}
+			if err := store.AppendToolRun(session.ID, cfg.WorkDir, log); err != nil {
+				slog.Warn("failed to write tool actions",
+					slog.String("error", err.Error()))
 			}
 		}
 		return nil
 	}
 
-	summaryMessages := append(session.Messages, agentTypes.Message{
-		Role:    "user",
-		Content: "請根據以上工具查詢結果，整理並總結回答原始問題。",
+	resp, err := agents.call(ctx, events, func(agent agentTypes.Agent) (*agentTypes.Output, error) {
+		compactMessages(session, inputBudget(contextWindow(agent), nil), events)
+		summaryMessages := append(session.Messages, agentTypes.Message{
+			Role:    "user",
+			Content: "請根據以上工具查詢結果，整理並總結回答原始問題。",
+		})
+		resp, err := agent.Send(ctx, summaryMessages, nil)
+		if err == nil {
+			usage.add(agent, resp)
+		}
+		return resp, err
 	})
-	resp, err := agent.Send(ctx, summaryMessages, nil)
 	if err == nil && len(resp.Choices) > 0 {
 		if text, ok := resp.Choices[0].Message.Content.(string); ok && text != "" {
-			cleaned := extractSummary(configDir, session.ID, text)
+			cleaned := extractSummary(store, session.ID, text)
 			events <- agentTypes.Event{Type: agentTypes.EventText, Text: cleaned}
-			events <- agentTypes.Event{Type: agentTypes.EventDone}
+			done()
 			return nil
 		}
 	}
 
 	events <- agentTypes.Event{Type: agentTypes.EventText, Text: "工具無法取得資料，請稍後再試或改用其他方式查詢。"}
-	events <- agentTypes.Event{Type: agentTypes.EventDone}
+	done()
 	return nil
 }
 
+func recordUsage(store sessions.Store, sessionID string, models []agentTypes.ModelUsage) {
+	if len(models) == 0 {
+		return
+	}
+	if err := store.AppendUsage(sessionID, sessions.UsageLog{Time: time.Now(), Models: models}); err != nil {
+		slog.Warn("failed to write usage",
+			slog.String("error", err.Error()))
+	}
+}
+
+// * full tool outputs stay in session.Tools for the action log
+func compactMessages(session *agentTypes.AgentSession, budget int, events chan<- agentTypes.Event) {
+	messages, stats := compact(session.Messages, budget)
+	if !stats.changed() {
+		return
+	}
+	session.Messages = messages
+	events <- agentTypes.Event{Type: agentTypes.EventCompact, Text: stats.String()}
+}
+
 func getSystemPrompt(workDir string, skill *skill.Skill) string {
 	if skill == nil {
 		return strings.NewReplacer(
@@ -155,15 +216,7 @@ func getSystemPrompt(workDir string, skill *skill.Skill) string {
 			"{{.Content}}", "",
 		).Replace(systemPrompt)
 	}
-	content := skill.Content
-
-	for _, prefix := range []string{"scripts/", "templates/", "assets/"} {
-		resolved := filepath.Join(skill.Path, prefix)
-
-		if _, err := os.Stat(resolved); err == nil {
-			content = strings.ReplaceAll(content, prefix, resolved+string(filepath.Separator))
-		}
-	}
+	content := skill.Resolve(skill.Content)
 
 	return strings.NewReplacer(
 		"{{.WorkPath}}", workDir,
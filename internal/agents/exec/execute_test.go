@@ -0,0 +1,187 @@
+package exec_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/mock"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func lookup(q string) mock.Reply {
+	return mock.Reply{ToolCalls: []mock.ToolCall{{Name: "lookup", Arguments: json.RawMessage(fmt.Sprintf(`{"q":%q}`, q))}}}
+}
+
+func repeat(reply mock.Reply, n int) []mock.Reply {
+	replies := make([]mock.Reply, n)
+	for i := range replies {
+		replies[i] = reply
+	}
+	return replies
+}
+
+// * every event, the roles sent on each call and the stored summary, one line each
+func runGolden(t *testing.T, sk *skill.Skill, replies ...mock.Reply) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := t.TempDir()
+	configDir, err := utils.NewConfigDir(filepath.Join(home, "agenvoy"), utils.ProjectDir(workDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sessions.Close)
+
+	calls := 0
+	def := toolTypes.ToolDef{Type: "function"}
+	def.Function.Name = "lookup"
+	def.Function.Description = "look a word up"
+	def.Function.Parameters = json.RawMessage(`{"type":"object","properties":{"q":{"type":"string"}}}`)
+	// * no flags, calls run one at a time so the trace keeps its order
+	tool := toolTypes.NewTool(def, 0, func(ctx context.Context, _ *toolTypes.Executor, args json.RawMessage) (string, error) {
+		calls++
+		var params struct {
+			Q string `json:"q"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", err
+		}
+		return "found " + params.Q, nil
+	})
+
+	cfg := &exec.Config{WorkDir: workDir, ConfigDir: configDir, AllowAll: true, Tools: []toolTypes.Tool{tool}}
+	agent := mock.New(replies...)
+	events := make(chan agentTypes.Event, 1024)
+	runErr := exec.Execute(context.Background(), cfg, agent, sk, "what is a", events)
+	close(events)
+
+	var b strings.Builder
+	for ev := range events {
+		b.WriteString(formatEvent(ev))
+		b.WriteByte('\n')
+	}
+	if runErr != nil {
+		fmt.Fprintf(&b, "error: %v\n", runErr)
+	}
+	fmt.Fprintf(&b, "tool runs: %d, replies left: %d\n", calls, agent.Remaining())
+	for i, messages := range agent.Requests() {
+		fmt.Fprintf(&b, "request %d: %s\n", i+1, formatRoles(messages))
+	}
+
+	store, err := sessions.Open(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := sessions.Current(configDir, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary, err := store.Summary(id); err == nil && summary != nil {
+		fmt.Fprintf(&b, "summary: %s\n", summary)
+	}
+	return b.String()
+}
+
+// * long requests are counted per role instead of listed
+func formatRoles(messages []agentTypes.Message) string {
+	if len(messages) > 8 {
+		counts := make(map[string]int)
+		for _, m := range messages {
+			counts[m.Role]++
+		}
+		return fmt.Sprintf("%d messages, %d system, %d user, %d assistant, %d tool",
+			len(messages), counts["system"], counts["user"], counts["assistant"], counts["tool"])
+	}
+	roles := make([]string, len(messages))
+	for i, m := range messages {
+		roles[i] = m.Role
+		if len(m.ToolCalls) > 0 {
+			roles[i] += fmt.Sprintf("(%d calls)", len(m.ToolCalls))
+		}
+	}
+	return strings.Join(roles, " ")
+}
+
+func formatEvent(ev agentTypes.Event) string {
+	parts := []string{ev.Type.String()}
+	if ev.ToolName != "" {
+		parts = append(parts, ev.ToolName)
+	}
+	if ev.ToolArgs != "" {
+		parts = append(parts, ev.ToolArgs)
+	}
+	line := strings.Join(parts, " ")
+	if text := ev.Text + ev.Result; text != "" {
+		line += ": " + strings.ReplaceAll(text, "\n", `\n`)
+	}
+	for _, u := range ev.Usage {
+		line += fmt.Sprintf(" [%s %d/%d]", u.Model, u.Usage.InputTokens, u.Usage.OutputTokens)
+	}
+	return line
+}
+
+func TestExecuteGolden(t *testing.T) {
+	usage := &agentTypes.Usage{InputTokens: 100, OutputTokens: 10}
+	tests := []struct {
+		name    string
+		skill   *skill.Skill
+		replies []mock.Reply
+	}{
+		{"toolCall", nil, []mock.Reply{
+			{ToolCalls: lookup("a").ToolCalls, Usage: usage},
+			{Text: "a is the first letter.\n<!--SUMMARY_START-->\n{\"core_discussion\":\"letters\",\"confirmed_needs\":[\"meaning of a\"]}\n<!--SUMMARY_END-->", Usage: usage},
+		}},
+		{"dedupe", nil, []mock.Reply{
+			// * the same call twice in one turn runs once, and again in a later turn comes from the cache
+			{ToolCalls: append(append(lookup("a").ToolCalls, lookup("a").ToolCalls...), lookup("b").ToolCalls...)},
+			lookup("a"),
+			{Text: "a and b found."},
+		}},
+		{"iterationLimit", nil, append(repeat(lookup("a"), exec.MaxToolIterations), mock.Reply{Text: "summary of the lookups"})},
+		{"iterationLimitSummaryFails", nil, append(repeat(lookup("a"), exec.MaxToolIterations), mock.Reply{Status: 400, Text: "bad request"})},
+		{"emptyResponses", nil, repeat(mock.Reply{Empty: true}, 3)},
+		{"emptyRecovers", nil, append(repeat(mock.Reply{Empty: true}, 2), mock.Reply{Text: "ok"})},
+		{"nullContent", nil, []mock.Reply{{}}},
+		{"rejected", nil, []mock.Reply{{Status: 400, Text: "bad request"}}},
+		// * a stream cut after its text went out is neither retried nor failed over
+		{"partialStream", nil, []mock.Reply{{Partial: "a is the fir", Status: 503, Text: "connection reset"}, {Text: "a is the first letter."}}},
+		// * frontmatter limits: tools outside allowed-tools are gone and max-iterations ends the loop early
+		{"skillLimits", &skill.Skill{Name: "define", Content: "look words up", AllowedTools: []string{"lookup"}, MaxIterations: 2}, []mock.Reply{
+			{ToolCalls: []mock.ToolCall{{Name: "run_command", Arguments: json.RawMessage(`{"command":"ls"}`)}}},
+			lookup("a"),
+			{Text: "a is a letter"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runGolden(t, tt.skill, tt.replies...)
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run go test -update to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("trace differs from %s, run go test -update if intended\n--- got\n%s--- want\n%s", path, got, want)
+			}
+		})
+	}
+}
@@ -2,12 +2,16 @@ package exec
 
 import (
 	"encoding/json"
-	"os"
-	"path/filepath"
+	"log/slog"
 	"regexp"
 	"strings"
 
-	"github.com/pardnchiu/agenvoy/internal/utils"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+)
+
+const (
+	summaryStart = "<!--SUMMARY_START-->"
+	summaryEnd   = "<!--SUMMARY_END-->"
 )
 
 var (
@@ -27,10 +31,20 @@ func isSummaryJSON(m map[string]any) bool {
 	return matched >= 2
 }
 
-func extractSummary(configDir *utils.ConfigDirData, sessionID, value string) string {
-	const summaryStart = "<!--SUMMARY_START-->"
-	const summaryEnd = "<!--SUMMARY_END-->"
+// * start and content of a trailing markdown JSON block that looks like a summary
+func trailingSummary(value string) (int, map[string]any) {
+	loc := trailingJsonRegex.FindStringSubmatchIndex(value)
+	if loc == nil {
+		return -1, nil
+	}
+	var m map[string]any
+	if json.Unmarshal([]byte(value[loc[2]:loc[3]]), &m) != nil || !isSummaryJSON(m) {
+		return -1, nil
+	}
+	return loc[0], m
+}
 
+func extractSummary(store sessions.Store, sessionID, value string) string {
 	var jsonData any
 	var cleaned string
 
@@ -46,13 +60,9 @@ func extractSummary(configDir *utils.ConfigDirData, sessionID, value string) str
 			cleaned = strings.TrimRight(value[:start], " \t\n\r")
 		}
 		// Fallback: strip any trailing markdown JSON block that looks like a summary
-		if loc := trailingJsonRegex.FindStringSubmatchIndex(value); loc != nil {
-			jsonPart := value[loc[2]:loc[3]]
-			var m map[string]any
-			if json.Unmarshal([]byte(jsonPart), &m) == nil && isSummaryJSON(m) {
-				jsonData = m
-				cleaned = strings.TrimRight(value[:loc[0]], " \t\n\r")
-			}
+		if idx, m := trailingSummary(value); m != nil {
+			jsonData = m
+			cleaned = strings.TrimRight(value[:idx], " \t\n\r")
 		}
 		if cleaned == "" {
 			cleaned = value
@@ -60,10 +70,8 @@ func extractSummary(configDir *utils.ConfigDirData, sessionID, value string) str
 	}
 
 	if jsonData != nil {
-		path := filepath.Join(configDir.Home, sessionID, "summary.json")
-
 		if newMap, ok := jsonData.(map[string]any); ok {
-			if existing, err := os.ReadFile(path); err == nil {
+			if existing, err := store.Summary(sessionID); err == nil && existing != nil {
 				var oldMap map[string]any
 				if json.Unmarshal(existing, &oldMap) == nil {
 					newMap = mergeSummary(oldMap, newMap)
@@ -74,7 +82,10 @@ func extractSummary(configDir *utils.ConfigDirData, sessionID, value string) str
 
 		data, err := json.Marshal(jsonData)
 		if err == nil {
-			os.WriteFile(path, data, 0644)
+			if err := store.SetSummary(sessionID, data); err != nil {
+				slog.Warn("failed to write summary",
+					slog.String("error", err.Error()))
+			}
 		}
 	}
 	return cleaned
@@ -0,0 +1,108 @@
+package exec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * USD per million tokens, cache prices fall back to input when zero
+type Price struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+// * list prices of the billed APIs, first matching prefix of the registry name wins;
+// * copilot, nvidia and compat models are not billed per token and stay unpriced
+var defaultPrices = []struct {
+	prefix string
+	price  Price
+}{
+	{"claude@claude-opus-4-5", Price{5, 25, 0.5, 6.25}},
+	{"claude@claude-opus-4-6", Price{5, 25, 0.5, 6.25}},
+	{"claude@claude-opus-4", Price{15, 75, 1.5, 18.75}},
+	{"claude@claude-sonnet-4", Price{3, 15, 0.3, 3.75}},
+	{"claude@claude-haiku-4", Price{1, 5, 0.1, 1.25}},
+	{"claude@claude-3-5-haiku", Price{0.8, 4, 0.08, 1}},
+	{"openai@gpt-5-mini", Price{0.25, 2, 0.025, 0}},
+	{"openai@gpt-5-nano", Price{0.05, 0.4, 0.005, 0}},
+	{"openai@gpt-5", Price{1.25, 10, 0.125, 0}},
+	{"openai@gpt-4.1-mini", Price{0.4, 1.6, 0.1, 0}},
+	{"openai@gpt-4.1-nano", Price{0.1, 0.4, 0.025, 0}},
+	{"openai@gpt-4.1", Price{2, 8, 0.5, 0}},
+	{"openai@gpt-4o-mini", Price{0.15, 0.6, 0.075, 0}},
+	{"openai@gpt-4o", Price{2.5, 10, 1.25, 0}},
+	{"openai@o4-mini", Price{1.1, 4.4, 0.275, 0}},
+	{"openai@o3-mini", Price{1.1, 4.4, 0.55, 0}},
+	{"openai@o3", Price{2, 8, 0.5, 0}},
+	{"gemini@gemini-2.5-flash-lite", Price{0.1, 0.4, 0.025, 0}},
+	{"gemini@gemini-2.5-flash", Price{0.3, 2.5, 0.075, 0}},
+	{"gemini@gemini-2.5-pro", Price{1.25, 10, 0.31, 0}},
+}
+
+// * "prices" in config.json, keyed by registry name or model name, ex.
+// * {"claude@claude-sonnet-4-5": {"input": 3, "output": 15}, "compat[vllm]@": {"input": 0.1, "output": 0.1}};
+// * a key also matches as a prefix, the longest one wins
+func GetPrices(configDir *utils.ConfigDirData) map[string]Price {
+	for _, dir := range configDir.Dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+		if err != nil {
+			continue
+		}
+		var cfg struct {
+			Prices map[string]Price `json:"prices"`
+		}
+		if json.Unmarshal(data, &cfg) != nil || len(cfg.Prices) == 0 {
+			continue
+		}
+		return cfg.Prices
+	}
+	return nil
+}
+
+// * overrides first, then the list prices; false when the model has no price
+func LookupPrice(model string, overrides map[string]Price) (Price, bool) {
+	name := strings.ToLower(model)
+	bare := name
+	if at := strings.LastIndex(bare, "@"); at != -1 {
+		bare = bare[at+1:]
+	}
+
+	best, found := -1, Price{}
+	for key, price := range overrides {
+		key = strings.ToLower(key)
+		if len(key) > best && (strings.HasPrefix(name, key) || strings.HasPrefix(bare, key)) {
+			best, found = len(key), price
+		}
+	}
+	if best != -1 {
+		return found, true
+	}
+
+	for _, p := range defaultPrices {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.price, true
+		}
+	}
+	return Price{}, false
+}
+
+func (p Price) Cost(u agentTypes.Usage) float64 {
+	cacheRead, cacheWrite := p.CacheRead, p.CacheWrite
+	if cacheRead == 0 {
+		cacheRead = p.Input
+	}
+	if cacheWrite == 0 {
+		cacheWrite = p.Input
+	}
+	return (float64(u.InputTokens)*p.Input +
+		float64(u.OutputTokens)*p.Output +
+		float64(u.CacheReadTokens)*cacheRead +
+		float64(u.CacheWriteTokens)*cacheWrite) / 1e6
+}
@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+const (
+	// * attempts on one agent before moving down the chain
+	maxAttempts = 3
+	retryBase   = time.Second
+	// * a longer Retry-After moves to the next agent instead of waiting
+	maxRetryWait = 30 * time.Second
+)
+
+// * replaced in tests
+var wait = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// * a stream that failed after its text deltas went out; a retry or another agent would
+// * show the answer twice, so it is returned as is
+type partialError struct {
+	err error
+}
+
+func (e *partialError) Error() string {
+	return e.err.Error()
+}
+
+func (e *partialError) Unwrap() error {
+	return e.err
+}
+
+// * agents of one run, the first is the chosen one and the rest take over in order
+type agentChain struct {
+	names  []string
+	agents []agentTypes.Agent
+	index  int
+}
+
+func newAgentChain(agent agentTypes.Agent) *agentChain {
+	return &agentChain{names: []string{modelName(agent)}, agents: []agentTypes.Agent{agent}}
+}
+
+func registryChain(registry agentTypes.AgentRegistry, chosen string) *agentChain {
+	chain := &agentChain{}
+	for _, name := range registry.Chain(chosen) {
+		chain.names = append(chain.names, name)
+		chain.agents = append(chain.agents, registry.Registry[name])
+	}
+	if len(chain.agents) == 0 {
+		return newAgentChain(registry.Fallback)
+	}
+	return chain
+}
+
+func (c *agentChain) current() agentTypes.Agent {
+	return c.agents[c.index]
+}
+
+func (c *agentChain) name() string {
+	return c.names[c.index]
+}
+
+// * call runs fn on the current agent; retryable errors are tried again with backoff,
+// * errors another agent may not hit move the rest of the run to the next agent in the chain,
+// * a partialError ends the call right away
+func (c *agentChain) call(ctx context.Context, events chan<- agentTypes.Event, fn func(agent agentTypes.Agent) (*agentTypes.Output, error)) (*agentTypes.Output, error) {
+	for {
+		var perr *agentTypes.ProviderError
+		for attempt := 1; ; attempt++ {
+			resp, err := fn(c.current())
+			if err == nil {
+				return resp, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			var partial *partialError
+			if errors.As(err, &partial) {
+				return nil, agentTypes.NewProviderError(c.name(), partial.err)
+			}
+
+			perr = agentTypes.NewProviderError(c.name(), err)
+			if !perr.Retryable() || attempt >= maxAttempts {
+				break
+			}
+			delay := backoff(attempt, perr.RetryAfter)
+			if delay > maxRetryWait && c.index+1 < len(c.agents) {
+				break
+			}
+			events <- agentTypes.Event{
+				Type: agentTypes.EventRetry,
+				Text: fmt.Sprintf("%s %s, retrying in %s (%d/%d)", c.name(), perr.Kind, delay.Round(100*time.Millisecond), attempt, maxAttempts-1),
+			}
+			if err := wait(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+
+		if !perr.Failover() || c.index+1 >= len(c.agents) {
+			return nil, perr
+		}
+		from := c.name()
+		c.index++
+		events <- agentTypes.Event{
+			Type: agentTypes.EventFailover,
+			Text: fmt.Sprintf("%s %s, switching to %s", from, perr.Kind, c.name()),
+		}
+	}
+}
+
+// * Retry-After when given, otherwise retryBase doubled per attempt; plus up to half of it as jitter
+func backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := retryAfter
+	if delay <= 0 {
+		delay = retryBase << (attempt - 1)
+	}
+	return delay + rand.N(delay/2+1)
+}
+
+func modelName(agent agentTypes.Agent) string {
+	if namer, ok := agent.(agentTypes.ModelNamer); ok {
+		return namer.ModelName()
+	}
+	return "unknown"
+}
@@ -0,0 +1,250 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func stubWait(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	original := wait
+	wait = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() { wait = original })
+	return &waits
+}
+
+func statusError(code int, retryAfter time.Duration) error {
+	return fmt.Errorf("utils.POST: %w", &utils.HTTPError{StatusCode: code, RetryAfter: retryAfter})
+}
+
+// * fn fails with errs in order for each agent, then succeeds
+func scripted(errs map[string][]error, calls map[string]int) func(agentTypes.Agent) (*agentTypes.Output, error) {
+	return func(agent agentTypes.Agent) (*agentTypes.Output, error) {
+		name := modelName(agent)
+		n := calls[name]
+		calls[name]++
+		if n < len(errs[name]) {
+			return nil, errs[name][n]
+		}
+		return &agentTypes.Output{Choices: []agentTypes.OutputChoices{{}}}, nil
+	}
+}
+
+func drain(events chan agentTypes.Event) []agentTypes.Event {
+	close(events)
+	var list []agentTypes.Event
+	for ev := range events {
+		list = append(list, ev)
+	}
+	return list
+}
+
+func TestAgentChainRetry(t *testing.T) {
+	waits := stubWait(t)
+	events := make(chan agentTypes.Event, 16)
+	calls := map[string]int{}
+	chain := newAgentChain(namedAgent("claude@claude-sonnet-4-5"))
+
+	errs := map[string][]error{"claude@claude-sonnet-4-5": {statusError(529, 0), statusError(503, 2*time.Second)}}
+	if _, err := chain.call(context.Background(), events, scripted(errs, calls)); err != nil {
+		t.Fatal(err)
+	}
+	if calls["claude@claude-sonnet-4-5"] != 3 || len(*waits) != 2 {
+		t.Fatalf("calls = %v, waits = %v", calls, *waits)
+	}
+	// * exponential with up to half as jitter, Retry-After wins when given
+	if w := (*waits)[0]; w < time.Second || w > 1500*time.Millisecond {
+		t.Errorf("first wait = %s", w)
+	}
+	if w := (*waits)[1]; w < 2*time.Second || w > 3*time.Second {
+		t.Errorf("retry-after wait = %s", w)
+	}
+	if list := drain(events); len(list) != 2 || list[0].Type != agentTypes.EventRetry {
+		t.Errorf("events = %+v", list)
+	}
+}
+
+func TestAgentChainFailover(t *testing.T) {
+	waits := stubWait(t)
+	events := make(chan agentTypes.Event, 16)
+	calls := map[string]int{}
+	chain := &agentChain{
+		names:  []string{"claude@claude-sonnet-4-5", "openai@gpt-5-mini", "compat@qwen3:8b"},
+		agents: []agentTypes.Agent{namedAgent("claude@claude-sonnet-4-5"), namedAgent("openai@gpt-5-mini"), namedAgent("compat@qwen3:8b")},
+	}
+
+	errs := map[string][]error{
+		// * a minute long Retry-After is not worth waiting for
+		"claude@claude-sonnet-4-5": {statusError(429, time.Minute)},
+		// * retries run out, then the next agent takes over
+		"openai@gpt-5-mini": {statusError(500, 0), statusError(500, 0), statusError(500, 0)},
+	}
+	if _, err := chain.call(context.Background(), events, scripted(errs, calls)); err != nil {
+		t.Fatal(err)
+	}
+	if chain.name() != "compat@qwen3:8b" || calls["claude@claude-sonnet-4-5"] != 1 || calls["openai@gpt-5-mini"] != 3 || len(*waits) != 2 {
+		t.Fatalf("current = %s, calls = %v, waits = %v", chain.name(), calls, *waits)
+	}
+	var switches []string
+	for _, ev := range drain(events) {
+		if ev.Type == agentTypes.EventFailover {
+			switches = append(switches, ev.Text)
+		}
+	}
+	if len(switches) != 2 || switches[0] != "claude@claude-sonnet-4-5 rate limited, switching to openai@gpt-5-mini" {
+		t.Errorf("switches = %v", switches)
+	}
+
+	// * later calls of the run stay on the agent that took over
+	if _, err := chain.call(context.Background(), make(chan agentTypes.Event, 1), scripted(nil, calls)); err != nil || calls["compat@qwen3:8b"] != 2 {
+		t.Errorf("calls = %v, %v", calls, err)
+	}
+}
+
+func TestAgentChainGivesUp(t *testing.T) {
+	stubWait(t)
+	calls := map[string]int{}
+	chain := &agentChain{
+		names:  []string{"claude@claude-sonnet-4-5", "openai@gpt-5-mini"},
+		agents: []agentTypes.Agent{namedAgent("claude@claude-sonnet-4-5"), namedAgent("openai@gpt-5-mini")},
+	}
+
+	// * a rejected request fails the same way everywhere
+	errs := map[string][]error{"claude@claude-sonnet-4-5": {statusError(400, 0)}}
+	_, err := chain.call(context.Background(), make(chan agentTypes.Event, 16), scripted(errs, calls))
+	var perr *agentTypes.ProviderError
+	if !errors.As(err, &perr) || perr.Kind != agentTypes.ErrRequest || perr.StatusCode != 400 || calls["openai@gpt-5-mini"] != 0 {
+		t.Fatalf("err = %v, calls = %v", err, calls)
+	}
+
+	// * the last agent out of retries returns its error
+	errs = map[string][]error{"openai@gpt-5-mini": {statusError(503, 0), statusError(503, 0), statusError(503, 0)}}
+	chain.index = 1
+	_, err = chain.call(context.Background(), make(chan agentTypes.Event, 16), scripted(errs, map[string]int{}))
+	if !errors.As(err, &perr) || perr.Kind != agentTypes.ErrOverloaded {
+		t.Fatalf("err = %v", err)
+	}
+
+	// * a cancelled run stops without retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = map[string]int{}
+	errs = map[string][]error{"openai@gpt-5-mini": {context.Canceled}}
+	if _, err := chain.call(ctx, make(chan agentTypes.Event, 16), scripted(errs, calls)); !errors.Is(err, context.Canceled) || calls["openai@gpt-5-mini"] != 1 {
+		t.Errorf("err = %v, calls = %v", err, calls)
+	}
+}
+
+func TestAgentChainPartial(t *testing.T) {
+	waits := stubWait(t)
+	calls := map[string]int{}
+	chain := &agentChain{
+		names:  []string{"claude@claude-sonnet-4-5", "openai@gpt-5-mini"},
+		agents: []agentTypes.Agent{namedAgent("claude@claude-sonnet-4-5"), namedAgent("openai@gpt-5-mini")},
+	}
+
+	// * text already went out, so even an overloaded server is not tried again
+	errs := map[string][]error{"claude@claude-sonnet-4-5": {&partialError{err: statusError(529, 0)}}}
+	_, err := chain.call(context.Background(), make(chan agentTypes.Event, 16), scripted(errs, calls))
+	var perr *agentTypes.ProviderError
+	if !errors.As(err, &perr) || perr.Kind != agentTypes.ErrOverloaded || len(*waits) != 0 || chain.index != 0 || calls["openai@gpt-5-mini"] != 0 {
+		t.Fatalf("err = %v, calls = %v, waits = %v", err, calls, *waits)
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"slow down"}}`))
+	}))
+	defer srv.Close()
+
+	// * non-2xx is an error, not an empty result
+	_, code, err := utils.POST[agentTypes.Output](context.Background(), nil, srv.URL, nil, map[string]any{}, "json")
+	perr := agentTypes.NewProviderError("openai@gpt-5-mini", fmt.Errorf("utils.POST: %w", err))
+	if code != 429 || perr.Kind != agentTypes.ErrRateLimit || perr.RetryAfter != 7*time.Second || !perr.Retryable() {
+		t.Fatalf("code = %d, err = %+v", code, perr)
+	}
+	if want := `openai@gpt-5-mini rate limited: utils.POST: status 429: {"error":{"message":"slow down"}}`; perr.Error() != want {
+		t.Errorf("Error() = %s", perr.Error())
+	}
+
+	// * GET fails the same way
+	_, code, err = utils.GET[agentTypes.Output](context.Background(), nil, srv.URL, nil)
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) || code != 429 || httpErr.RetryAfter != 7*time.Second {
+		t.Errorf("GET code = %d, err = %v", code, err)
+	}
+
+	tests := []struct {
+		err  error
+		kind agentTypes.ErrorKind
+	}{
+		{statusError(401, 0), agentTypes.ErrAuth},
+		{statusError(404, 0), agentTypes.ErrRequest},
+		{statusError(502, 0), agentTypes.ErrOverloaded},
+		{fmt.Errorf("utils.POSTStream: %w", context.DeadlineExceeded), agentTypes.ErrTimeout},
+		{errors.New("json.Unmarshal: bad"), agentTypes.ErrOther},
+	}
+	for _, tt := range tests {
+		if got := agentTypes.NewProviderError("m", tt.err).Kind; got != tt.kind {
+			t.Errorf("%v: kind = %s, want %s", tt.err, got, tt.kind)
+		}
+	}
+
+	now := time.Date(2026, 10, 17, 7, 28, 0, 0, time.UTC)
+	if got := utils.ParseRetryAfter("Sat, 17 Oct 2026 07:28:30 GMT", now); got != 30*time.Second {
+		t.Errorf("http date = %s", got)
+	}
+}
+
+func TestRegistryChain(t *testing.T) {
+	registry := agentTypes.AgentRegistry{
+		Registry: map[string]agentTypes.Agent{
+			"claude@claude-sonnet-4-5": namedAgent("claude@claude-sonnet-4-5"),
+			"openai@gpt-5-mini":        namedAgent("openai@gpt-5-mini"),
+			"compat@qwen3:8b":          namedAgent("compat@qwen3:8b"),
+		},
+		Entries: []agentTypes.AgentEntry{
+			{Name: "claude@claude-sonnet-4-5"},
+			{Name: "openai@gpt-5-mini"},
+			{Name: "compat@qwen3:8b"},
+		},
+		Fallback: namedAgent("claude@claude-sonnet-4-5"),
+	}
+	tests := []struct {
+		failover []string
+		chosen   string
+		want     string
+	}{
+		{nil, "openai@gpt-5-mini", "[openai@gpt-5-mini claude@claude-sonnet-4-5 compat@qwen3:8b]"},
+		{nil, "", "[claude@claude-sonnet-4-5 openai@gpt-5-mini compat@qwen3:8b]"},
+		{[]string{}, "compat@qwen3:8b", "[compat@qwen3:8b]"},
+		{[]string{"gone@model", "compat@qwen3:8b"}, "unknown", "[compat@qwen3:8b]"},
+	}
+	for _, tt := range tests {
+		registry.Failover = tt.failover
+		if got := fmt.Sprint(registryChain(registry, tt.chosen).names); got != tt.want {
+			t.Errorf("chain(%v, %q) = %s", tt.failover, tt.chosen, got)
+		}
+	}
+
+	// * nothing known falls back to the selector agent
+	registry.Failover = []string{}
+	if chain := registryChain(registry, "unknown"); chain.name() != "claude@claude-sonnet-4-5" {
+		t.Errorf("fallback chain = %v", chain.names)
+	}
+}
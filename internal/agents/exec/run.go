@@ -3,25 +3,81 @@ package exec
 import (
 	"context"
 	"fmt"
-	"os"
+	"log/slog"
 	"strings"
+	"sync"
 
+	"github.com/pardnchiu/agenvoy/internal/agents/router"
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 	"github.com/pardnchiu/agenvoy/internal/skill"
 )
 
-func Run(ctx context.Context, bot agentTypes.Agent, registry agentTypes.AgentRegistry, scanner *skill.Scanner, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
-	workDir, err := os.Getwd()
+func Run(ctx context.Context, cfg *Config, bot agentTypes.Agent, registry agentTypes.AgentRegistry, scanner *skill.Scanner, userInput string, events chan<- agentTypes.Event) error {
+	trimInput := strings.TrimSpace(userInput)
+	usage := newUsageMeter()
+
+	// * overrides are checked before any selector call is paid for
+	var matchedSkill *skill.Skill
+	if name := strings.TrimSpace(cfg.Skill); name != "" {
+		matchedSkill = scanner.Get(name)
+		if matchedSkill == nil {
+			return fmt.Errorf("exec.Run: skill %q not found", name)
+		}
+	}
+	chosen := strings.TrimSpace(cfg.Agent)
+	if chosen != "" {
+		if _, ok := registry.Registry[chosen]; !ok {
+			return fmt.Errorf("exec.Run: agent %q not found", chosen)
+		}
+	}
+
+	// * routes.json rules come next, a name missing from this setup is left to the selector
+	rules, err := router.LoadRules(cfg.ConfigDir)
 	if err != nil {
-		return fmt.Errorf("os.Getwd: %w", err)
+		slog.Warn("failed to load routes", slog.String("error", err.Error()))
+	}
+	route := rules.Match(trimInput, cfg.WorkDir)
+	if matchedSkill == nil && route.Skill != "" {
+		if matchedSkill = scanner.Get(route.Skill); matchedSkill == nil {
+			slog.Warn("failed to route skill", slog.String("name", route.Skill), slog.String("error", "skill not found"))
+		}
+	}
+	if chosen == "" && route.Agent != "" {
+		if _, ok := registry.Registry[route.Agent]; ok {
+			chosen = route.Agent
+		} else {
+			slog.Warn("failed to route agent", slog.String("name", route.Agent), slog.String("error", "agent not found"))
+		}
 	}
 
-	trimInput := strings.TrimSpace(userInput)
+	// * a skill known ahead that names its model needs no agent selector
+	agentFixed := chosen != ""
+	if !agentFixed && matchedSkill != nil {
+		chosen = skillAgent(matchedSkill, registry, route.AllowAgent)
+	}
+
+	// * both selectors run at once, events keep the skill then agent order
+	var skillDone, agentDone sync.WaitGroup
+	if matchedSkill == nil {
+		skillDone.Add(1)
+		go func() {
+			defer skillDone.Done()
+			matchedSkill = selectSkill(ctx, bot, cfg.Embedding, scanner, route.AllowSkill, trimInput, usage)
+		}()
+	}
+	if chosen == "" {
+		// * default is fallback
+		agentDone.Add(1)
+		go func() {
+			defer agentDone.Done()
+			chosen = selectAgent(ctx, bot, cfg.Embedding, registry.Entries, route.AllowAgent, trimInput, usage)
+		}()
+	}
 
 	events <- agentTypes.Event{
 		Type: agentTypes.EventSkillSelect,
 	}
-	matchedSkill := selectSkill(ctx, bot, scanner, trimInput)
+	skillDone.Wait()
 	if matchedSkill != nil {
 		events <- agentTypes.Event{
 			Type: agentTypes.EventSkillResult,
@@ -37,12 +93,14 @@ func Run(ctx context.Context, bot agentTypes.Agent, registry agentTypes.AgentReg
 	events <- agentTypes.Event{
 		Type: agentTypes.EventAgentSelect,
 	}
-	// * default is fallback
-	agent := registry.Fallback
-	if chosen := selectAgent(ctx, bot, registry.Entries, trimInput); chosen != "" {
-		if a, ok := registry.Registry[chosen]; ok {
-			agent = a
+	agentDone.Wait()
+	// * the model of a selected skill wins over the agent selector
+	if !agentFixed {
+		if name := skillAgent(matchedSkill, registry, route.AllowAgent); name != "" {
+			chosen = name
 		}
+	}
+	if chosen != "" {
 		events <- agentTypes.Event{
 			Type: agentTypes.EventAgentResult,
 			Text: strings.TrimSpace(chosen),
@@ -54,5 +112,21 @@ func Run(ctx context.Context, bot agentTypes.Agent, registry agentTypes.AgentReg
 		}
 	}
 
-	return Execute(ctx, agent, workDir, matchedSkill, trimInput, events, allowAll)
+	return execute(ctx, cfg, registryChain(registry, chosen), matchedSkill, trimInput, events, usage)
+}
+
+// * model from the skill frontmatter, a registry name or the model part of one, ex. "claude-sonnet-4-5"
+func skillAgent(s *skill.Skill, registry agentTypes.AgentRegistry, allow func(name string) bool) string {
+	if s == nil || s.Model == "" {
+		return ""
+	}
+	if _, ok := registry.Registry[s.Model]; ok && allow(s.Model) {
+		return s.Model
+	}
+	for _, entry := range registry.Entries {
+		if strings.HasSuffix(entry.Name, "@"+s.Model) && allow(entry.Name) {
+			return entry.Name
+		}
+	}
+	return ""
 }
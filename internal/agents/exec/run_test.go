@@ -0,0 +1,254 @@
+package exec_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	"github.com/pardnchiu/agenvoy/internal/agents/provider/mock"
+	"github.com/pardnchiu/agenvoy/internal/agents/router"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * answers the skill and agent selectors, each call waits until the other one has started
+type selector struct {
+	skill   string
+	agent   string
+	mu      sync.Mutex
+	calls   []string
+	arrived chan struct{} // * closed by the second call
+}
+
+func newSelector() *selector {
+	return &selector{skill: "review", agent: "mock@b", arrived: make(chan struct{})}
+}
+
+func (s *selector) Send(ctx context.Context, messages []agentTypes.Message, _ []toolTypes.ToolDef) (*agentTypes.Output, error) {
+	kind, answer := "agent", s.agent
+	if strings.HasPrefix(messages[len(messages)-1].Content.(string), "Available skills") {
+		kind, answer = "skill", s.skill
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, kind)
+	if len(s.calls) == 2 {
+		close(s.arrived)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.arrived:
+	case <-time.After(2 * time.Second):
+		s.mu.Lock()
+		s.calls = append(s.calls, "timeout")
+		s.mu.Unlock()
+		answer = "NONE"
+	}
+	return &agentTypes.Output{Choices: []agentTypes.OutputChoices{{Message: agentTypes.Message{Role: "assistant", Content: answer}}}}, nil
+}
+
+func (s *selector) Stream(ctx context.Context, messages []agentTypes.Message, toolDefs []toolTypes.ToolDef, _ func(string)) (*agentTypes.Output, error) {
+	return s.Send(ctx, messages, toolDefs)
+}
+
+func (s *selector) Execute(context.Context, *skill.Skill, string, chan<- agentTypes.Event, bool) error {
+	return nil
+}
+
+func runSelect(t *testing.T, cfg *exec.Config, bot agentTypes.Agent, input, routes string) (map[string]*mock.Agent, []agentTypes.Event, error) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := t.TempDir()
+	configDir, err := utils.NewConfigDir(filepath.Join(home, "agenvoy"), utils.ProjectDir(workDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sessions.Close)
+	cfg.WorkDir, cfg.ConfigDir, cfg.AllowAll = workDir, configDir, true
+	if routes != "" {
+		if err := os.WriteFile(filepath.Join(configDir.Work, "routes.json"), []byte(routes), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	agents := map[string]*mock.Agent{
+		"mock@a": mock.New(mock.Reply{Text: "from a"}),
+		"mock@b": mock.New(mock.Reply{Text: "from b"}),
+	}
+	registry := agentTypes.AgentRegistry{
+		Registry: map[string]agentTypes.Agent{"mock@a": agents["mock@a"], "mock@b": agents["mock@b"]},
+		Entries:  []agentTypes.AgentEntry{{Name: "mock@a", Description: "general questions"}, {Name: "mock@b", Description: "code review and refactoring"}},
+		Fallback: agents["mock@a"],
+		Failover: []string{},
+	}
+	scanner := skill.NewScannerWithPaths(t.TempDir())
+	scanner.Add(
+		&skill.Skill{Name: "review", Description: "review code", Content: "review the code"},
+		&skill.Skill{Name: "commit", Description: "write a commit", Content: "write a commit message"},
+		&skill.Skill{Name: "deploy", Description: "ship the service", Content: "deploy it", Model: "b", MaxIterations: 2, AllowedTools: []string{"Read"}},
+	)
+
+	events := make(chan agentTypes.Event, 64)
+	runErr := exec.Run(context.Background(), cfg, bot, registry, scanner, input, events)
+	close(events)
+	var list []agentTypes.Event
+	for ev := range events {
+		list = append(list, ev)
+	}
+	return agents, list, runErr
+}
+
+func selection(events []agentTypes.Event) []string {
+	var list []string
+	for _, ev := range events {
+		switch ev.Type {
+		case agentTypes.EventSkillSelect, agentTypes.EventAgentSelect:
+			list = append(list, ev.Type.String())
+		case agentTypes.EventSkillResult, agentTypes.EventAgentResult:
+			list = append(list, ev.Type.String()+" "+ev.Text)
+		}
+	}
+	return list
+}
+
+func TestRunSelectsConcurrently(t *testing.T) {
+	bot := newSelector()
+	agents, events, err := runSelect(t, &exec.Config{}, bot, "check main.go", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// * a serial run would time out waiting for the second selector call
+	if len(bot.calls) != 2 || slices.Contains(bot.calls, "timeout") {
+		t.Fatalf("selector calls = %v", bot.calls)
+	}
+	want := "skill_select,skill_result review,agent_select,agent_result mock@b"
+	if got := strings.Join(selection(events), ","); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if agents["mock@b"].Remaining() != 0 || agents["mock@a"].Remaining() != 1 {
+		t.Errorf("the chosen agent did not run")
+	}
+	if system := agents["mock@b"].Requests()[0][0].Content.(string); !strings.Contains(system, "review the code") {
+		t.Errorf("skill not in the prompt: %s", system)
+	}
+}
+
+func TestRunOverrides(t *testing.T) {
+	bot := mock.New()
+	agents, events, err := runSelect(t, &exec.Config{Skill: "commit", Agent: "mock@a"}, bot, "check main.go", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// * both given, no selector call at all
+	if len(bot.Requests()) != 0 {
+		t.Fatalf("selector called %d times", len(bot.Requests()))
+	}
+	want := "skill_select,skill_result commit,agent_select,agent_result mock@a"
+	if got := strings.Join(selection(events), ","); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if agents["mock@a"].Remaining() != 0 {
+		t.Errorf("the given agent did not run")
+	}
+
+	for _, cfg := range []*exec.Config{{Skill: "missing"}, {Agent: "mock@missing"}} {
+		if _, _, err := runSelect(t, cfg, bot, "check main.go", ""); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("%+v: err = %v", cfg, err)
+		}
+	}
+}
+
+func TestRunEmbedding(t *testing.T) {
+	// * a clear match on both sides never reaches the selector
+	bot := mock.New()
+	cfg := &exec.Config{Embedding: router.NewEmbedding(mock.New(), router.EmbeddingConfig{Model: "mock@embed"}, t.TempDir())}
+	agents, events, err := runSelect(t, cfg, bot, "review the code in main.go", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bot.Requests()) != 0 {
+		t.Fatalf("selector called %d times", len(bot.Requests()))
+	}
+	want := "skill_select,skill_result review,agent_select,agent_result mock@b"
+	if got := strings.Join(selection(events), ","); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if agents["mock@b"].Remaining() != 0 {
+		t.Errorf("the matched agent did not run")
+	}
+}
+
+func TestRunRoutes(t *testing.T) {
+	routes := `{"routes": [
+		{"regex": "^/commit", "skill": "commit", "agent": "mock@b"},
+		{"keywords": ["main.go"], "never_skills": ["review"]}
+	]}`
+
+	// * a rule decides both, no selector call
+	bot := mock.New()
+	agents, events, err := runSelect(t, &exec.Config{}, bot, "/commit the staged files", routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bot.Requests()) != 0 {
+		t.Fatalf("selector called %d times", len(bot.Requests()))
+	}
+	want := "skill_select,skill_result commit,agent_select,agent_result mock@b"
+	if got := strings.Join(selection(events), ","); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if agents["mock@b"].Remaining() != 0 {
+		t.Errorf("the routed agent did not run")
+	}
+
+	// * no rule picks, the selectors run without the skill routed away
+	bot = mock.New(mock.Reply{Text: "commit"}, mock.Reply{Text: "NONE"})
+	if _, _, err := runSelect(t, &exec.Config{}, bot, "check main.go", routes); err != nil {
+		t.Fatal(err)
+	}
+	if requests := bot.Requests(); len(requests) != 2 {
+		t.Fatalf("selector called %d times", len(requests))
+	}
+	for _, messages := range bot.Requests() {
+		prompt := messages[len(messages)-1].Content.(string)
+		if strings.HasPrefix(prompt, "Available skills") && (strings.Contains(prompt, "review") || !strings.Contains(prompt, "commit")) {
+			t.Errorf("skill prompt = %s", prompt)
+		}
+	}
+}
+
+func TestRunSkillModel(t *testing.T) {
+	// * the skill's model wins over the agent selector
+	bot := newSelector()
+	bot.skill, bot.agent = "deploy", "mock@a"
+	agents, events, err := runSelect(t, &exec.Config{}, bot, "ship it", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "skill_select,skill_result deploy,agent_select,agent_result mock@b"
+	if got := strings.Join(selection(events), ","); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if agents["mock@b"].Remaining() != 0 {
+		t.Errorf("the skill's agent did not run")
+	}
+
+	// * a skill given ahead skips the agent selector, an explicit agent still wins
+	none := mock.New()
+	if _, events, err := runSelect(t, &exec.Config{Skill: "deploy"}, none, "ship it", ""); err != nil || len(none.Requests()) != 0 || selection(events)[3] != "agent_result mock@b" {
+		t.Errorf("skill given: %v, %d selector calls, %v", err, len(none.Requests()), selection(events))
+	}
+	if _, events, err := runSelect(t, &exec.Config{Skill: "deploy", Agent: "mock@a"}, none, "ship it", ""); err != nil || selection(events)[3] != "agent_result mock@a" {
+		t.Errorf("agent given: %v, %v", err, selection(events))
+	}
+}
@@ -5,10 +5,13 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/pardnchiu/agenvoy/internal/agents/router"
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 	"github.com/pardnchiu/agenvoy/internal/utils"
 )
@@ -16,12 +19,7 @@ import (
 //go:embed prompt/agentSelector.md
 var agentSelectorPrompt string
 
-func GetAgentEntries() []agentTypes.AgentEntry {
-	configDir, err := utils.GetConfigDir()
-	if err != nil {
-		return []agentTypes.AgentEntry{}
-	}
-
+func GetAgentEntries(configDir *utils.ConfigDirData) []agentTypes.AgentEntry {
 	for _, dir := range configDir.Dirs {
 		data, err := os.ReadFile(filepath.Join(dir, "config.json"))
 		if err != nil {
@@ -48,13 +46,81 @@ func GetAgentEntries() []agentTypes.AgentEntry {
 	return []agentTypes.AgentEntry{}
 }
 
-func selectAgent(ctx context.Context, bot agentTypes.Agent, agentEntries []agentTypes.AgentEntry, userInput string) string {
+// * "failover" in config.json, agent names tried in order when the chosen one fails, ex.
+// * ["claude@claude-sonnet-4-5", "openai@gpt-5-mini"]; nil when unset, so models order is used
+func GetFailover(configDir *utils.ConfigDirData) []string {
+	for _, dir := range configDir.Dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+		if err != nil {
+			continue
+		}
+		var cfg struct {
+			Failover []string `json:"failover"`
+		}
+		if json.Unmarshal(data, &cfg) != nil || cfg.Failover == nil {
+			continue
+		}
+		return cfg.Failover
+	}
+	return nil
+}
+
+// * "embedding" in config.json, nil when unset
+func GetEmbedding(configDir *utils.ConfigDirData) *router.EmbeddingConfig {
+	for _, dir := range configDir.Dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+		if err != nil {
+			continue
+		}
+		var cfg struct {
+			Embedding *router.EmbeddingConfig `json:"embedding"`
+		}
+		if json.Unmarshal(data, &cfg) != nil || cfg.Embedding == nil || cfg.Embedding.Model == "" {
+			continue
+		}
+		return cfg.Embedding
+	}
+	return nil
+}
+
+func selectAgent(ctx context.Context, bot agentTypes.Agent, embedding *router.Embedding, agentEntries []agentTypes.AgentEntry, allow func(name string) bool, userInput string, usage *usageMeter) string {
 	trimInput := strings.TrimSpace(userInput)
 
+	// * never_agents routes are left out, the fallback still runs when nothing is picked
+	agentEntries = slices.DeleteFunc(slices.Clone(agentEntries), func(a agentTypes.AgentEntry) bool {
+		return !allow(a.Name)
+	})
 	if len(agentEntries) == 0 {
 		return ""
 	}
 
+	// * same as skills, no match falls back to the default agent
+	if embedding != nil {
+		candidates := make([]router.Candidate, len(agentEntries))
+		for i, a := range agentEntries {
+			candidates[i] = router.Candidate{Name: a.Name, Text: strings.TrimSpace(a.Description)}
+		}
+		matches, err := embedding.Shortlist(ctx, "agents", trimInput, candidates)
+		if err != nil {
+			slog.Warn("failed to shortlist agents", slog.String("error", err.Error()))
+		} else {
+			switch len(matches) {
+			case 0:
+				return ""
+			case 1:
+				return matches[0].Name
+			}
+			byName := make(map[string]agentTypes.AgentEntry, len(agentEntries))
+			for _, a := range agentEntries {
+				byName[a.Name] = a
+			}
+			agentEntries = make([]agentTypes.AgentEntry, 0, len(matches))
+			for _, m := range matches {
+				agentEntries = append(agentEntries, byName[m.Name])
+			}
+		}
+	}
+
 	agentMap := make(map[string]struct{}, len(agentEntries))
 	for _, a := range agentEntries {
 		agentMap[a.Name] = struct{}{}
@@ -81,7 +147,11 @@ func selectAgent(ctx context.Context, bot agentTypes.Agent, agentEntries []agent
 	}
 
 	resp, err := bot.Send(ctx, messages, nil)
-	if err != nil || len(resp.Choices) == 0 {
+	if err != nil {
+		return ""
+	}
+	usage.add(bot, resp)
+	if len(resp.Choices) == 0 {
 		return ""
 	}
 
@@ -5,8 +5,10 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
 
+	"github.com/pardnchiu/agenvoy/internal/agents/router"
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 	"github.com/pardnchiu/agenvoy/internal/skill"
 )
@@ -14,18 +16,49 @@ import (
 //go:embed prompt/skillSelector.md
 var skillSelectorPrompt string
 
-func selectSkill(ctx context.Context, bot agentTypes.Agent, scanner *skill.Scanner, userInput string) *skill.Skill {
+func selectSkill(ctx context.Context, bot agentTypes.Agent, embedding *router.Embedding, scanner *skill.Scanner, allow func(name string) bool, userInput string, usage *usageMeter) *skill.Skill {
 	trimInput := strings.TrimSpace(userInput)
 
-	skills := scanner.List()
+	// * one snapshot for the whole selection, a reload meanwhile does not change it
+	list := scanner.Snapshot()
+	// * never_skills routes are left out
+	skills := make([]string, 0, len(list.ByName))
+	for name := range list.ByName {
+		if allow(name) {
+			skills = append(skills, name)
+		}
+	}
 	if len(skills) == 0 {
 		return nil
 	}
 
+	// * with embeddings only a close tie is left to the LLM; on failure every skill is
+	if embedding != nil {
+		candidates := make([]router.Candidate, len(skills))
+		for i, name := range skills {
+			s := list.ByName[name]
+			candidates[i] = router.Candidate{Name: name, Text: strings.TrimSpace(s.Description), Hash: s.Hash}
+		}
+		matches, err := embedding.Shortlist(ctx, "skills", trimInput, candidates)
+		if err != nil {
+			slog.Warn("failed to shortlist skills", slog.String("error", err.Error()))
+		} else {
+			switch len(matches) {
+			case 0:
+				return nil
+			case 1:
+				return list.ByName[matches[0].Name]
+			}
+			skills = skills[:0]
+			for _, m := range matches {
+				skills = append(skills, m.Name)
+			}
+		}
+	}
+
 	skillMap := make(map[string]string, len(skills))
 	for _, name := range skills {
-		// * already checked List() will output trimmed skill name
-		skillMap[name] = strings.TrimSpace(scanner.Skills.ByName[name].Description)
+		skillMap[name] = strings.TrimSpace(list.ByName[name].Description)
 	}
 	skillJson, err := json.Marshal(skillMap)
 	if err != nil {
@@ -48,7 +81,11 @@ func selectSkill(ctx context.Context, bot agentTypes.Agent, scanner *skill.Scann
 	}
 
 	resp, err := bot.Send(ctx, messages, nil)
-	if err != nil || len(resp.Choices) == 0 {
+	if err != nil {
+		return nil
+	}
+	usage.add(bot, resp)
+	if len(resp.Choices) == 0 {
 		return nil
 	}
 
@@ -59,8 +96,8 @@ func selectSkill(ctx context.Context, bot agentTypes.Agent, scanner *skill.Scann
 
 	if answer == "NONE" || answer == "" {
 		return nil
-	} else if s, ok := scanner.Skills.ByName[answer]; ok {
-		return s
+	} else if _, ok := skillMap[answer]; ok {
+		return list.ByName[answer]
 	}
 
 	return nil
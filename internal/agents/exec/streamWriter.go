@@ -0,0 +1,189 @@
+package exec
+
+import (
+	"regexp"
+	"strings"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+var (
+	summaryHeadingRegex = regexp.MustCompile(`^\*{0,2}[^\n*]*[Ss]ummary[^\n*]*\*{0,2}\s*$`)
+)
+
+// * forward streamed text as EventTextDelta, stop at the summary block;
+// * a trailing fenced summary json is held back until the stream ends
+type streamWriter struct {
+	events    chan<- agentTypes.Event
+	pending   string
+	lineStart bool // * pending begins at the start of a line
+	stopped   bool
+	emitted   bool // * a delta went out since the last flush
+}
+
+func newStreamWriter(events chan<- agentTypes.Event) *streamWriter {
+	return &streamWriter{events: events, lineStart: true}
+}
+
+func (w *streamWriter) write(text string) {
+	if w.stopped || text == "" {
+		return
+	}
+	w.pending += text
+
+	if idx := strings.Index(w.pending, summaryStart); idx != -1 {
+		w.emit(strings.TrimRight(w.pending[:idx], " \t\n\r"))
+		w.pending = ""
+		w.stopped = true
+		return
+	}
+
+	// * hold back tail which may be the beginning of summary marker or a summary block
+	keep := max(partialPrefix(w.pending, summaryStart), len(w.pending)-w.holdFrom())
+	w.release(len(w.pending) - keep)
+}
+
+// * reports whether any delta of this stream went out, the text cannot be taken back then
+func (w *streamWriter) flush() bool {
+	if !w.stopped {
+		text := w.pending
+		if idx, m := trailingSummary(text); m != nil {
+			text = strings.TrimRight(text[:idx], " \t\n\r")
+		}
+		w.emit(text)
+	}
+	emitted := w.emitted
+	w.pending = ""
+	w.lineStart = true
+	w.stopped = false
+	w.emitted = false
+	return emitted
+}
+
+// * emit pending[:n] and keep the rest
+func (w *streamWriter) release(n int) {
+	if n == 0 {
+		return
+	}
+	w.emit(w.pending[:n])
+	w.lineStart = w.pending[n-1] == '\n'
+	w.pending = w.pending[n:]
+}
+
+// * first line start in pending from which the rest may still be a trailing summary block
+func (w *streamWriter) holdFrom() int {
+	if w.lineStart && summaryTail(w.pending) {
+		return 0
+	}
+	for i := 0; i < len(w.pending); i++ {
+		if w.pending[i] == '\n' && summaryTail(w.pending[i+1:]) {
+			return i + 1
+		}
+	}
+	return len(w.pending)
+}
+
+func (w *streamWriter) emit(text string) {
+	if text == "" {
+		return
+	}
+	w.emitted = true
+	w.events <- agentTypes.Event{
+		Type: agentTypes.EventTextDelta,
+		Text: text,
+	}
+}
+
+// * whether s, starting at a line start, may still be what trailingJsonRegex strips:
+// * an optional --- and summary heading, then a fenced summary json and nothing after it
+func summaryTail(s string) bool {
+	const (
+		divider = iota
+		heading
+		fence
+		body
+		closed
+	)
+	lines := strings.Split(s, "\n")
+	partial := lines[len(lines)-1]
+	stage := divider
+	var content strings.Builder
+
+	for _, line := range lines[:len(lines)-1] {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case stage == body:
+			if trimmed == "```" {
+				if !isSummaryBody(content.String()) {
+					return false
+				}
+				stage = closed
+				continue
+			}
+			content.WriteString(line + "\n")
+			if !jsonStart(content.String()) {
+				return false
+			}
+		case trimmed == "":
+			continue
+		case stage == closed:
+			return false
+		case stage == divider && trimmed == "---":
+			stage = heading
+		case stage <= heading && summaryHeadingRegex.MatchString(line):
+			stage = fence
+		case strings.HasPrefix(trimmed, "```"):
+			rest := strings.TrimPrefix(strings.TrimPrefix(trimmed, "```"), "json")
+			if rest != "" && !strings.HasPrefix(rest, "{") {
+				return false
+			}
+			content.WriteString(rest)
+			stage = body
+		default:
+			return false
+		}
+	}
+
+	trimmed := strings.TrimSpace(partial)
+	switch {
+	case stage == body:
+		content.WriteString(partial)
+		return jsonStart(content.String())
+	case trimmed == "":
+		return true
+	case stage == closed:
+		return false
+	case strings.HasPrefix(trimmed, "```"):
+		rest := strings.TrimPrefix(strings.TrimPrefix(trimmed, "```"), "json")
+		return rest == "" || strings.HasPrefix(rest, "{") || strings.HasPrefix("json", rest)
+	case strings.HasPrefix("```", trimmed):
+		return true
+	case stage == divider && strings.HasPrefix("---", trimmed):
+		return true
+	case stage <= heading && strings.HasPrefix(trimmed, "*"):
+		// * a bold summary heading still being written
+		return !strings.Contains(strings.Trim(trimmed, "*"), "*")
+	}
+	return false
+}
+
+// * blank so far or starting with {
+func jsonStart(content string) bool {
+	content = strings.TrimSpace(content)
+	return content == "" || content[0] == '{'
+}
+
+func isSummaryBody(content string) bool {
+	_, m := trailingSummary("```json\n" + content + "```")
+	return m != nil
+}
+
+// * length of the longest suffix of s that is a prefix of marker
+func partialPrefix(s, marker string) int {
+	for n := min(len(s), len(marker)-1); n > 0; n-- {
+		if strings.HasSuffix(s, marker[:n]) {
+			return n
+		}
+	}
+	return 0
+}
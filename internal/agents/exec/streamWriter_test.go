@@ -0,0 +1,83 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+// * deltas written for chunks joined, and what of it came out before flush
+func stream(chunks []string) (text string, beforeFlush string) {
+	events := make(chan agentTypes.Event, 4096)
+	w := newStreamWriter(events)
+	for _, chunk := range chunks {
+		w.write(chunk)
+	}
+	before := len(events)
+	w.flush()
+
+	var sb strings.Builder
+	for i, ev := range drain(events) {
+		if ev.Type != agentTypes.EventTextDelta {
+			continue
+		}
+		sb.WriteString(ev.Text)
+		if i == before-1 {
+			beforeFlush = sb.String()
+		}
+	}
+	return sb.String(), beforeFlush
+}
+
+// * every two-chunk split and one rune at a time
+func splits(text string) [][]string {
+	list := [][]string{{text}, strings.Split(text, "")}
+	for i := 1; i < len(text); i++ {
+		list = append(list, []string{text[:i], text[i:]})
+	}
+	return list
+}
+
+func TestStreamWriter(t *testing.T) {
+	summary := `{"core_discussion": "release", "confirmed_needs": ["notes"]}`
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"marker", "Done.\n" + summaryStart + summary + summaryEnd, "Done."},
+		{"fenced summary", "Done.\n\n```json\n" + summary + "\n```\n", "Done.\n"},
+		{"heading and divider", "Done.\n\n---\n**Summary**\n```json\n" + summary + "\n```", "Done.\n"},
+		{"plain text", "Use `go test` with -race --- and *care*.", "Use `go test` with -race --- and *care*."},
+		{"code block", "Run:\n```go\nfmt.Println(\"{}\")\n```\nthen check.", "Run:\n```go\nfmt.Println(\"{}\")\n```\nthen check."},
+		{"json not a summary", "Config:\n```json\n{\"model\": \"x\"}\n```\n", "Config:\n```json\n{\"model\": \"x\"}\n```\n"},
+		{"summary then text", "```json\n" + summary + "\n```\nMore.", "```json\n" + summary + "\n```\nMore."},
+	}
+	for _, tt := range tests {
+		for _, chunks := range splits(tt.input) {
+			// * whitespace before the summary may already be out, EventText trims it
+			got, _ := stream(chunks)
+			if strings.TrimRight(got, "\n") != strings.TrimRight(tt.want, "\n") {
+				t.Errorf("%s %q: got %q, want %q", tt.name, chunks, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestStreamWriterStreams(t *testing.T) {
+	// * only the candidate summary block waits for the end of the stream
+	_, before := stream(strings.Split("Done.\n\n```json\n{\"core_discussion\": \"x\"", ""))
+	if before != "Done.\n" {
+		t.Errorf("before flush = %q", before)
+	}
+	_, before = stream(strings.Split("Run:\n```go\nfmt.Println()\n", ""))
+	if before != "Run:\n```go\nfmt.Println()\n" {
+		t.Errorf("code block held back: %q", before)
+	}
+	_, before = stream([]string{"Done.\n<!--SUMMARY"})
+	if before != "Done.\n" {
+		t.Errorf("partial marker: %q", before)
+	}
+}
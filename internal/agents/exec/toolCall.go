@@ -5,16 +5,65 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 	"github.com/pardnchiu/agenvoy/internal/tools"
 	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
 )
 
-func toolCall(ctx context.Context, exec *toolTypes.Executor, choice agentTypes.OutputChoices, sessionData *agentTypes.AgentSession, events chan<- agentTypes.Event, allowAll bool, alreadyCall map[string]string) (*agentTypes.AgentSession, map[string]string, error) {
+const (
+	MaxToolConcurrency = 4
+)
+
+type toolCache struct {
+	mu    sync.RWMutex
+	calls map[string]string
+}
+
+func newToolCache() *toolCache {
+	return &toolCache{
+		calls: make(map[string]string),
+	}
+}
+
+func (c *toolCache) get(hash string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.calls[hash]
+	return value, ok && value != ""
+}
+
+func (c *toolCache) set(hash, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[hash] = value
+}
+
+type toolJob struct {
+	index int
+	id    string
+	name  string
+	args  string
+	hash  string
+}
+
+func toolCall(ctx context.Context, cfg *Config, exec *toolTypes.Executor, choice agentTypes.OutputChoices, sessionData *agentTypes.AgentSession, events chan<- agentTypes.Event, alreadyCall *toolCache) (*agentTypes.AgentSession, error) {
 	sessionData.Messages = append(sessionData.Messages, choice.Message)
+	// * names and arguments for the tool action log
+	sessionData.Tools = append(sessionData.Tools, choice.Message)
+
+	calls := choice.Message.ToolCalls
+	results := make([]string, len(calls))
+	// * tool results which should be recorded in session tools
+	recorded := make([]bool, len(calls))
+	// * same call inside one turn only run once
+	duplicate := make(map[int]int)
+	pending := make(map[string]int)
 
-	for _, tool := range choice.Message.ToolCalls {
+	// * confirm is serialized in original order before anything run
+	var jobs []toolJob
+	for i, tool := range calls {
 		toolID := strings.TrimSpace(tool.ID)
 		toolArg := strings.TrimSpace(tool.Function.Arguments)
 		toolName := strings.TrimSpace(tool.Function.Name)
@@ -23,12 +72,12 @@ func toolCall(ctx context.Context, exec *toolTypes.Executor, choice agentTypes.O
 		}
 
 		hash := fmt.Sprintf("%v|%v", toolName, toolArg)
-		if cached, ok := alreadyCall[hash]; ok && cached != "" {
-			sessionData.Messages = append(sessionData.Messages, agentTypes.Message{
-				Role:       "tool",
-				Content:    strings.TrimSpace(cached),
-				ToolCallID: toolID,
-			})
+		if cached, ok := alreadyCall.get(hash); ok {
+			results[i] = strings.TrimSpace(cached)
+			continue
+		}
+		if j, ok := pending[hash]; ok {
+			duplicate[i] = j
 			continue
 		}
 
@@ -39,7 +88,8 @@ func toolCall(ctx context.Context, exec *toolTypes.Executor, choice agentTypes.O
 			ToolID:   toolID,
 		}
 
-		if !allowAll {
+		// * tools declared read-only skip the prompt
+		if flags, _ := exec.Registry.Flags(toolName); !cfg.AllowAll && flags.NeedsConfirm() {
 			replyCh := make(chan bool, 1)
 			events <- agentTypes.Event{
 				Type:     agentTypes.EventToolConfirm,
@@ -48,72 +98,120 @@ func toolCall(ctx context.Context, exec *toolTypes.Executor, choice agentTypes.O
 				ToolID:   toolID,
 				ReplyCh:  replyCh,
 			}
-			proceed := <-replyCh
+			// * remote front ends may never answer, stop waiting on cancel
+			var proceed bool
+			select {
+			case proceed = <-replyCh:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 			if !proceed {
 				events <- agentTypes.Event{
 					Type:     agentTypes.EventToolSkipped,
 					ToolName: toolName,
 					ToolID:   toolID,
 				}
-				sessionData.Tools = append(sessionData.Tools, agentTypes.Message{
-					Role:       "tool",
-					Content:    "Skipped by user",
-					ToolCallID: toolID,
-				})
-				sessionData.Messages = append(sessionData.Messages, agentTypes.Message{
-					Role:       "tool",
-					Content:    "Skipped by user",
-					ToolCallID: toolID,
-				})
+				results[i] = "Skipped by user"
+				recorded[i] = true
 				continue
 			}
 		}
 
-		events <- agentTypes.Event{
-			Type:     agentTypes.EventToolCallStart,
-			ToolName: toolName,
-			ToolID:   toolID,
-		}
-
-		result, err := tools.Execute(ctx, exec, toolName, json.RawMessage(tool.Function.Arguments))
-		if err != nil {
-			result = "no data"
-		}
+		pending[hash] = i
+		jobs = append(jobs, toolJob{
+			index: i,
+			id:    toolID,
+			name:  toolName,
+			args:  tool.Function.Arguments,
+			hash:  hash,
+		})
+	}
 
-		if result != "" {
-			events <- agentTypes.Event{
-				Type:     agentTypes.EventToolCallText,
-				ToolName: toolName,
-				ToolID:   toolID,
-				Text:     result,
-			}
+	// * read-only and network tools run concurrently, mutating tools wait for everything before them
+	limit := cfg.ToolConcurrency
+	if limit <= 0 {
+		limit = MaxToolConcurrency
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		if flags, _ := exec.Registry.Flags(job.name); !flags.Concurrent() {
+			wg.Wait()
+			results[job.index] = runTool(ctx, exec, job, events, alreadyCall)
+			recorded[job.index] = true
+			continue
 		}
 
-		events <- agentTypes.Event{
-			Type:     agentTypes.EventToolCallEnd,
-			ToolName: toolName,
-			ToolID:   toolID,
-		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(job toolJob) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[job.index] = runTool(ctx, exec, job, events, alreadyCall)
+			recorded[job.index] = true
+		}(job)
+	}
+	wg.Wait()
 
-		content := strings.TrimSpace(fmt.Sprintf("[%s] %s", toolName, result))
-		alreadyCall[hash] = content
+	for i, j := range duplicate {
+		results[i] = results[j]
+	}
 
-		events <- agentTypes.Event{
-			Type:     agentTypes.EventToolResult,
-			ToolName: toolName,
-			ToolID:   toolID,
-			Result:   result,
+	for i, tool := range calls {
+		toolID := strings.TrimSpace(tool.ID)
+		if recorded[i] {
+			sessionData.Tools = append(sessionData.Tools, agentTypes.Message{
+				Role:       "tool",
+				Content:    results[i],
+				ToolCallID: toolID,
+			})
 		}
-		sessionData.Tools = append(sessionData.Tools, agentTypes.Message{
-			Role:       "tool",
-			Content:    content,
-			ToolCallID: toolID,
-		})
 		sessionData.Messages = append(sessionData.Messages, agentTypes.Message{
 			Role:       "tool",
-			Content:    content,
+			Content:    results[i],
 			ToolCallID: toolID,
 		})
 	}
-	return sessionData, alreadyCall, nil
+	return sessionData, nil
+}
+
+func runTool(ctx context.Context, exec *toolTypes.Executor, job toolJob, events chan<- agentTypes.Event, alreadyCall *toolCache) string {
+	events <- agentTypes.Event{
+		Type:     agentTypes.EventToolCallStart,
+		ToolName: job.name,
+		ToolID:   job.id,
+	}
+
+	result, err := tools.Execute(ctx, exec, job.name, json.RawMessage(job.args))
+	if err != nil {
+		result = "no data"
+	}
+
+	if result != "" {
+		events <- agentTypes.Event{
+			Type:     agentTypes.EventToolCallText,
+			ToolName: job.name,
+			ToolID:   job.id,
+			Text:     result,
+		}
+	}
+
+	events <- agentTypes.Event{
+		Type:     agentTypes.EventToolCallEnd,
+		ToolName: job.name,
+		ToolID:   job.id,
+	}
+
+	content := strings.TrimSpace(fmt.Sprintf("[%s] %s", job.name, result))
+	alreadyCall.set(job.hash, content)
+
+	events <- agentTypes.Event{
+		Type:     agentTypes.EventToolResult,
+		ToolName: job.name,
+		ToolID:   job.id,
+		Result:   result,
+	}
+	return content
 }
@@ -0,0 +1,183 @@
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+// * tools that report when they start and block until their argument is released
+type blockingTools struct {
+	mu      sync.Mutex
+	active  int
+	peak    int
+	runs    map[string]int
+	log     []string
+	started chan string
+	release map[string]chan struct{}
+}
+
+func newBlockingTools(args ...string) *blockingTools {
+	b := &blockingTools{
+		runs:    make(map[string]int),
+		started: make(chan string, 64),
+		release: make(map[string]chan struct{}),
+	}
+	for _, arg := range args {
+		b.release[arg] = make(chan struct{})
+	}
+	return b
+}
+
+func (b *blockingTools) tool(name string, flags toolTypes.ToolFlag) toolTypes.Tool {
+	def := toolTypes.ToolDef{Type: "function"}
+	def.Function.Name = name
+	return toolTypes.NewTool(def, flags, func(ctx context.Context, _ *toolTypes.Executor, args json.RawMessage) (string, error) {
+		var params struct {
+			Q string `json:"q"`
+		}
+		json.Unmarshal(args, &params)
+
+		b.mu.Lock()
+		b.active++
+		b.peak = max(b.peak, b.active)
+		b.runs[params.Q]++
+		b.log = append(b.log, "start "+params.Q)
+		b.mu.Unlock()
+		b.started <- params.Q
+
+		<-b.release[params.Q]
+
+		b.mu.Lock()
+		b.active--
+		b.log = append(b.log, "end "+params.Q)
+		b.mu.Unlock()
+		return "got " + params.Q, nil
+	})
+}
+
+func callsOf(calls ...[2]string) agentTypes.OutputChoices {
+	var choice agentTypes.OutputChoices
+	choice.Message.Role = "assistant"
+	for i, c := range calls {
+		var call agentTypes.ToolCall
+		call.ID = fmt.Sprintf("call_%d", i)
+		call.Type = "function"
+		call.Function.Name = c[0]
+		call.Function.Arguments = fmt.Sprintf(`{"q":%q}`, c[1])
+		choice.Message.ToolCalls = append(choice.Message.ToolCalls, call)
+	}
+	return choice
+}
+
+// * runs toolCall in the background, its session arrives on the returned channel
+func runToolCall(t *testing.T, limit int, b *blockingTools, choice agentTypes.OutputChoices) <-chan *agentTypes.AgentSession {
+	t.Helper()
+	registry := toolTypes.NewRegistry()
+	if err := registry.Register(b.tool("read", toolTypes.FlagReadOnly), b.tool("write", 0)); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{AllowAll: true, ToolConcurrency: limit}
+	exec := &toolTypes.Executor{Registry: registry}
+	events := make(chan agentTypes.Event, 256)
+
+	done := make(chan *agentTypes.AgentSession, 1)
+	go func() {
+		session, err := toolCall(context.Background(), cfg, exec, choice, &agentTypes.AgentSession{}, events, newToolCache())
+		if err != nil {
+			t.Error(err)
+		}
+		done <- session
+	}()
+	return done
+}
+
+func waitStarted(t *testing.T, b *blockingTools, want ...string) {
+	t.Helper()
+	for range want {
+		select {
+		case <-b.started:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("tools did not start, log %v", b.log)
+		}
+	}
+}
+
+func TestToolCallConcurrencyLimit(t *testing.T) {
+	b := newBlockingTools("a", "b", "c", "d", "e")
+	done := runToolCall(t, 2, b, callsOf([2]string{"read", "a"}, [2]string{"read", "b"}, [2]string{"read", "c"}, [2]string{"read", "d"}, [2]string{"read", "e"}))
+
+	waitStarted(t, b, "a", "b")
+	// * a third one would have started by now without the limit
+	time.Sleep(50 * time.Millisecond)
+	b.mu.Lock()
+	active := b.active
+	b.mu.Unlock()
+	if active != 2 {
+		t.Fatalf("active = %d, want 2", active)
+	}
+
+	for _, arg := range []string{"a", "b", "c", "d", "e"} {
+		close(b.release[arg])
+	}
+	<-done
+	if b.peak != 2 {
+		t.Errorf("peak = %d, want 2", b.peak)
+	}
+}
+
+func TestToolCallOrder(t *testing.T) {
+	b := newBlockingTools("a", "b", "c", "w")
+	done := runToolCall(t, 4, b, callsOf([2]string{"read", "a"}, [2]string{"read", "b"}, [2]string{"write", "w"}, [2]string{"read", "c"}))
+
+	// * a and b run together, the write waits for both
+	waitStarted(t, b, "a", "b")
+	close(b.release["b"])
+	time.Sleep(20 * time.Millisecond)
+	close(b.release["a"])
+	waitStarted(t, b, "w")
+	close(b.release["w"])
+	waitStarted(t, b, "c")
+	close(b.release["c"])
+
+	session := <-done
+	// * a and b start in either order
+	want := []string{"end b", "end a", "start w", "end w", "start c", "end c"}
+	if fmt.Sprint(b.log[2:]) != fmt.Sprint(want) {
+		t.Errorf("log = %v, want %v", b.log, want)
+	}
+
+	// * results keep the order of the calls, not of completion
+	var got []string
+	for _, msg := range session.Messages[1:] {
+		got = append(got, fmt.Sprintf("%s=%v", msg.ToolCallID, msg.Content))
+	}
+	if fmt.Sprint(got) != "[call_0=[read] got a call_1=[read] got b call_2=[write] got w call_3=[read] got c]" {
+		t.Errorf("results = %v", got)
+	}
+}
+
+func TestToolCallDuplicate(t *testing.T) {
+	b := newBlockingTools("a", "b")
+	close(b.release["a"])
+	close(b.release["b"])
+	session := <-runToolCall(t, 4, b, callsOf([2]string{"read", "a"}, [2]string{"read", "b"}, [2]string{"read", "a"}))
+
+	if b.runs["a"] != 1 || b.runs["b"] != 1 {
+		t.Errorf("runs = %v, want each once", b.runs)
+	}
+	msgs := session.Messages[1:]
+	if len(msgs) != 3 || msgs[2].Content != msgs[0].Content || msgs[2].ToolCallID != "call_2" {
+		t.Errorf("messages = %+v", msgs)
+	}
+	// * the duplicate is answered but recorded once in the tool log
+	if len(session.Tools) != 3 {
+		t.Errorf("tools = %d entries, want call message and 2 results", len(session.Tools))
+	}
+}
@@ -0,0 +1,56 @@
+package exec
+
+import (
+	"sync"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+// * token usage of one Run, selector calls included, kept per model in the order first seen
+type usageMeter struct {
+	mu     sync.Mutex
+	order  []string
+	models map[string]*agentTypes.Usage
+}
+
+func newUsageMeter() *usageMeter {
+	return &usageMeter{models: make(map[string]*agentTypes.Usage)}
+}
+
+func (m *usageMeter) add(agent agentTypes.Agent, resp *agentTypes.Output) {
+	if resp == nil || resp.Usage == nil {
+		return
+	}
+	model := modelName(agent)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.models[model]
+	if !ok {
+		u = &agentTypes.Usage{}
+		m.models[model] = u
+		m.order = append(m.order, model)
+	}
+	u.Add(resp.Usage)
+}
+
+// * nil when no provider reported usage
+func (m *usageMeter) report(prices map[string]Price) []agentTypes.ModelUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var list []agentTypes.ModelUsage
+	for _, model := range m.order {
+		u := *m.models[model]
+		if u.IsZero() {
+			continue
+		}
+		entry := agentTypes.ModelUsage{Model: model, Usage: u}
+		if price, ok := LookupPrice(model, prices); ok {
+			entry.Cost = price.Cost(u)
+		}
+		list = append(list, entry)
+	}
+	return list
+}
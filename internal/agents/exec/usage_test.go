@@ -0,0 +1,131 @@
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+type namedAgent string
+
+func (a namedAgent) Send(context.Context, []agentTypes.Message, []toolTypes.ToolDef) (*agentTypes.Output, error) {
+	return nil, nil
+}
+
+func (a namedAgent) Stream(context.Context, []agentTypes.Message, []toolTypes.ToolDef, func(string)) (*agentTypes.Output, error) {
+	return nil, nil
+}
+
+func (a namedAgent) Execute(context.Context, *skill.Skill, string, chan<- agentTypes.Event, bool) error {
+	return nil
+}
+
+func (a namedAgent) ModelName() string {
+	return string(a)
+}
+
+func TestLookupPrice(t *testing.T) {
+	overrides := map[string]Price{
+		"compat[vllm]@":    {Input: 0.1, Output: 0.2},
+		"gpt-4.1":          {Input: 1, Output: 1},
+		"openai@gpt-4.1-m": {Input: 9, Output: 9},
+	}
+	tests := []struct {
+		model string
+		input float64
+		ok    bool
+	}{
+		{"claude@claude-sonnet-4-5", 3, true},
+		{"claude@claude-opus-4-1", 15, true},
+		{"claude@claude-opus-4-5", 5, true},
+		{"openai@o3-mini", 1.1, true},
+		{"compat[vllm]@qwen3:32b", 0.1, true},
+		{"copilot@gpt-4.1", 1, true},
+		{"openai@gpt-4.1-mini", 9, true},
+		{"copilot@claude-sonnet-4-5", 0, false},
+		{"compat[ollama]@qwen3:8b", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := LookupPrice(tt.model, overrides)
+		if ok != tt.ok || price.Input != tt.input {
+			t.Errorf("LookupPrice(%q) = %+v, %v", tt.model, price, ok)
+		}
+	}
+	if _, ok := LookupPrice("copilot@gpt-4.1", nil); ok {
+		t.Error("copilot should stay unpriced without an override")
+	}
+}
+
+func TestPriceCost(t *testing.T) {
+	usage := agentTypes.Usage{InputTokens: 1000000, OutputTokens: 100000, CacheReadTokens: 1000000, CacheWriteTokens: 1000000}
+	if got := (Price{3, 15, 0.3, 3.75}).Cost(usage); math.Abs(got-8.55) > 1e-9 {
+		t.Errorf("cost = %f", got)
+	}
+	// * cache tokens fall back to the input price
+	if got := (Price{Input: 1, Output: 2}).Cost(usage); math.Abs(got-3.2) > 1e-9 {
+		t.Errorf("fallback cost = %f", got)
+	}
+}
+
+func TestUsageMeter(t *testing.T) {
+	selector, agent := namedAgent("copilot@gpt-4.1"), namedAgent("claude@claude-sonnet-4-5")
+	meter := newUsageMeter()
+	meter.add(selector, &agentTypes.Output{Usage: &agentTypes.Usage{InputTokens: 300, OutputTokens: 2}})
+	meter.add(agent, &agentTypes.Output{Usage: &agentTypes.Usage{InputTokens: 1000, OutputTokens: 100}})
+	meter.add(agent, &agentTypes.Output{})
+	meter.add(agent, &agentTypes.Output{Usage: &agentTypes.Usage{InputTokens: 2000, OutputTokens: 100, CacheReadTokens: 1000}})
+
+	report := meter.report(nil)
+	if len(report) != 2 || report[0].Model != "copilot@gpt-4.1" || report[0].Cost != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	want := agentTypes.Usage{InputTokens: 3000, OutputTokens: 200, CacheReadTokens: 1000}
+	if report[1].Usage != want || math.Abs(report[1].Cost-0.0123) > 1e-9 {
+		t.Errorf("agent usage = %+v", report[1])
+	}
+
+	if report := newUsageMeter().report(nil); report != nil {
+		t.Errorf("empty report = %+v", report)
+	}
+}
+
+func TestUsageJSON(t *testing.T) {
+	// * chat completions usage, cached tokens split out of the prompt
+	var out agentTypes.Output
+	data := `{"choices":[],"usage":{"prompt_tokens":1200,"completion_tokens":30,"total_tokens":1230,"prompt_tokens_details":{"cached_tokens":1000}}}`
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := (agentTypes.Usage{InputTokens: 200, OutputTokens: 30, CacheReadTokens: 1000}); out.Usage == nil || *out.Usage != want {
+		t.Errorf("usage = %+v", out.Usage)
+	}
+
+	// * own encoding round trips
+	encoded, _ := json.Marshal(out.Usage)
+	var back agentTypes.Usage
+	if err := json.Unmarshal(encoded, &back); err != nil || back != *out.Usage {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+
+	// * usage of a stream arrives in the last chunk
+	var builder agentTypes.StreamBuilder
+	for _, chunk := range []string{
+		`{"choices":[{"delta":{"content":"hi"}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":50,"completion_tokens":5}}`,
+	} {
+		var c agentTypes.StreamChunk
+		if err := json.Unmarshal([]byte(chunk), &c); err != nil {
+			t.Fatal(err)
+		}
+		builder.AddChunk(&c)
+	}
+	if out := builder.Output(); out.Usage == nil || out.Usage.InputTokens != 50 || out.Usage.OutputTokens != 5 {
+		t.Errorf("stream usage = %+v", out.Usage)
+	}
+}
@@ -1,20 +1,18 @@
 package exec
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
-	"github.com/pardnchiu/agenvoy/internal/utils"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
 )
 
-func writeHistory(choice agentTypes.OutputChoices, configDir *utils.ConfigDirData, session *agentTypes.AgentSession) error {
+// * only entries after session.Saved are appended, earlier ones are already stored
+func writeHistory(choice agentTypes.OutputChoices, store sessions.Store, session *agentTypes.AgentSession) error {
 	session.Histories = append(session.Histories, choice.Message)
 
-	filtered := make([]agentTypes.Message, 0, len(session.Histories))
-	for _, m := range session.Histories {
+	filtered := make([]agentTypes.Message, 0, len(session.Histories)-session.Saved)
+	for _, m := range session.Histories[session.Saved:] {
 		if m.Role == "system" {
 			continue
 		}
@@ -27,13 +25,15 @@ func writeHistory(choice agentTypes.OutputChoices, configDir *utils.ConfigDirDat
 		filtered = append(filtered, m)
 	}
 
-	historyPath := filepath.Join(configDir.Home, session.ID, "history.json")
-	historyData, err := json.Marshal(filtered)
-	if err != nil {
-		return fmt.Errorf("json.Marshal: %w", err)
+	if err := store.AppendHistory(session.ID, filtered...); err != nil {
+		return fmt.Errorf("store.AppendHistory: %w", err)
 	}
-	if err := os.WriteFile(historyPath, historyData, 0644); err != nil {
-		return fmt.Errorf("os.WriteFile: %w", err)
+	session.Histories = append(session.Histories[:session.Saved], filtered...)
+	session.Saved = len(session.Histories)
+
+	// * lets fork and rewind restore the summary of an earlier turn
+	if err := sessions.RecordSummary(store, session.ID, session.Saved); err != nil {
+		return fmt.Errorf("sessions.RecordSummary: %w", err)
 	}
 	return nil
 }
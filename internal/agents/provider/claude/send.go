@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/pardnchiu/agenvoy/internal/agents/exec"
 	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
@@ -15,20 +16,91 @@ import (
 const (
 	messagesAPI = "https://api.anthropic.com/v1/messages"
 	maxTokens   = 16384
+	// * continuations of one reply cut by max_tokens
+	maxContinuations = 3
+	// * smallest budget_tokens the API accepts
+	minThinkingBudget = 1024
 )
 
+var cacheControl = map[string]any{"type": "ephemeral"}
+
 func (a *Agent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
-	return exec.Execute(ctx, a, a.workDir, skill, userInput, events, allowAll)
+	cfg, err := exec.NewConfig(a.workDir)
+	if err != nil {
+		return fmt.Errorf("exec.NewConfig: %w", err)
+	}
+	cfg.AllowAll = allowAll
+	return exec.Execute(ctx, cfg, a, skill, userInput, events)
+}
+
+func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef) (*agentTypes.Output, error) {
+	return a.complete(messages, tools, func(body map[string]any) (*Output, error) {
+		result, _, err := utils.POST[Output](ctx, a.httpClient, messagesAPI, a.generateHeaders(), body, "json")
+		if err != nil {
+			return nil, fmt.Errorf("utils.POST: %w", err)
+		}
+
+		if result.Error != nil {
+			return nil, fmt.Errorf("result.Error: %s", result.Error.Message)
+		}
+		return &result, nil
+	})
+}
+
+// * a reply cut by max_tokens is continued from its text, prefilled as the start of the assistant turn;
+// * a tool call cut halfway is dropped, the continuation asks for it again
+func (a *Agent) complete(messages []agentTypes.Message, tools []toolTypes.ToolDef, send func(body map[string]any) (*Output, error)) (*agentTypes.Output, error) {
+	body := a.generateRequestBody(messages, tools, "")
+	var prefix string
+	var thinking []agentTypes.ThinkingBlock
+	var usage Usage
+	for round := 0; ; round++ {
+		resp, err := send(body)
+		if err != nil {
+			return nil, err
+		}
+		usage.add(resp.Usage)
+
+		output := a.convertToOutput(resp)
+		message := &output.Choices[0].Message
+		thinking = append(thinking, message.Thinking...)
+		text, _ := message.Content.(string)
+
+		if resp.StopReason != "max_tokens" {
+			message.Content = prefix + text
+			message.Thinking = thinking
+			output.Usage = usage.convert()
+			return output, nil
+		}
+
+		prefix = strings.TrimRight(prefix+text, " \t\r\n")
+		if prefix == "" || round >= maxContinuations {
+			return nil, fmt.Errorf("exceeded max_tokens (%d)", maxTokens)
+		}
+		body = a.generateRequestBody(messages, tools, prefix)
+	}
 }
 
-func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.Tool) (*agentTypes.Output, error) {
-	var systemPrompt string
+func (a *Agent) generateHeaders() map[string]string {
+	return map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": "2023-06-01",
+		"Content-Type":      "application/json",
+	}
+}
+
+// * prefill continues an assistant reply cut by max_tokens
+func (a *Agent) generateRequestBody(messages []agentTypes.Message, tools []toolTypes.ToolDef, prefill string) map[string]any {
+	var system []map[string]any
 	var newMessages []map[string]any
 
 	for _, msg := range messages {
 		if msg.Role == "system" {
-			if content, ok := msg.Content.(string); ok {
-				systemPrompt = content
+			if content, ok := msg.Content.(string); ok && content != "" {
+				system = append(system, map[string]any{
+					"type": "text",
+					"text": content,
+				})
 			}
 			continue
 		}
@@ -36,32 +108,74 @@ func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools [
 		message := a.convertToMessage(msg)
 		newMessages = append(newMessages, message)
 	}
-
 	newTools := a.convertToTools(tools)
-	result, _, err := utils.POST[Output](ctx, a.httpClient, messagesAPI, map[string]string{
-		"x-api-key":         a.apiKey,
-		"anthropic-version": "2023-06-01",
-		"Content-Type":      "application/json",
-	}, map[string]any{
+
+	// * cache breakpoints: the tool list, the embedded system prompt (a summary after it changes per run)
+	// * and the history so far, which the next iteration of the tool loop starts with
+	if len(newTools) > 0 {
+		newTools[len(newTools)-1]["cache_control"] = cacheControl
+	}
+	if len(system) > 0 {
+		system[0]["cache_control"] = cacheControl
+	}
+	if len(newMessages) > 0 {
+		setCacheControl(newMessages[len(newMessages)-1])
+	}
+
+	body := map[string]any{
 		"model":      a.model,
 		"max_tokens": maxTokens,
-		"system":     systemPrompt,
 		"messages":   newMessages,
 		"tools":      newTools,
-	}, "json")
-	if err != nil {
-		return nil, fmt.Errorf("utils.POST: %w", err)
+	}
+	if len(system) > 0 {
+		body["system"] = system
 	}
 
-	if result.Error != nil {
-		return nil, fmt.Errorf("result.Error: %s", result.Error.Message)
+	// * thinking can not be prefilled, and a tool loop begun without it can not turn it on halfway
+	switch {
+	case prefill != "":
+		body["messages"] = append(newMessages, map[string]any{
+			"role":    "assistant",
+			"content": prefill,
+		})
+	case a.thinkingBudget > 0 && canThink(messages):
+		body["thinking"] = map[string]any{
+			"type":          "enabled",
+			"budget_tokens": a.thinkingBudget,
+		}
+		body["max_tokens"] = maxTokens + a.thinkingBudget
 	}
+	return body
+}
 
-	if result.StopReason == "max_tokens" {
-		return nil, fmt.Errorf("exceeded max_tokens (%d)", maxTokens)
+// * the last assistant turn, when it called tools, must start with its thinking blocks
+func canThink(messages []agentTypes.Message) bool {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" {
+			return len(messages[i].ToolCalls) == 0 || len(messages[i].Thinking) > 0
+		}
 	}
+	return true
+}
 
-	return a.convertToOutput(&result), nil
+func setCacheControl(message map[string]any) {
+	switch content := message["content"].(type) {
+	case string:
+		if content != "" {
+			message["content"] = []map[string]any{
+				{
+					"type":          "text",
+					"text":          content,
+					"cache_control": cacheControl,
+				},
+			}
+		}
+	case []map[string]any:
+		if len(content) > 0 {
+			content[len(content)-1]["cache_control"] = cacheControl
+		}
+	}
 }
 
 func (a *Agent) convertToMessage(message agentTypes.Message) map[string]any {
@@ -80,8 +194,29 @@ func (a *Agent) convertToMessage(message agentTypes.Message) map[string]any {
 
 	if len(message.ToolCalls) > 0 {
 		var content []map[string]any
+		// * thinking goes back unchanged and first, the signature is checked
+		for _, block := range message.Thinking {
+			if block.Type == "redacted_thinking" {
+				content = append(content, map[string]any{
+					"type": "redacted_thinking",
+					"data": block.Data,
+				})
+				continue
+			}
+			content = append(content, map[string]any{
+				"type":      "thinking",
+				"thinking":  block.Thinking,
+				"signature": block.Signature,
+			})
+		}
+		if text, ok := message.Content.(string); ok && text != "" {
+			content = append(content, map[string]any{
+				"type": "text",
+				"text": text,
+			})
+		}
 		for _, tool := range message.ToolCalls {
-			var input map[string]any
+			input := map[string]any{}
 			json.Unmarshal([]byte(tool.Function.Arguments), &input)
 			content = append(content, map[string]any{
 				"type":  "tool_use",
@@ -102,7 +237,7 @@ func (a *Agent) convertToMessage(message agentTypes.Message) map[string]any {
 	}
 }
 
-func (a *Agent) convertToTools(tools []toolTypes.Tool) []map[string]any {
+func (a *Agent) convertToTools(tools []toolTypes.ToolDef) []map[string]any {
 	newTools := make([]map[string]any, len(tools))
 	for i, tool := range tools {
 		newTools[i] = map[string]any{
@@ -117,22 +252,28 @@ func (a *Agent) convertToTools(tools []toolTypes.Tool) []map[string]any {
 func (a *Agent) convertToOutput(resp *Output) *agentTypes.Output {
 	output := &agentTypes.Output{
 		Choices: make([]agentTypes.OutputChoices, 1),
+		Usage:   resp.Usage.convert(),
 	}
 
 	var toolCalls []agentTypes.ToolCall
+	var thinking []agentTypes.ThinkingBlock
 	var textContent string
 
 	for _, item := range resp.Content {
-		if item.Type == "text" {
-			textContent = item.Text
-		} else if item.Type == "tool_use" {
-			arg := ""
-			if item.Input != nil {
-				data, err := json.Marshal(item.Input)
-				if err != nil {
-					continue
-				}
-				arg = string(data)
+		switch item.Type {
+		case "text":
+			textContent += item.Text
+		case "thinking", "redacted_thinking":
+			thinking = append(thinking, agentTypes.ThinkingBlock{
+				Type:      item.Type,
+				Thinking:  item.Thinking,
+				Signature: item.Signature,
+				Data:      item.Data,
+			})
+		case "tool_use":
+			arg := string(item.Input)
+			if arg == "" {
+				arg = "{}"
 			}
 
 			toolCall := agentTypes.ToolCall{
@@ -149,6 +290,7 @@ func (a *Agent) convertToOutput(resp *Output) *agentTypes.Output {
 		Role:      "assistant",
 		Content:   textContent,
 		ToolCalls: toolCalls,
+		Thinking:  thinking,
 	}
 
 	return output
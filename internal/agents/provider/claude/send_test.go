@@ -0,0 +1,161 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/replay"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+// * keeps the request bodies sent through next
+type capture struct {
+	next   http.RoundTripper
+	bodies []map[string]any
+}
+
+func (c *capture) RoundTrip(req *http.Request) (*http.Response, error) {
+	data, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	var body map[string]any
+	json.Unmarshal(data, &body)
+	c.bodies = append(c.bodies, body)
+	return c.next.RoundTrip(req)
+}
+
+func encode(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRequestBody(t *testing.T) {
+	agent := &Agent{model: "claude-sonnet-4-5", thinkingBudget: 2048}
+	def := toolTypes.ToolDef{Type: "function"}
+	def.Function.Name = "read_file"
+	def.Function.Parameters = json.RawMessage(`{"type":"object"}`)
+	tools := []toolTypes.ToolDef{def, def}
+
+	messages := []agentTypes.Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "old question"},
+		{Role: "system", Content: "summary"},
+		{Role: "user", Content: "read notes.md"},
+	}
+	body := agent.generateRequestBody(messages, tools, "")
+
+	// * both system messages kept, the stable first one cached
+	if got := encode(t, body["system"]); got != `[{"cache_control":{"type":"ephemeral"},"text":"prompt","type":"text"},{"text":"summary","type":"text"}]` {
+		t.Errorf("system = %s", got)
+	}
+	newTools := body["tools"].([]map[string]any)
+	if _, ok := newTools[0]["cache_control"]; ok || newTools[1]["cache_control"] == nil {
+		t.Errorf("tools = %s", encode(t, newTools))
+	}
+	newMessages := body["messages"].([]map[string]any)
+	if got := encode(t, newMessages[1]); got != `{"content":[{"cache_control":{"type":"ephemeral"},"text":"read notes.md","type":"text"}],"role":"user"}` {
+		t.Errorf("last message = %s", got)
+	}
+	if got := encode(t, body["thinking"]); got != `{"budget_tokens":2048,"type":"enabled"}` || body["max_tokens"] != maxTokens+2048 {
+		t.Errorf("thinking = %s, max_tokens = %v", got, body["max_tokens"])
+	}
+
+	// * a tool loop begun by another provider has no thinking to send back
+	call := agentTypes.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "read_file"
+	call.Function.Arguments = `{"path":"notes.md"}`
+	messages = append(messages,
+		agentTypes.Message{Role: "assistant", ToolCalls: []agentTypes.ToolCall{call}},
+		agentTypes.Message{Role: "tool", ToolCallID: "call_1", Content: "three tasks"},
+	)
+	if body := agent.generateRequestBody(messages, tools, ""); body["thinking"] != nil {
+		t.Error("thinking enabled after a tool call without thinking blocks")
+	}
+	messages[4].Thinking = []agentTypes.ThinkingBlock{{Type: "thinking", Thinking: "read it", Signature: "sig"}}
+	body = agent.generateRequestBody(messages, tools, "")
+	newMessages = body["messages"].([]map[string]any)
+	if body["thinking"] == nil || encode(t, newMessages[2]["content"]) != `[{"signature":"sig","thinking":"read it","type":"thinking"},{"id":"call_1","input":{"path":"notes.md"},"name":"read_file","type":"tool_use"}]` {
+		t.Errorf("assistant = %s", encode(t, newMessages[2]))
+	}
+	if got := encode(t, newMessages[3]["content"]); got != `[{"cache_control":{"type":"ephemeral"},"content":"three tasks","tool_use_id":"call_1","type":"tool_result"}]` {
+		t.Errorf("tool result = %s", got)
+	}
+
+	// * a continuation prefills the cut text, without thinking
+	body = agent.generateRequestBody(messages, tools, "The first half")
+	newMessages = body["messages"].([]map[string]any)
+	if last := newMessages[len(newMessages)-1]; body["thinking"] != nil || last["role"] != "assistant" || last["content"] != "The first half" {
+		t.Errorf("prefill = %s", encode(t, body))
+	}
+}
+
+func TestContinuation(t *testing.T) {
+	player, err := replay.Open("testdata/continue.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := &capture{next: player}
+	agent, err := NewWithConfig("claude@claude-sonnet-4-5", agentTypes.ProviderConfig{
+		WorkDir:        t.TempDir(),
+		Secrets:        func(string) string { return "test-key" },
+		HTTPClient:     &http.Client{Transport: sent},
+		ThinkingBudget: 500,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.thinkingBudget != minThinkingBudget {
+		t.Errorf("budget = %d", agent.thinkingBudget)
+	}
+	ctx := context.Background()
+	messages := []agentTypes.Message{{Role: "system", Content: "prompt"}, {Role: "user", Content: "both halves"}}
+
+	// * cut by max_tokens mid tool call: the text is continued and the tool call asked for again
+	var deltas string
+	out, err := agent.Stream(ctx, messages, nil, func(text string) { deltas += text })
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := out.Choices[0].Message
+	if message.Content != "The first half and the second half." || deltas != "The first half  and the second half." {
+		t.Errorf("text = %q, deltas = %q", message.Content, deltas)
+	}
+	if len(message.ToolCalls) != 1 || message.ToolCalls[0].ID != "toolu_10" || message.ToolCalls[0].Function.Arguments != `{"path": "notes.md"}` {
+		t.Errorf("tool calls = %+v", message.ToolCalls)
+	}
+	if len(message.Thinking) != 1 || message.Thinking[0].Signature != "EqQBCgIYAhIM1gbcDa9GJwZA2b3h" {
+		t.Errorf("thinking = %+v", message.Thinking)
+	}
+	if want := (agentTypes.Usage{InputTokens: 42, OutputTokens: 18472, CacheReadTokens: 3100, CacheWriteTokens: 3100}); *out.Usage != want {
+		t.Errorf("usage = %+v", out.Usage)
+	}
+	if len(sent.bodies) != 2 || sent.bodies[0]["thinking"] == nil || sent.bodies[1]["thinking"] != nil {
+		t.Fatalf("bodies = %v", sent.bodies)
+	}
+	continued := sent.bodies[1]["messages"].([]any)
+	if last := continued[len(continued)-1].(map[string]any); last["role"] != "assistant" || last["content"] != "The first half" {
+		t.Errorf("prefill = %v", last)
+	}
+
+	// * Send keeps thinking, redacted thinking included, for the next turn
+	out, err = agent.Send(ctx, messages, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message = out.Choices[0].Message
+	if len(message.Thinking) != 2 || message.Thinking[1].Type != "redacted_thinking" || message.ToolCalls[0].Function.Arguments != `{"path": "."}` {
+		t.Errorf("message = %+v", message)
+	}
+	body := agent.generateRequestBody(append(messages, message), nil, "")
+	if got := encode(t, body["messages"].([]map[string]any)[1]["content"].([]map[string]any)[:2]); got != `[{"signature":"sig-send","thinking":"Need the file.","type":"thinking"},{"data":"EmwKAhgBEgy3va3pzix","type":"redacted_thinking"}]` {
+		t.Errorf("thinking sent back = %s", got)
+	}
+}
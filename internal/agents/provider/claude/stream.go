@@ -0,0 +1,92 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * content blocks are assembled as the non-streaming response would carry them
+func (a *Agent) Stream(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef, onDelta func(string)) (*agentTypes.Output, error) {
+	return a.complete(messages, tools, func(body map[string]any) (*Output, error) {
+		body["stream"] = true
+
+		resp := &Output{Usage: &Usage{}}
+		args := make(map[int]*strings.Builder)
+		block := func(index int) *Content {
+			for len(resp.Content) <= index {
+				resp.Content = append(resp.Content, Content{})
+			}
+			return &resp.Content[index]
+		}
+
+		_, err := utils.POSTStream(ctx, a.httpClient, messagesAPI, a.generateHeaders(), body, func(_ string, data []byte) error {
+			var event StreamEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				return fmt.Errorf("json.Unmarshal: %w", err)
+			}
+
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					resp.Usage.merge(event.Message.Usage)
+				}
+
+			case "content_block_start":
+				if event.ContentBlock != nil {
+					*block(event.Index) = *event.ContentBlock
+					// * the input arrives again as input_json_delta
+					block(event.Index).Input = nil
+				}
+
+			case "content_block_delta":
+				b := block(event.Index)
+				switch event.Delta.Type {
+				case "text_delta":
+					b.Type = "text"
+					b.Text += event.Delta.Text
+					if event.Delta.Text != "" && onDelta != nil {
+						onDelta(event.Delta.Text)
+					}
+				case "input_json_delta":
+					if args[event.Index] == nil {
+						args[event.Index] = &strings.Builder{}
+					}
+					args[event.Index].WriteString(event.Delta.PartialJSON)
+				case "thinking_delta":
+					b.Thinking += event.Delta.Thinking
+				case "signature_delta":
+					b.Signature += event.Delta.Signature
+				}
+
+			case "message_delta":
+				resp.Usage.merge(event.Usage)
+				if event.Delta.StopReason != "" {
+					resp.StopReason = event.Delta.StopReason
+				}
+
+			case "error":
+				if event.Error != nil {
+					return &agentTypes.ProviderError{
+						Kind: errorKind(event.Error.Type),
+						Err:  fmt.Errorf("event.Error: %s", event.Error.Message),
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("utils.POSTStream: %w", err)
+		}
+
+		for index, b := range args {
+			block(index).Input = json.RawMessage(b.String())
+		}
+		return resp, nil
+	})
+}
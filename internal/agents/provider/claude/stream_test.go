@@ -0,0 +1,50 @@
+package claude
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/replay"
+)
+
+func TestStreamReplay(t *testing.T) {
+	player, err := replay.Open("testdata/stream.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := NewWithConfig("claude@claude-sonnet-4-5", agentTypes.ProviderConfig{
+		WorkDir:    t.TempDir(),
+		Secrets:    func(string) string { return "test-key" },
+		HTTPClient: &http.Client{Transport: player},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	messages := []agentTypes.Message{{Role: "user", Content: "what is in notes.md"}}
+
+	// * text and a tool_use block in one message, usage merged from message_start and message_delta
+	var deltas string
+	out, err := agent.Stream(ctx, messages, nil, func(text string) { deltas += text })
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := out.Choices[0].Message
+	if text, _ := message.Content.(string); text != "Reading the notes first." || deltas != text {
+		t.Errorf("text = %q, deltas = %q", text, deltas)
+	}
+	if len(message.ToolCalls) != 1 || message.ToolCalls[0].ID != "toolu_01" || message.ToolCalls[0].Function.Arguments != `{"path": "notes.md"}` {
+		t.Errorf("tool calls = %+v", message.ToolCalls)
+	}
+	if want := (agentTypes.Usage{InputTokens: 420, OutputTokens: 64, CacheReadTokens: 2048}); out.Usage == nil || *out.Usage != want {
+		t.Errorf("usage = %+v", out.Usage)
+	}
+
+	// * an error event inside a 200 stream is still classified
+	_, err = agent.Stream(ctx, messages, nil, nil)
+	if perr := agentTypes.NewProviderError(agent.ModelName(), err); perr.Kind != agentTypes.ErrOverloaded || !perr.Retryable() {
+		t.Errorf("err = %v", err)
+	}
+}
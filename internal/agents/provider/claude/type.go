@@ -1,5 +1,11 @@
 package claude
 
+import (
+	"encoding/json"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
 type Output struct {
 	ID         string    `json:"id"`
 	Type       string    `json:"type"`
@@ -7,20 +13,108 @@ type Output struct {
 	Content    []Content `json:"content"`
 	Model      string    `json:"model"`
 	StopReason string    `json:"stop_reason"`
-	Usage      struct {
-		InputTokens  int `json:"input_tokens"`
-		OutputTokens int `json:"output_tokens"`
-	} `json:"usage"`
-	Error *struct {
+	Usage      *Usage    `json:"usage"`
+	Error      *struct {
 		Type    string `json:"type"`
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
 type Content struct {
-	Type  string         `json:"type"`
-	Text  string         `json:"text,omitempty"`
-	ID    string         `json:"id,omitempty"`
-	Name  string         `json:"name,omitempty"`
-	Input map[string]any `json:"input,omitempty"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// * thinking blocks, redacted ones only carry data
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+type StreamEvent struct {
+	Type         string   `json:"type"`
+	Index        int      `json:"index"`
+	ContentBlock *Content `json:"content_block,omitempty"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text,omitempty"`
+		PartialJSON string `json:"partial_json,omitempty"`
+		Thinking    string `json:"thinking,omitempty"`
+		Signature   string `json:"signature,omitempty"`
+		StopReason  string `json:"stop_reason,omitempty"`
+	} `json:"delta"`
+	// * message_start carries the input usage, message_delta the output usage
+	Message *struct {
+		Usage *Usage `json:"usage"`
+	} `json:"message,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (u *Usage) convert() *agentTypes.Usage {
+	if u == nil {
+		return nil
+	}
+	return &agentTypes.Usage{
+		InputTokens:      u.InputTokens,
+		OutputTokens:     u.OutputTokens,
+		CacheReadTokens:  u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
+}
+
+// * message_delta counts are cumulative, a field it carries replaces the one from message_start
+func (u *Usage) merge(other *Usage) {
+	if other == nil {
+		return
+	}
+	if other.InputTokens > 0 {
+		u.InputTokens = other.InputTokens
+	}
+	if other.OutputTokens > 0 {
+		u.OutputTokens = other.OutputTokens
+	}
+	if other.CacheReadInputTokens > 0 {
+		u.CacheReadInputTokens = other.CacheReadInputTokens
+	}
+	if other.CacheCreationInputTokens > 0 {
+		u.CacheCreationInputTokens = other.CacheCreationInputTokens
+	}
+}
+
+// * rounds of a continued reply are billed separately
+func (u *Usage) add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+}
+
+// * error.type of an error event, ex. "overloaded_error"
+func errorKind(errorType string) agentTypes.ErrorKind {
+	switch errorType {
+	case "rate_limit_error":
+		return agentTypes.ErrRateLimit
+	case "overloaded_error", "api_error":
+		return agentTypes.ErrOverloaded
+	case "authentication_error", "permission_error":
+		return agentTypes.ErrAuth
+	case "invalid_request_error", "not_found_error", "request_too_large":
+		return agentTypes.ErrRequest
+	}
+	return agentTypes.ErrOther
 }
@@ -0,0 +1,35 @@
+package compat
+
+import (
+	"context"
+	"fmt"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * OpenAI-compatible /v1/embeddings, ex. compat@nomic-embed-text on Ollama
+func (a *Agent) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddingAPI := a.baseURL + "/v1/embeddings"
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	if a.apiKey != "" {
+		headers["Authorization"] = "Bearer " + a.apiKey
+	}
+
+	result, _, err := utils.POST[agentTypes.EmbeddingOutput](ctx, a.httpClient, embeddingAPI, headers, map[string]any{
+		"model": a.model,
+		"input": texts,
+	}, "json")
+	if err != nil {
+		return nil, fmt.Errorf("utils.POST: %w", err)
+	}
+
+	vectors, err := result.Vectors(len(texts))
+	if err != nil {
+		return nil, fmt.Errorf("utils.POST: %w", err)
+	}
+	return vectors, nil
+}
@@ -3,18 +3,20 @@ package compat
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/pardnchiu/agenvoy/internal/keychain"
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 )
 
 type Agent struct {
-	httpClient *http.Client
-	model      string
-	baseURL    string
-	apiKey     string
-	workDir    string
+	httpClient    *http.Client
+	name          string // * registry name, ex. "compat[ollama]@qwen3:8b"
+	model         string
+	contextWindow int
+	baseURL       string
+	apiKey        string
+	workDir       string
 }
 
 const (
@@ -23,14 +25,24 @@ const (
 )
 
 func New(model ...string) (*Agent, error) {
+	name := ""
+	if len(model) > 0 {
+		name = model[0]
+	}
+	return NewWithConfig(name, agentTypes.ProviderConfig{})
+}
+
+func NewWithConfig(model string, cfg agentTypes.ProviderConfig) (*Agent, error) {
 	usedModel := defaultModel
 	instanceName := ""
+	name := "compat@"
 
-	if len(model) > 0 && model[0] != "" {
-		raw := model[0]
+	if model != "" {
+		raw := model
 		if start := strings.Index(raw, "["); start != -1 {
 			if end := strings.Index(raw, "]"); end > start {
 				instanceName = strings.ToUpper(raw[start+1 : end])
+				name = raw[:end+1] + "@"
 			}
 		}
 		if at := strings.Index(raw, "@"); at != -1 {
@@ -45,24 +57,34 @@ func New(model ...string) (*Agent, error) {
 		apiKeyEnvKey = "COMPAT_" + instanceName + "_API_KEY"
 	}
 
-	baseURL := keychain.Get(urlEnvKey)
+	baseURL := cfg.Secret(urlEnvKey)
 	if baseURL == "" {
 		baseURL = defaultBaseURL
 	}
 	baseURL = strings.TrimRight(baseURL, "/")
 
-	apiKey := keychain.Get(apiKeyEnvKey)
+	apiKey := cfg.Secret(apiKeyEnvKey)
 
-	workDir, err := os.Getwd()
+	workDir, err := cfg.GetWorkDir()
 	if err != nil {
-		return nil, fmt.Errorf("os.Getwd: %w", err)
+		return nil, fmt.Errorf("cfg.GetWorkDir: %w", err)
 	}
 
 	return &Agent{
-		httpClient: &http.Client{},
-		model:      usedModel,
-		baseURL:    baseURL,
-		apiKey:     apiKey,
-		workDir:    workDir,
+		httpClient:    cfg.GetHTTPClient(),
+		name:          name + usedModel,
+		model:         usedModel,
+		contextWindow: exec.ContextWindow(usedModel, cfg.ContextWindow),
+		baseURL:       baseURL,
+		apiKey:        apiKey,
+		workDir:       workDir,
 	}, nil
 }
+
+func (a *Agent) ContextWindow() int {
+	return a.contextWindow
+}
+
+func (a *Agent) ModelName() string {
+	return a.name
+}
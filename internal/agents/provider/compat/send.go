@@ -12,10 +12,15 @@ import (
 )
 
 func (a *Agent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
-	return exec.Execute(ctx, a, a.workDir, skill, userInput, events, allowAll)
+	cfg, err := exec.NewConfig(a.workDir)
+	if err != nil {
+		return fmt.Errorf("exec.NewConfig: %w", err)
+	}
+	cfg.AllowAll = allowAll
+	return exec.Execute(ctx, cfg, a, skill, userInput, events)
 }
 
-func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.Tool) (*agentTypes.Output, error) {
+func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef) (*agentTypes.Output, error) {
 	chatAPI := a.baseURL + "/v1/chat/completions"
 
 	headers := map[string]string{
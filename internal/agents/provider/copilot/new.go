@@ -9,9 +9,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/pardnchiu/agenvoy/internal/utils"
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 )
 
 type Token struct {
@@ -22,12 +24,16 @@ type Token struct {
 }
 
 type Agent struct {
-	httpClient *http.Client
-	model      string
-	Token      *Token
-	Refresh    *RefreshToken
-	workDir    string
-	tokenDir   string
+	httpClient    *http.Client
+	model         string
+	contextWindow int
+	Token         *Token
+	workDir       string
+	tokenDir      string
+
+	// * selectSkill and selectAgent call Send from two goroutines
+	mu      sync.Mutex
+	Refresh *RefreshToken
 }
 
 const (
@@ -40,26 +46,35 @@ const (
 )
 
 func New(model ...string) (*Agent, error) {
+	name := ""
+	if len(model) > 0 {
+		name = model[0]
+	}
+	return NewWithConfig(name, agentTypes.ProviderConfig{})
+}
+
+func NewWithConfig(model string, cfg agentTypes.ProviderConfig) (*Agent, error) {
 	usedModel := defaultModel
-	if len(model) > 0 && strings.HasPrefix(model[0], prefix) {
-		usedModel = strings.TrimPrefix(model[0], prefix)
+	if strings.HasPrefix(model, prefix) {
+		usedModel = strings.TrimPrefix(model, prefix)
 	}
 
-	workDir, err := os.Getwd()
+	workDir, err := cfg.GetWorkDir()
 	if err != nil {
-		return nil, fmt.Errorf("os.Getwd: %w", err)
+		return nil, fmt.Errorf("cfg.GetWorkDir: %w", err)
 	}
 
-	configDir, err := utils.GetConfigDir()
+	configDir, err := cfg.GetConfigDir()
 	if err != nil {
-		return nil, fmt.Errorf("utils.ConfigDir(: %w", err)
+		return nil, fmt.Errorf("cfg.GetConfigDir: %w", err)
 	}
 
 	agent := &Agent{
-		httpClient: &http.Client{},
-		model:      usedModel,
-		workDir:    workDir,
-		tokenDir:   filepath.Join(configDir.Home, "copilot_token.json"),
+		httpClient:    cfg.GetHTTPClient(),
+		model:         usedModel,
+		contextWindow: exec.ContextWindow(usedModel, cfg.ContextWindow),
+		workDir:       workDir,
+		tokenDir:      filepath.Join(configDir, "copilot_token.json"),
 	}
 
 	var token *Token
@@ -88,3 +103,11 @@ func New(model ...string) (*Agent, error) {
 
 	return agent, nil
 }
+
+func (a *Agent) ContextWindow() int {
+	return a.contextWindow
+}
+
+func (a *Agent) ModelName() string {
+	return prefix + a.model
+}
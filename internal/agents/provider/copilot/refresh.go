@@ -2,6 +2,7 @@ package copilot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -18,31 +19,37 @@ type RefreshToken struct {
 	ExpiresAt int64  `json:"expires_at"`
 }
 
-func (c *Agent) checkExpires(ctx context.Context) error {
+// * returns the bearer token, refreshed under the lock so concurrent calls wait for a single refresh
+func (c *Agent) checkExpires(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.Refresh == nil || time.Now().Unix() >= c.Refresh.ExpiresAt-60 {
-		return c.refresh(ctx)
+		if err := c.refresh(ctx); err != nil {
+			return "", err
+		}
 	}
-	return nil
+	return c.Refresh.Token, nil
 }
 
 func (c *Agent) refresh(ctx context.Context) error {
-	token, code, err := utils.GET[RefreshToken](ctx, nil, copilotTokenAPI, map[string]string{
+	token, _, err := utils.GET[RefreshToken](ctx, c.httpClient, copilotTokenAPI, map[string]string{
 		"Authorization":  "token " + c.Token.AccessToken,
 		"Accept":         "application/json",
 		"Editor-Version": "vscode/1.95.0",
 	})
 	if err != nil {
+		var httpErr *utils.HTTPError
+		if errors.As(err, &httpErr) {
+			switch httpErr.StatusCode {
+			case http.StatusUnauthorized:
+				return fmt.Errorf("utils.GET: token expired: %w", err)
+			case http.StatusForbidden, http.StatusNotFound:
+				return fmt.Errorf("utils.GET: token refresh failed: %w", err)
+			}
+		}
 		return fmt.Errorf("utils.GET: %w", err)
 	}
-	if code == http.StatusUnauthorized {
-		return fmt.Errorf("utils.GET: token expired")
-	}
-	if code == http.StatusForbidden || code == http.StatusNotFound {
-		return fmt.Errorf("utils.GET: token refresh failed")
-	}
-	if code != http.StatusOK {
-		return fmt.Errorf("utils.GET: %d", code)
-	}
 
 	c.Refresh = &token
 
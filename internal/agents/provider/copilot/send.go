@@ -16,16 +16,22 @@ const (
 )
 
 func (a *Agent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
-	return exec.Execute(ctx, a, a.workDir, skill, userInput, events, allowAll)
+	cfg, err := exec.NewConfig(a.workDir)
+	if err != nil {
+		return fmt.Errorf("exec.NewConfig: %w", err)
+	}
+	cfg.AllowAll = allowAll
+	return exec.Execute(ctx, cfg, a, skill, userInput, events)
 }
 
-func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.Tool) (*agentTypes.Output, error) {
-	if err := a.checkExpires(ctx); err != nil {
+func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef) (*agentTypes.Output, error) {
+	token, err := a.checkExpires(ctx)
+	if err != nil {
 		return nil, fmt.Errorf("a.checkExpires: %w", err)
 	}
 
 	result, _, err := utils.POST[agentTypes.Output](ctx, a.httpClient, chatAPI, map[string]string{
-		"Authorization":  "Bearer " + a.Refresh.Token,
+		"Authorization":  "Bearer " + token,
 		"Editor-Version": "vscode/1.95.0",
 	}, map[string]any{
 		"model":    a.model,
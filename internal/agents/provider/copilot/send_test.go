@@ -0,0 +1,113 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func jsonResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// * token endpoint hands out tok_{n}, the chat endpoint echoes the bearer it got
+func newRefreshingAgent(refreshes *atomic.Int32) *Agent {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() == copilotTokenAPI {
+			n := refreshes.Add(1)
+			// * keeps the refresh open long enough for the other callers to arrive
+			time.Sleep(20 * time.Millisecond)
+			return jsonResponse(fmt.Sprintf(`{"token":"tok_%d","expires_at":%d}`, n, time.Now().Add(time.Hour).Unix())), nil
+		}
+		auth := req.Header.Get("Authorization")
+		return jsonResponse(fmt.Sprintf(`{"choices":[{"message":{"role":"assistant","content":%q}}]}`, auth)), nil
+	})}
+	return &Agent{
+		httpClient: client,
+		model:      defaultModel,
+		Token:      &Token{AccessToken: "gho_test"},
+	}
+}
+
+func TestSendConcurrentRefresh(t *testing.T) {
+	var refreshes atomic.Int32
+	agent := newRefreshingAgent(&refreshes)
+
+	// * like selectSkill and selectAgent sharing one selector
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := agent.Send(context.Background(), nil, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if got := out.Choices[0].Message.Content; got != "Bearer tok_1" {
+				errs <- fmt.Errorf("authorization = %v", got)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("refreshes = %d, want 1", n)
+	}
+}
+
+func TestSendRefreshExpired(t *testing.T) {
+	var refreshes atomic.Int32
+	agent := newRefreshingAgent(&refreshes)
+	agent.Refresh = &RefreshToken{Token: "old", ExpiresAt: time.Now().Add(30 * time.Second).Unix()}
+
+	// * within a minute of expiry the token is replaced before the request
+	out, err := agent.Send(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Choices[0].Message.Content; got != "Bearer tok_1" || refreshes.Load() != 1 {
+		t.Errorf("authorization = %v, refreshes = %d", got, refreshes.Load())
+	}
+}
+
+func TestRefreshUnauthorized(t *testing.T) {
+	agent := &Agent{
+		httpClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp := jsonResponse(`{"message":"Bad credentials"}`)
+			resp.StatusCode = http.StatusUnauthorized
+			return resp, nil
+		})},
+		Token: &Token{AccessToken: "gho_test"},
+	}
+
+	// * the status stays on the error, so a failover can tell an auth failure apart
+	_, err := agent.Send(context.Background(), nil, nil)
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+type embeddingOutput struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// * model is an embedding model, ex. gemini@gemini-embedding-001
+func (a *Agent) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	apiURL := fmt.Sprintf("%s%s:batchEmbedContents?key=%s", baseAPI, a.model, a.apiKey)
+
+	requests := make([]map[string]any, len(texts))
+	for i, text := range texts {
+		requests[i] = map[string]any{
+			"model": "models/" + a.model,
+			"content": map[string]any{
+				"parts": []map[string]string{{"text": text}},
+			},
+		}
+	}
+
+	result, _, err := utils.POST[embeddingOutput](ctx, a.httpClient, apiURL, map[string]string{
+		"Content-Type": "application/json",
+	}, map[string]any{
+		"requests": requests,
+	}, "json")
+	if err != nil {
+		return nil, fmt.Errorf("utils.POST: %w", err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("utils.POST: %s", result.Error.Message)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("utils.POST: %d embeddings for %d texts", len(result.Embeddings), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, e := range result.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
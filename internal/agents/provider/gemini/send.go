@@ -17,10 +17,28 @@ const (
 )
 
 func (a *Agent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
-	return exec.Execute(ctx, a, a.workDir, skill, userInput, events, allowAll)
+	cfg, err := exec.NewConfig(a.workDir)
+	if err != nil {
+		return fmt.Errorf("exec.NewConfig: %w", err)
+	}
+	cfg.AllowAll = allowAll
+	return exec.Execute(ctx, cfg, a, skill, userInput, events)
+}
+
+func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef) (*agentTypes.Output, error) {
+	apiURL := fmt.Sprintf("%s%s:generateContent?key=%s", baseAPI, a.model, a.apiKey)
+
+	result, _, err := utils.POST[Output](ctx, a.httpClient, apiURL, map[string]string{
+		"Content-Type": "application/json",
+	}, a.convertToRequestBody(messages, tools), "json")
+	if err != nil {
+		return nil, fmt.Errorf("utils.POST: %w", err)
+	}
+
+	return a.convertToOutput(&result), nil
 }
 
-func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.Tool) (*agentTypes.Output, error) {
+func (a *Agent) convertToRequestBody(messages []agentTypes.Message, tools []toolTypes.ToolDef) map[string]any {
 	var systemPrompt string
 	var newMessages []Content
 
@@ -36,18 +54,7 @@ func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools [
 		newMessages = append(newMessages, message)
 	}
 
-	newTools := a.convertToTools(tools)
-	apiURL := fmt.Sprintf("%s%s:generateContent?key=%s", baseAPI, a.model, a.apiKey)
-	requestBody := a.generateRequestBody(newMessages, systemPrompt, newTools)
-
-	result, _, err := utils.POST[Output](ctx, a.httpClient, apiURL, map[string]string{
-		"Content-Type": "application/json",
-	}, requestBody, "json")
-	if err != nil {
-		return nil, fmt.Errorf("utils.POST: %w", err)
-	}
-
-	return a.convertToOutput(&result), nil
+	return a.generateRequestBody(newMessages, systemPrompt, a.convertToTools(tools))
 }
 
 func (a *Agent) convertToContent(message agentTypes.Message) Content {
@@ -98,7 +105,7 @@ func (a *Agent) convertToContent(message agentTypes.Message) Content {
 	return content
 }
 
-func (a *Agent) convertToTools(tools []toolTypes.Tool) []map[string]any {
+func (a *Agent) convertToTools(tools []toolTypes.ToolDef) []map[string]any {
 	newTools := make([]map[string]any, len(tools))
 	for i, tool := range tools {
 		var params map[string]any
@@ -137,6 +144,7 @@ func (a *Agent) generateRequestBody(messages []Content, prompt string, newTools
 func (a *Agent) convertToOutput(resp *Output) *agentTypes.Output {
 	output := &agentTypes.Output{
 		Choices: make([]agentTypes.OutputChoices, 1),
+		Usage:   resp.UsageMetadata.convert(),
 	}
 
 	if len(resp.Candidates) == 0 {
@@ -0,0 +1,61 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func (a *Agent) Stream(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef, onDelta func(string)) (*agentTypes.Output, error) {
+	apiURL := fmt.Sprintf("%s%s:streamGenerateContent?alt=sse&key=%s", baseAPI, a.model, a.apiKey)
+
+	var builder agentTypes.StreamBuilder
+	// * gemini send every function call as a whole part, so each part is a new call
+	toolIndex := 0
+
+	_, err := utils.POSTStream(ctx, a.httpClient, apiURL, map[string]string{
+		"Content-Type": "application/json",
+	}, a.convertToRequestBody(messages, tools), func(_ string, data []byte) error {
+		var chunk Output
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		// * every chunk carries the running total
+		builder.SetUsage(chunk.UsageMetadata.convert())
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+
+		candidate := chunk.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" {
+				builder.AddText(part.Text)
+				if onDelta != nil {
+					onDelta(part.Text)
+				}
+			} else if part.FunctionCall != nil {
+				args := "{}"
+				if part.FunctionCall.Args != nil {
+					data, err := json.Marshal(part.FunctionCall.Args)
+					if err != nil {
+						continue
+					}
+					args = string(data)
+				}
+				builder.AddToolCall(toolIndex, part.FunctionCall.Name, part.FunctionCall.Name, args)
+				toolIndex++
+			}
+		}
+		builder.SetFinishReason(candidate.FinishReason)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("utils.POSTStream: %w", err)
+	}
+
+	return builder.Output(), nil
+}
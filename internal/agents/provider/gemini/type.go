@@ -1,5 +1,7 @@
 package gemini
 
+import agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+
 type Output struct {
 	Candidates []struct {
 		Content       Content `json:"content"`
@@ -9,11 +11,16 @@ type Output struct {
 			Probability string `json:"probability"`
 		} `json:"safetyRatings,omitempty"`
 	} `json:"candidates"`
-	UsageMetadata *struct {
-		PromptTokenCount     int `json:"promptTokenCount"`
-		CandidatesTokenCount int `json:"candidatesTokenCount"`
-		TotalTokenCount      int `json:"totalTokenCount"`
-	} `json:"usageMetadata,omitempty"`
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+}
+
+// * promptTokenCount includes cachedContentTokenCount, candidates exclude thoughtsTokenCount
+type UsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
 }
 
 type Content struct {
@@ -36,3 +43,15 @@ type FunctionResponse struct {
 	Name     string         `json:"name"`
 	Response map[string]any `json:"response"`
 }
+
+// * thinking tokens are billed as output
+func (u *UsageMetadata) convert() *agentTypes.Usage {
+	if u == nil {
+		return nil
+	}
+	return &agentTypes.Usage{
+		InputTokens:     u.PromptTokenCount - u.CachedContentTokenCount,
+		OutputTokens:    u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		CacheReadTokens: u.CachedContentTokenCount,
+	}
+}
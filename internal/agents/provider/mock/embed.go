@@ -0,0 +1,34 @@
+package mock
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const (
+	embeddingSize = 64
+)
+
+// * offline bag of words, texts sharing words point the same way
+func (a *Agent) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, embeddingSize)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%embeddingSize]++
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
@@ -0,0 +1,107 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+// * one scripted model response, given in order to each Send / Stream call
+type Reply struct {
+	Text      string            `json:"text,omitempty"`
+	ToolCalls []ToolCall        `json:"tool_calls,omitempty"`
+	Empty     bool              `json:"empty,omitempty"`   // * no choices at all
+	Status    int               `json:"status,omitempty"`  // * fail as an http error with this status
+	Partial   string            `json:"partial,omitempty"` // * streamed before a Status failure, ex. a connection dropped mid-answer
+	Usage     *agentTypes.Usage `json:"usage,omitempty"`
+}
+
+type ToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// * deterministic offline agent, no text and no tool calls answers with null content
+type Agent struct {
+	mu            sync.Mutex
+	name          string
+	replies       []Reply
+	next          int
+	calls         int
+	requests      [][]agentTypes.Message
+	contextWindow int
+	workDir       string
+}
+
+const (
+	prefix = "mock@"
+)
+
+func New(replies ...Reply) *Agent {
+	return &Agent{
+		name:          "script",
+		replies:       replies,
+		contextWindow: exec.DefaultContextWindow,
+	}
+}
+
+// * model is "mock@{script.json}", a JSON array of replies; a relative path is from the work dir
+func NewWithConfig(model string, cfg agentTypes.ProviderConfig) (*Agent, error) {
+	path := strings.TrimPrefix(model, prefix)
+	if path == "" || path == model {
+		return nil, fmt.Errorf("mock: script path is required, ex. mock@script.json")
+	}
+
+	workDir, err := cfg.GetWorkDir()
+	if err != nil {
+		return nil, fmt.Errorf("cfg.GetWorkDir: %w", err)
+	}
+
+	file := path
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(workDir, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	var replies []Reply
+	if err := json.Unmarshal(data, &replies); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return &Agent{
+		name:          path,
+		replies:       replies,
+		contextWindow: exec.ContextWindow("mock", cfg.ContextWindow),
+		workDir:       workDir,
+	}, nil
+}
+
+func (a *Agent) ContextWindow() int {
+	return a.contextWindow
+}
+
+func (a *Agent) ModelName() string {
+	return prefix + a.name
+}
+
+// * messages of every call so far, in call order
+func (a *Agent) Requests() [][]agentTypes.Message {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([][]agentTypes.Message(nil), a.requests...)
+}
+
+// * replies not yet given
+func (a *Agent) Remaining() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.replies) - a.next
+}
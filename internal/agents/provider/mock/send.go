@@ -0,0 +1,67 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func (a *Agent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
+	cfg, err := exec.NewConfig(a.workDir)
+	if err != nil {
+		return fmt.Errorf("exec.NewConfig: %w", err)
+	}
+	cfg.AllowAll = allowAll
+	return exec.Execute(ctx, cfg, a, skill, userInput, events)
+}
+
+func (a *Agent) Send(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef) (*agentTypes.Output, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests = append(a.requests, append([]agentTypes.Message(nil), messages...))
+	if a.next >= len(a.replies) {
+		return nil, fmt.Errorf("mock: script has only %d replies", len(a.replies))
+	}
+	reply := a.replies[a.next]
+	a.next++
+
+	if reply.Status != 0 {
+		return nil, fmt.Errorf("mock: %w", &utils.HTTPError{StatusCode: reply.Status, Body: reply.Text})
+	}
+
+	out := &agentTypes.Output{Usage: reply.Usage}
+	if reply.Empty {
+		return out, nil
+	}
+
+	message := agentTypes.Message{Role: "assistant"}
+	if reply.Text != "" {
+		message.Content = reply.Text
+	}
+	for _, call := range reply.ToolCalls {
+		a.calls++
+		toolCall := agentTypes.ToolCall{ID: fmt.Sprintf("call_%d", a.calls), Type: "function"}
+		toolCall.Function.Name = call.Name
+		toolCall.Function.Arguments = string(call.Arguments)
+		if toolCall.Function.Arguments == "" {
+			toolCall.Function.Arguments = "{}"
+		}
+		message.ToolCalls = append(message.ToolCalls, toolCall)
+	}
+	finish := "stop"
+	if len(message.ToolCalls) > 0 {
+		finish = "tool_calls"
+	}
+	out.Choices = []agentTypes.OutputChoices{{Message: message, FinishReason: finish}}
+	return out, nil
+}
@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+// * same reply as Send, the text arrives as a single delta
+func (a *Agent) Stream(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef, onDelta func(string)) (*agentTypes.Output, error) {
+	a.mu.Lock()
+	var partial string
+	if a.next < len(a.replies) {
+		partial = a.replies[a.next].Partial
+	}
+	a.mu.Unlock()
+
+	out, err := a.Send(ctx, messages, tools)
+	if err != nil {
+		if partial != "" && onDelta != nil {
+			onDelta(partial)
+		}
+		return nil, err
+	}
+	if len(out.Choices) > 0 && onDelta != nil {
+		if text, ok := out.Choices[0].Message.Content.(string); ok {
+			onDelta(text)
+		}
+	}
+	return out, nil
+}
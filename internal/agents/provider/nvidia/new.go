@@ -3,17 +3,18 @@ package nvidia
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/pardnchiu/agenvoy/internal/keychain"
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
 )
 
 type Agent struct {
-	httpClient *http.Client
-	model      string
-	apiKey     string
-	workDir    string
+	httpClient    *http.Client
+	model         string
+	contextWindow int
+	apiKey        string
+	workDir       string
 }
 
 const (
@@ -26,21 +27,41 @@ const (
 )
 
 func New(model ...string) (*Agent, error) {
+	name := ""
+	if len(model) > 0 {
+		name = model[0]
+	}
+	return NewWithConfig(name, agentTypes.ProviderConfig{})
+}
+
+func NewWithConfig(model string, cfg agentTypes.ProviderConfig) (*Agent, error) {
 	usedModel := defaultModel
-	if len(model) > 0 && strings.HasPrefix(model[0], prefix) {
-		usedModel = strings.TrimPrefix(model[0], prefix)
+	if strings.HasPrefix(model, prefix) {
+		usedModel = strings.TrimPrefix(model, prefix)
 	}
-	apiKey := keychain.Get("NVIDIA_API_KEY")
+	apiKey := cfg.Secret("NVIDIA_API_KEY")
 	if apiKey == "" {
-		return nil, fmt.Errorf("keychain.Get: NVIDIA_API_KEY is required")
+		return nil, fmt.Errorf("cfg.Secret: NVIDIA_API_KEY is required")
 	}
 
-	workDir, _ := os.Getwd()
+	workDir, err := cfg.GetWorkDir()
+	if err != nil {
+		return nil, fmt.Errorf("cfg.GetWorkDir: %w", err)
+	}
 
 	return &Agent{
-		httpClient: &http.Client{},
-		model:      usedModel,
-		apiKey:     apiKey,
-		workDir:    workDir,
+		httpClient:    cfg.GetHTTPClient(),
+		model:         usedModel,
+		contextWindow: exec.ContextWindow(usedModel, cfg.ContextWindow),
+		apiKey:        apiKey,
+		workDir:       workDir,
 	}, nil
 }
+
+func (a *Agent) ContextWindow() int {
+	return a.contextWindow
+}
+
+func (a *Agent) ModelName() string {
+	return prefix + a.model
+}
@@ -0,0 +1,45 @@
+package nvidia
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func (a *Agent) Stream(ctx context.Context, messages []agentTypes.Message, tools []toolTypes.ToolDef, onDelta func(string)) (*agentTypes.Output, error) {
+	var builder agentTypes.StreamBuilder
+
+	_, err := utils.POSTStream(ctx, a.httpClient, chatAPI, map[string]string{
+		"Authorization": "Bearer " + a.apiKey,
+		"Content-Type":  "application/json",
+	}, map[string]any{
+		"model":    a.model,
+		"messages": messages,
+		"tools":    tools,
+		"stream":   true,
+		"stream_options": map[string]any{
+			"include_usage": true,
+		},
+	}, func(_ string, data []byte) error {
+		var chunk agentTypes.StreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("chunk.Error: %s", chunk.Error.Message)
+		}
+		if text := builder.AddChunk(&chunk); text != "" && onDelta != nil {
+			onDelta(text)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("utils.POSTStream: %w", err)
+	}
+
+	return builder.Output(), nil
+}
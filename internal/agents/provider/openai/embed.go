@@ -0,0 +1,33 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+const (
+	embeddingAPI = "https://api.openai.com/v1/embeddings"
+)
+
+// * model is an embedding model, ex. openai@text-embedding-3-small
+func (a *Agent) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	result, _, err := utils.POST[agentTypes.EmbeddingOutput](ctx, a.httpClient, embeddingAPI, map[string]string{
+		"Authorization": "Bearer " + a.apiKey,
+		"Content-Type":  "application/json",
+	}, map[string]any{
+		"model": a.model,
+		"input": texts,
+	}, "json")
+	if err != nil {
+		return nil, fmt.Errorf("utils.POST: %w", err)
+	}
+
+	vectors, err := result.Vectors(len(texts))
+	if err != nil {
+		return nil, fmt.Errorf("utils.POST: %w", err)
+	}
+	return vectors, nil
+}
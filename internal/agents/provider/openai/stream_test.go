@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/replay"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func TestStreamReplay(t *testing.T) {
+	player, err := replay.Open("testdata/stream.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := NewWithConfig("openai@gpt-5-mini", agentTypes.ProviderConfig{
+		WorkDir:    t.TempDir(),
+		Secrets:    func(string) string { return "test-key" },
+		HTTPClient: &http.Client{Transport: player},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	messages := []agentTypes.Message{{Role: "user", Content: "what is in notes.md"}}
+
+	// * tool call arguments arrive in pieces
+	out, err := agent.Stream(ctx, messages, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := out.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_Xy1" || calls[0].Function.Name != "read_file" || calls[0].Function.Arguments != `{"path":"notes.md"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if want := (agentTypes.Usage{InputTokens: 226, OutputTokens: 18, CacheReadTokens: 1024}); out.Usage == nil || *out.Usage != want {
+		t.Errorf("usage = %+v", out.Usage)
+	}
+
+	var deltas []string
+	out, err = agent.Stream(ctx, messages, nil, func(text string) { deltas = append(deltas, text) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := out.Choices[0].Message.Content.(string); text != "The notes list three tasks." || strings.Join(deltas, "|") != "The notes| list three tasks." {
+		t.Errorf("text = %q, deltas = %q", text, deltas)
+	}
+
+	// * a rate limit surfaces with its Retry-After
+	_, err = agent.Stream(ctx, messages, nil, nil)
+	perr := agentTypes.NewProviderError(agent.ModelName(), err)
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) || perr.Kind != agentTypes.ErrRateLimit || perr.RetryAfter != 20*time.Second {
+		t.Errorf("err = %v", err)
+	}
+	if unused := player.Unused(); len(unused) != 0 {
+		t.Errorf("unused = %v", unused)
+	}
+}
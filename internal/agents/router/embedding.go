@@ -0,0 +1,228 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+// * "embedding" in config.json, ex. {"model": "compat@nomic-embed-text"}
+type EmbeddingConfig struct {
+	Model    string  `json:"model"`
+	MinScore float64 `json:"min_score,omitempty"` // * best candidate below it means no match
+	Margin   float64 `json:"margin,omitempty"`    // * candidates this close to the best are a tie for the LLM
+	TopK     int     `json:"top_k,omitempty"`     // * most candidates in a tie
+}
+
+const (
+	defaultMinScore = 0.3
+	defaultMargin   = 0.05
+	defaultTopK     = 5
+	embedBatch      = 64
+)
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type Candidate struct {
+	Name string
+	Text string // * what is embedded, ex. the skill description
+	Hash string // * cache key, ex. Skill.Hash; empty hashes Name and Text
+}
+
+type Match struct {
+	Name  string
+	Score float64
+}
+
+// * ranks candidates by cosine similarity to the input; candidate vectors are
+// * embedded once and cached under {dir}/embeddings/{model}.json
+type Embedding struct {
+	embedder agentTypes.Embedder
+	cfg      EmbeddingConfig
+	path     string
+
+	mu     sync.Mutex
+	loaded bool
+	sets   map[string]map[string][]float32 // * set name to hash to vector
+}
+
+func NewEmbedding(embedder agentTypes.Embedder, cfg EmbeddingConfig, dir string) *Embedding {
+	if cfg.MinScore == 0 {
+		cfg.MinScore = defaultMinScore
+	}
+	if cfg.Margin == 0 {
+		cfg.Margin = defaultMargin
+	}
+	if cfg.TopK <= 0 {
+		cfg.TopK = defaultTopK
+	}
+	return &Embedding{
+		embedder: embedder,
+		cfg:      cfg,
+		path:     filepath.Join(dir, "embeddings", unsafeName.ReplaceAllString(cfg.Model, "_")+".json"),
+		sets:     make(map[string]map[string][]float32),
+	}
+}
+
+// * candidates worth choosing from, best first: none when the best is below MinScore,
+// * one when it is clear of the rest by Margin, otherwise up to TopK close ones
+func (e *Embedding) Shortlist(ctx context.Context, set, input string, candidates []Candidate) ([]Match, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	vectors, err := e.vectors(ctx, set, candidates)
+	if err != nil {
+		return nil, err
+	}
+	query, err := e.embedder.Embed(ctx, []string{input})
+	if err != nil {
+		return nil, fmt.Errorf("embedder.Embed: %w", err)
+	}
+	if len(query) != 1 {
+		return nil, fmt.Errorf("embedder.Embed: %d vectors for 1 text", len(query))
+	}
+
+	matches := make([]Match, len(candidates))
+	for i, c := range candidates {
+		matches[i] = Match{Name: c.Name, Score: cosine(query[0], vectors[i])}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	best := matches[0].Score
+	if best < e.cfg.MinScore {
+		return nil, nil
+	}
+	n := 1
+	for n < len(matches) && n < e.cfg.TopK && best-matches[n].Score <= e.cfg.Margin {
+		n++
+	}
+	return matches[:n], nil
+}
+
+// * cached vectors in candidate order; missing ones are embedded in batches and the set
+// * is rewritten with only the current candidates, so removed or edited entries drop out
+func (e *Embedding) vectors(ctx context.Context, set string, candidates []Candidate) ([][]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.loaded {
+		if err := e.load(); err != nil {
+			return nil, err
+		}
+		e.loaded = true
+	}
+	cached := e.sets[set]
+
+	keys := make([]string, len(candidates))
+	var missing []int
+	for i, c := range candidates {
+		keys[i] = c.Hash
+		if keys[i] == "" {
+			keys[i] = fmt.Sprintf("%x", sha256.Sum256([]byte(c.Name+"\n"+c.Text)))
+		}
+		if _, ok := cached[keys[i]]; !ok {
+			missing = append(missing, i)
+		}
+	}
+
+	next := make(map[string][]float32, len(candidates))
+	for i := range candidates {
+		if v, ok := cached[keys[i]]; ok {
+			next[keys[i]] = v
+		}
+	}
+	for start := 0; start < len(missing); start += embedBatch {
+		batch := missing[start:min(start+embedBatch, len(missing))]
+		texts := make([]string, len(batch))
+		for j, i := range batch {
+			texts[j] = candidates[i].Name + ": " + candidates[i].Text
+		}
+		embedded, err := e.embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("embedder.Embed: %w", err)
+		}
+		if len(embedded) != len(batch) {
+			return nil, fmt.Errorf("embedder.Embed: %d vectors for %d texts", len(embedded), len(batch))
+		}
+		for j, i := range batch {
+			next[keys[i]] = embedded[j]
+		}
+	}
+
+	if len(missing) > 0 || len(next) != len(cached) {
+		e.sets[set] = next
+		if err := e.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	vectors := make([][]float32, len(candidates))
+	for i := range candidates {
+		vectors[i] = next[keys[i]]
+	}
+	return vectors, nil
+}
+
+func (e *Embedding) load() error {
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+	// * a broken cache is rebuilt rather than failing the run
+	if json.Unmarshal(data, &e.sets) != nil || e.sets == nil {
+		e.sets = make(map[string]map[string][]float32)
+	}
+	return nil
+}
+
+func (e *Embedding) save() error {
+	if err := os.MkdirAll(filepath.Dir(e.path), 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	data, err := json.Marshal(e.sets)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	// * written aside then renamed, so a concurrent run never reads half a file
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	if err := os.Rename(tmp, e.path); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+	return nil
+}
+
+// * zero when either vector is empty or the sizes differ, ex. after switching models
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,134 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// * fixed vectors per text, texts not listed embed to the zero vector
+type stubEmbedder struct {
+	vectors map[string][]float32
+	texts   []string
+	err     error
+}
+
+func (s *stubEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.texts = append(s.texts, texts...)
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = s.vectors[text]
+		if out[i] == nil {
+			out[i] = []float32{0, 0, 0}
+		}
+	}
+	return out, nil
+}
+
+func names(matches []Match) string {
+	list := make([]string, len(matches))
+	for i, m := range matches {
+		list[i] = m.Name
+	}
+	return strings.Join(list, ",")
+}
+
+func TestShortlist(t *testing.T) {
+	embedder := &stubEmbedder{vectors: map[string][]float32{
+		"stock: fetch quotes":     {1, 0, 0},
+		"finance: market data":    {0.98, 0.2, 0},
+		"changelog: write notes":  {0, 1, 0},
+		"price of 2330":           {1, 0.05, 0},
+		"draft the release notes": {0.1, 1, 0},
+		"weather tomorrow":        {0, 0, 1},
+	}}
+	candidates := []Candidate{
+		{Name: "stock", Text: "fetch quotes", Hash: "h1"},
+		{Name: "finance", Text: "market data", Hash: "h2"},
+		{Name: "changelog", Text: "write notes", Hash: "h3"},
+	}
+	dir := t.TempDir()
+	e := NewEmbedding(embedder, EmbeddingConfig{Model: "compat@nomic-embed-text"}, dir)
+	ctx := context.Background()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// * two close scores are a tie for the LLM, best first
+		{"price of 2330", "stock,finance"},
+		// * clear winner, no LLM needed
+		{"draft the release notes", "changelog"},
+		// * nothing similar enough
+		{"weather tomorrow", ""},
+	}
+	for _, tt := range tests {
+		matches, err := e.Shortlist(ctx, "skills", tt.input, candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(matches); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+	// * candidates embedded once, then only the inputs
+	if len(embedder.texts) != 6 {
+		t.Errorf("embedded %q", embedder.texts)
+	}
+
+	// * cache survives a restart, an edited candidate is embedded again and the old entry dropped
+	embedder.texts = nil
+	candidates[2] = Candidate{Name: "changelog", Text: "write notes", Hash: "h4"}
+	e = NewEmbedding(embedder, EmbeddingConfig{Model: "compat@nomic-embed-text"}, dir)
+	if _, err := e.Shortlist(ctx, "skills", "price of 2330", candidates); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(embedder.texts, "|") != "changelog: write notes|price of 2330" {
+		t.Errorf("embedded %q", embedder.texts)
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sets map[string]map[string][]float32
+	if err := json.Unmarshal(data, &sets); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sets["skills"]["h3"]; ok || len(sets["skills"]) != 3 {
+		t.Errorf("cached hashes = %v", sets["skills"])
+	}
+
+	// * a narrower top_k caps the tie
+	e = NewEmbedding(embedder, EmbeddingConfig{Model: "compat@nomic-embed-text", TopK: 1}, dir)
+	if matches, _ := e.Shortlist(ctx, "skills", "price of 2330", candidates); names(matches) != "stock" {
+		t.Errorf("top_k 1 = %v", matches)
+	}
+
+	embedder.err = errors.New("connection refused")
+	e = NewEmbedding(embedder, EmbeddingConfig{Model: "compat@other"}, dir)
+	if _, err := e.Shortlist(ctx, "skills", "price of 2330", candidates); err == nil {
+		t.Error("embedder error not returned")
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := cosine([]float32{1, 2}, []float32{2, 4}); got < 0.999 {
+		t.Errorf("parallel = %f", got)
+	}
+	if got := cosine([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("orthogonal = %f", got)
+	}
+	// * vectors from another model are never similar
+	if got := cosine([]float32{1, 0}, []float32{1, 0, 0}); got != 0 {
+		t.Errorf("size mismatch = %f", got)
+	}
+	if got := cosine([]float32{0, 0}, []float32{1, 0}); got != 0 {
+		t.Errorf("zero = %f", got)
+	}
+}
@@ -0,0 +1,175 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * one entry of "routes" in routes.json; its conditions must all hold, none means always
+type Rule struct {
+	Regex    string   `json:"regex,omitempty"`
+	Keywords []string `json:"keywords,omitempty"` // * any of them, case-insensitive
+	Cwd      string   `json:"cwd,omitempty"`      // * glob on the work dir or one of its parents, ~ is home
+
+	Skill       string   `json:"skill,omitempty"`
+	Agent       string   `json:"agent,omitempty"`
+	NeverSkills []string `json:"never_skills,omitempty"`
+	NeverAgents []string `json:"never_agents,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+type Rules struct {
+	rules []Rule
+}
+
+// * what the rules matching one input decided; empty Skill / Agent leaves it to the selector
+type Route struct {
+	Skill       string
+	Agent       string
+	neverSkills map[string]bool
+	neverAgents map[string]bool
+}
+
+// * routes.json of the work config dir, then of the home one; missing files are no rules
+func LoadRules(configDir *utils.ConfigDirData) (*Rules, error) {
+	dirs := []string{configDir.Work}
+	if configDir.Home != configDir.Work {
+		dirs = append(dirs, configDir.Home)
+	}
+
+	rules := &Rules{}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, "routes.json")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("os.ReadFile: %w", err)
+		}
+		var file struct {
+			Routes []Rule `json:"routes"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal %s: %w", path, err)
+		}
+		compiled, err := NewRules(file.Routes...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		rules.rules = append(rules.rules, compiled.rules...)
+	}
+	return rules, nil
+}
+
+// * regexes are compiled here, a bad one is an error
+func NewRules(rules ...Rule) (*Rules, error) {
+	for i := range rules {
+		if rules[i].Regex == "" {
+			continue
+		}
+		re, err := regexp.Compile(rules[i].Regex)
+		if err != nil {
+			return nil, fmt.Errorf("regexp.Compile route %d: %w", i, err)
+		}
+		rules[i].regex = re
+	}
+	return &Rules{rules: rules}, nil
+}
+
+// * the first matching rule naming an allowed skill or agent wins it,
+// * never lists of every matching rule add up
+func (r *Rules) Match(input, workDir string) Route {
+	route := Route{
+		neverSkills: make(map[string]bool),
+		neverAgents: make(map[string]bool),
+	}
+	if r == nil {
+		return route
+	}
+
+	var matched []Rule
+	for _, rule := range r.rules {
+		if !rule.matches(input, workDir) {
+			continue
+		}
+		matched = append(matched, rule)
+		for _, name := range rule.NeverSkills {
+			route.neverSkills[strings.TrimSpace(name)] = true
+		}
+		for _, name := range rule.NeverAgents {
+			route.neverAgents[strings.TrimSpace(name)] = true
+		}
+	}
+	for _, rule := range matched {
+		if name := strings.TrimSpace(rule.Skill); route.Skill == "" && name != "" && route.AllowSkill(name) {
+			route.Skill = name
+		}
+		if name := strings.TrimSpace(rule.Agent); route.Agent == "" && name != "" && route.AllowAgent(name) {
+			route.Agent = name
+		}
+	}
+	return route
+}
+
+func (r Route) AllowSkill(name string) bool {
+	return !r.neverSkills[name]
+}
+
+func (r Route) AllowAgent(name string) bool {
+	return !r.neverAgents[name]
+}
+
+func (rule Rule) matches(input, workDir string) bool {
+	if rule.regex != nil && !rule.regex.MatchString(input) {
+		return false
+	}
+	if len(rule.Keywords) > 0 {
+		lower := strings.ToLower(input)
+		found := false
+		for _, keyword := range rule.Keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(lower, keyword) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.Cwd != "" && !matchDir(rule.Cwd, workDir) {
+		return false
+	}
+	return true
+}
+
+func matchDir(pattern, workDir string) bool {
+	if workDir == "" {
+		return false
+	}
+	if strings.HasPrefix(pattern, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			pattern = filepath.Join(home, pattern[2:])
+		}
+	}
+	pattern = filepath.Clean(pattern)
+
+	for dir := filepath.Clean(workDir); ; {
+		if ok, _ := filepath.Match(pattern, dir); ok {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func TestRules(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := filepath.Join(home, "work", "finance-app", "src")
+	configDir, err := utils.NewConfigDir(filepath.Join(home, "agenvoy"), utils.ProjectDir(workDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// * project rules come before home rules
+	write := func(dir, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "routes.json"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(configDir.Work, `{"routes": [
+		{"regex": "\\b\\d{4}\\.TW\\b", "skill": "fetch-finance"},
+		{"regex": "^/changelog", "skill": "changelog-generate", "agent": "claude@claude-sonnet-4-5"}
+	]}`)
+	write(configDir.Home, `{"routes": [
+		{"regex": "\\b\\d{4}\\.TW\\b", "skill": "other", "agent": "openai@gpt-5-mini"},
+		{"keywords": ["Weather", "天氣"], "agent": "compat@qwen3:8b"},
+		{"cwd": "~/work/*", "never_agents": ["claude@claude-sonnet-4-5"]},
+		{"never_skills": ["experimental"]}
+	]}`)
+	rules, err := LoadRules(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input   string
+		workDir string
+		skill   string
+		agent   string
+	}{
+		// * skill from the first rule, agent from a later one
+		{"price of 2330.TW today", workDir, "fetch-finance", "openai@gpt-5-mini"},
+		// * routed agent is excluded under ~/work
+		{"/changelog for v1.2", workDir, "changelog-generate", ""},
+		{"/changelog for v1.2", home, "changelog-generate", "claude@claude-sonnet-4-5"},
+		{"明天天氣如何", home, "", "compat@qwen3:8b"},
+		{"what is the WEATHER", home, "", "compat@qwen3:8b"},
+		{"hello", home, "", ""},
+	}
+	for _, tt := range tests {
+		route := rules.Match(tt.input, tt.workDir)
+		if route.Skill != tt.skill || route.Agent != tt.agent {
+			t.Errorf("%s in %s = %q / %q, want %q / %q", tt.input, tt.workDir, route.Skill, route.Agent, tt.skill, tt.agent)
+		}
+	}
+
+	route := rules.Match("hello", workDir)
+	if route.AllowSkill("experimental") || !route.AllowSkill("fetch-finance") || route.AllowAgent("claude@claude-sonnet-4-5") {
+		t.Errorf("never lists = %+v", route)
+	}
+	if !rules.Match("hello", home).AllowAgent("claude@claude-sonnet-4-5") {
+		t.Error("cwd rule matched outside ~/work")
+	}
+
+	// * no file is no rules, a nil Rules matches nothing
+	empty, err := LoadRules(&utils.ConfigDirData{Home: t.TempDir(), Work: t.TempDir()})
+	if err != nil || len(empty.rules) != 0 {
+		t.Errorf("empty = %v, %v", empty, err)
+	}
+	var none *Rules
+	if route := none.Match("x", workDir); route.Skill != "" || !route.AllowSkill("x") {
+		t.Errorf("nil rules = %+v", route)
+	}
+
+	write(configDir.Work, `{"routes": [{"regex": "(", "skill": "x"}]}`)
+	if _, err := LoadRules(configDir); err == nil || !strings.Contains(err.Error(), "route 0") {
+		t.Errorf("bad regex = %v", err)
+	}
+}
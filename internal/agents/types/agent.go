@@ -8,7 +8,8 @@ import (
 )
 
 type Agent interface {
-	Send(ctx context.Context, messages []Message, toolDefs []toolTypes.Tool) (*Output, error)
+	Send(ctx context.Context, messages []Message, toolDefs []toolTypes.ToolDef) (*Output, error)
+	Stream(ctx context.Context, messages []Message, toolDefs []toolTypes.ToolDef, onDelta func(text string)) (*Output, error)
 	Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- Event, allowAll bool) error
 }
 
@@ -16,11 +17,51 @@ type AgentRegistry struct {
 	Registry map[string]Agent
 	Entries  []AgentEntry
 	Fallback Agent
+	// * names tried in order when the chosen agent keeps failing, nil means Entries order, empty disables failover
+	Failover []string
+}
+
+// * chosen first, empty means the fallback, then the failover order; unknown names are skipped
+func (r AgentRegistry) Chain(chosen string) []string {
+	if chosen == "" && len(r.Entries) > 0 {
+		chosen = r.Entries[0].Name
+	}
+	order := r.Failover
+	if order == nil {
+		order = make([]string, 0, len(r.Entries))
+		for _, e := range r.Entries {
+			order = append(order, e.Name)
+		}
+	}
+
+	var chain []string
+	seen := make(map[string]bool)
+	for _, name := range append([]string{chosen}, order...) {
+		if _, ok := r.Registry[name]; !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		chain = append(chain, name)
+	}
+	return chain
+}
+
+// * agents that know the context size of their model, see exec.ContextWindow
+type ContextSizer interface {
+	ContextWindow() int
+}
+
+// * agents whose provider has an embeddings endpoint, one vector per text in order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
 }
 
 type AgentEntry struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	ContextWindow int    `json:"context_window,omitempty"` // * tokens, zero picks from the model name
+	// * claude extended thinking, tokens; zero leaves thinking off
+	ThinkingBudget int `json:"thinking_budget,omitempty"`
 }
 
 type AgentSession struct {
@@ -28,4 +69,5 @@ type AgentSession struct {
 	Tools     []Message
 	Messages  []Message
 	Histories []Message
+	Saved     int // * leading Histories entries already in the session store
 }
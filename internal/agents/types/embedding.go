@@ -0,0 +1,33 @@
+package agentTypes
+
+import "fmt"
+
+// * OpenAI-style /v1/embeddings response, shared by the compatible providers
+type EmbeddingOutput struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// * vectors in input order, n is the number of texts sent
+func (o *EmbeddingOutput) Vectors(n int) ([][]float32, error) {
+	if o.Error != nil {
+		return nil, fmt.Errorf("%s", o.Error.Message)
+	}
+	if len(o.Data) != n {
+		return nil, fmt.Errorf("%d embeddings for %d texts", len(o.Data), n)
+	}
+
+	vectors := make([][]float32, n)
+	for _, d := range o.Data {
+		if d.Index < 0 || d.Index >= n || vectors[d.Index] != nil {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,123 @@
+package agentTypes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+type ErrorKind int
+
+const (
+	ErrOther      ErrorKind = iota
+	ErrRateLimit            // * 429, or a rate limit error inside a stream
+	ErrOverloaded           // * 5xx, 529, or an overloaded error inside a stream
+	ErrTimeout              // * request timeout, 408
+	ErrNetwork              // * connection refused / reset, stream cut short
+	ErrAuth                 // * 401, 403
+	ErrRequest              // * other 4xx, the request itself is rejected
+)
+
+var errorKindNames = [...]string{
+	ErrOther:      "failed",
+	ErrRateLimit:  "rate limited",
+	ErrOverloaded: "overloaded",
+	ErrTimeout:    "timed out",
+	ErrNetwork:    "unreachable",
+	ErrAuth:       "unauthorized",
+	ErrRequest:    "rejected the request",
+}
+
+func (k ErrorKind) String() string {
+	if k >= 0 && int(k) < len(errorKindNames) {
+		return errorKindNames[k]
+	}
+	return fmt.Sprintf("error(%d)", int(k))
+}
+
+// * error of a Send / Stream call, classified so exec can retry or move to the next agent
+type ProviderError struct {
+	Model      string // * registry name, ex. "claude@claude-sonnet-4-5"
+	Kind       ErrorKind
+	StatusCode int           // * zero when there was no response
+	RetryAfter time.Duration // * zero when the provider did not ask for a wait
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Model == "" {
+		return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Model, e.Kind, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// * worth another try on the same agent
+func (e *ProviderError) Retryable() bool {
+	switch e.Kind {
+	case ErrRateLimit, ErrOverloaded, ErrTimeout, ErrNetwork:
+		return true
+	}
+	return false
+}
+
+// * another agent may succeed where this one failed; rejected requests would fail the same way
+func (e *ProviderError) Failover() bool {
+	return e.Retryable() || e.Kind == ErrAuth
+}
+
+// * wraps err as a ProviderError, keeping the kind when err already is one
+func NewProviderError(model string, err error) *ProviderError {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		if pe.Model == "" {
+			pe.Model = model
+		}
+		return pe
+	}
+
+	pe = &ProviderError{Model: model, Kind: ErrOther, Err: err}
+	var httpErr *utils.HTTPError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &httpErr):
+		pe.StatusCode = httpErr.StatusCode
+		pe.RetryAfter = httpErr.RetryAfter
+		pe.Kind = statusKind(httpErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		pe.Kind = ErrTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		pe.Kind = ErrTimeout
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED):
+		pe.Kind = ErrNetwork
+	case errors.As(err, &netErr):
+		pe.Kind = ErrNetwork
+	}
+	return pe
+}
+
+func statusKind(code int) ErrorKind {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrRateLimit
+	case code == http.StatusRequestTimeout:
+		return ErrTimeout
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return ErrAuth
+	case code >= 500:
+		return ErrOverloaded
+	case code >= 400:
+		return ErrRequest
+	}
+	return ErrOther
+}
@@ -1,5 +1,7 @@
 package agentTypes
 
+import "fmt"
+
 type EventType int
 
 const (
@@ -15,8 +17,12 @@ const (
 	EventToolResult
 	EventToolSkipped
 	EventToolConfirm
+	EventCompact
+	EventRetry
+	EventFailover
 	EventError
 	EventDone
+	EventTextDelta
 )
 
 type Event struct {
@@ -26,6 +32,51 @@ type Event struct {
 	ToolArgs string    `json:"tool_args,omitempty"`
 	ToolID   string    `json:"tool_id,omitempty"`
 	Result   string    `json:"result,omitempty"`
-	Err      error     `json:"-"`
-	ReplyCh  chan bool `json:"-"`
+	// * EventDone only, selector calls and every iteration of the run
+	Usage   []ModelUsage `json:"usage,omitempty"`
+	Err     error        `json:"-"`
+	ReplyCh chan bool    `json:"-"`
+}
+
+var eventNames = [...]string{
+	EventText:          "text",
+	EventAgentSelect:   "agent_select",
+	EventAgentResult:   "agent_result",
+	EventSkillSelect:   "skill_select",
+	EventSkillResult:   "skill_result",
+	EventToolCall:      "tool_call",
+	EventToolCallStart: "tool_call_start",
+	EventToolCallText:  "tool_call_text",
+	EventToolCallEnd:   "tool_call_end",
+	EventToolResult:    "tool_result",
+	EventToolSkipped:   "tool_skipped",
+	EventToolConfirm:   "tool_confirm",
+	EventCompact:       "compact",
+	EventRetry:         "retry",
+	EventFailover:      "failover",
+	EventError:         "error",
+	EventDone:          "done",
+	EventTextDelta:     "text_delta",
+}
+
+func (t EventType) String() string {
+	if t >= 0 && int(t) < len(eventNames) {
+		return eventNames[t]
+	}
+	return fmt.Sprintf("event(%d)", int(t))
+}
+
+// * events are encoded by name, ex. "tool_confirm"
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *EventType) UnmarshalText(data []byte) error {
+	for i, name := range eventNames {
+		if name == string(data) {
+			*t = EventType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type: %s", data)
 }
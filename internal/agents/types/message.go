@@ -7,6 +7,16 @@ type Message struct {
 	Content    any        `json:"content,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// * provider reasoning sent back within the run only, never stored or sent to another provider
+	Thinking []ThinkingBlock `json:"-"`
+}
+
+// * ex. a claude thinking block and its signature, or a redacted one holding only data
+type ThinkingBlock struct {
+	Type      string
+	Thinking  string
+	Signature string
+	Data      string
 }
 
 type ToolCall struct {
@@ -20,6 +30,7 @@ type ToolCall struct {
 
 type Output struct {
 	Choices []OutputChoices `json:"choices"`
+	Usage   *Usage          `json:"usage,omitempty"`
 	Error   *struct {
 		Message string      `json:"message"`
 		Type    string      `json:"type"`
@@ -0,0 +1,61 @@
+package agentTypes
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/pardnchiu/agenvoy/internal/keychain"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * zero value falls back to keychain, os.Getwd and ~/.config/agenvoy
+type ProviderConfig struct {
+	Secrets    func(key string) string
+	WorkDir    string
+	ConfigDir  string
+	HTTPClient *http.Client
+	// * tokens, zero picks from the model name
+	ContextWindow int
+	// * tokens of extended thinking, providers without it ignore it
+	ThinkingBudget int
+}
+
+func (c ProviderConfig) Secret(key string) string {
+	if c.Secrets != nil {
+		return c.Secrets(key)
+	}
+	return keychain.Get(key)
+}
+
+func (c ProviderConfig) GetWorkDir() (string, error) {
+	if c.WorkDir != "" {
+		return c.WorkDir, nil
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("os.Getwd: %w", err)
+	}
+	return workDir, nil
+}
+
+func (c ProviderConfig) GetConfigDir() (string, error) {
+	if c.ConfigDir != "" {
+		if err := os.MkdirAll(c.ConfigDir, 0755); err != nil {
+			return "", fmt.Errorf("os.MkdirAll: %w", err)
+		}
+		return c.ConfigDir, nil
+	}
+	configDir, err := utils.GetConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("utils.GetConfigDir: %w", err)
+	}
+	return configDir.Home, nil
+}
+
+func (c ProviderConfig) GetHTTPClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return utils.NewHTTPClient()
+}
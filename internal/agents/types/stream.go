@@ -0,0 +1,151 @@
+package agentTypes
+
+import (
+	"sort"
+	"strings"
+)
+
+// * chat completions chunk (openai / copilot / nvidia / compat)
+type StreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	// * only in the last chunk, with stream_options.include_usage
+	Usage *Usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// * collect streamed fragments back into a single Output
+type StreamBuilder struct {
+	text         strings.Builder
+	toolCalls    map[int]*ToolCall
+	finishReason string
+	usage        *Usage
+	received     bool
+}
+
+func (b *StreamBuilder) AddText(text string) {
+	if text == "" {
+		return
+	}
+	b.received = true
+	b.text.WriteString(text)
+}
+
+// * fragments with the same index belong to the same call, id and name only arrive once
+func (b *StreamBuilder) AddToolCall(index int, id, name, args string) {
+	b.received = true
+	if b.toolCalls == nil {
+		b.toolCalls = make(map[int]*ToolCall)
+	}
+	call, ok := b.toolCalls[index]
+	if !ok {
+		call = &ToolCall{Type: "function"}
+		b.toolCalls[index] = call
+	}
+	if id != "" {
+		call.ID = id
+	}
+	if name != "" && call.Function.Name == "" {
+		call.Function.Name = name
+	}
+	call.Function.Arguments += args
+}
+
+func (b *StreamBuilder) SetFinishReason(reason string) {
+	if reason == "" {
+		return
+	}
+	b.received = true
+	b.finishReason = reason
+}
+
+// * providers report usage in parts (claude input first, output last), so it adds up
+func (b *StreamBuilder) AddUsage(usage *Usage) {
+	if usage == nil {
+		return
+	}
+	if b.usage == nil {
+		b.usage = &Usage{}
+	}
+	b.usage.Add(usage)
+}
+
+// * for providers that resend the running total in every chunk (gemini)
+func (b *StreamBuilder) SetUsage(usage *Usage) {
+	if usage == nil {
+		return
+	}
+	u := *usage
+	b.usage = &u
+}
+
+// * return text delta of the chunk for display
+func (b *StreamBuilder) AddChunk(chunk *StreamChunk) string {
+	var delta strings.Builder
+	for _, choice := range chunk.Choices {
+		b.AddText(choice.Delta.Content)
+		delta.WriteString(choice.Delta.Content)
+
+		for _, tool := range choice.Delta.ToolCalls {
+			b.AddToolCall(tool.Index, tool.ID, tool.Function.Name, tool.Function.Arguments)
+		}
+		b.SetFinishReason(choice.FinishReason)
+	}
+	b.AddUsage(chunk.Usage)
+	return delta.String()
+}
+
+func (b *StreamBuilder) Text() string {
+	return b.text.String()
+}
+
+func (b *StreamBuilder) Output() *Output {
+	// * nothing received, keep empty choices for the retry path in Execute
+	if !b.received {
+		return &Output{Usage: b.usage}
+	}
+
+	indexes := make([]int, 0, len(b.toolCalls))
+	for index := range b.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var toolCalls []ToolCall
+	for _, index := range indexes {
+		call := *b.toolCalls[index]
+		if strings.TrimSpace(call.Function.Arguments) == "" {
+			call.Function.Arguments = "{}"
+		}
+		toolCalls = append(toolCalls, call)
+	}
+
+	return &Output{
+		Choices: []OutputChoices{
+			{
+				Message: Message{
+					Role:      "assistant",
+					Content:   b.text.String(),
+					ToolCalls: toolCalls,
+				},
+				FinishReason: b.finishReason,
+			},
+		},
+		Usage: b.usage,
+	}
+}
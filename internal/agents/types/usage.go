@@ -0,0 +1,75 @@
+package agentTypes
+
+import "encoding/json"
+
+// * tokens of one or more requests, InputTokens excludes the cached part counted in CacheReadTokens
+type Usage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+}
+
+// * usage of one model within a run, cost is an estimate in USD, zero when the model has no price
+type ModelUsage struct {
+	Model string  `json:"model"`
+	Usage Usage   `json:"usage"`
+	Cost  float64 `json:"cost"`
+}
+
+// * agents that report the model they send to, used to price usage
+type ModelNamer interface {
+	ModelName() string
+}
+
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheWriteTokens += other.CacheWriteTokens
+}
+
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
+}
+
+func (u Usage) IsZero() bool {
+	return u.Total() == 0
+}
+
+// * also accepts chat completions usage, where prompt_tokens includes prompt_tokens_details.cached_tokens
+func (u *Usage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		InputTokens         int `json:"input_tokens"`
+		OutputTokens        int `json:"output_tokens"`
+		CacheReadTokens     int `json:"cache_read_tokens"`
+		CacheWriteTokens    int `json:"cache_write_tokens"`
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails *struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*u = Usage{
+		InputTokens:      raw.InputTokens,
+		OutputTokens:     raw.OutputTokens,
+		CacheReadTokens:  raw.CacheReadTokens,
+		CacheWriteTokens: raw.CacheWriteTokens,
+	}
+	if raw.PromptTokens > 0 || raw.CompletionTokens > 0 {
+		u.InputTokens = raw.PromptTokens
+		u.OutputTokens = raw.CompletionTokens
+		if raw.PromptTokensDetails != nil {
+			u.CacheReadTokens = raw.PromptTokensDetails.CachedTokens
+			u.InputTokens -= u.CacheReadTokens
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package discord
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// * who may talk to the bot and who confirms its tools, nothing is allowed when empty
+type Access struct {
+	Users  []string `json:"allowed_users"`  // * user ids answered anywhere, direct messages included
+	Guilds []string `json:"allowed_guilds"` // * guild ids whose members are all answered
+	Owner  string   `json:"owner"`          // * user id asked for every tool confirmation, in a direct message
+}
+
+// * "discord" in {configHome}/config.json; the project config is inside the sandbox and never read
+func LoadAccess(configHome string) Access {
+	var cfg struct {
+		Discord Access `json:"discord"`
+	}
+	data, err := os.ReadFile(filepath.Join(configHome, "config.json"))
+	if err != nil {
+		return cfg.Discord
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		slog.Warn("failed to parse discord config, answering nobody",
+			slog.String("error", err.Error()))
+		return Access{}
+	}
+	return cfg.Discord
+}
+
+func (a Access) allows(m *discordgo.Message) bool {
+	if m.Author == nil {
+		return false
+	}
+	if (a.Owner != "" && m.Author.ID == a.Owner) || slices.Contains(a.Users, m.Author.ID) {
+		return true
+	}
+	return m.GuildID != "" && slices.Contains(a.Guilds, m.GuildID)
+}
@@ -0,0 +1,236 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+const (
+	sessionPrefix  = "discord-"
+	editInterval   = time.Second
+	confirmTimeout = 5 * time.Minute
+)
+
+// * subset of *discordgo.Session used by the bot, so tests can stand in for gateway and rest
+type Session interface {
+	AddHandler(handler any) func()
+	Open() error
+	Close() error
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+}
+
+var _ Session = (*discordgo.Session)(nil)
+
+// * runs the agent for input on sessionID, ex. exec.Run with Config.SessionID set
+type RunFunc func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error
+
+// * each channel or thread is one agenvoy session named discord-{channel id}
+type Bot struct {
+	session Session
+	run     RunFunc
+
+	// * minimum time between edits of a streamed message
+	EditInterval time.Duration
+	// * how long the owner has to answer a confirm before the tool is skipped
+	ConfirmTimeout time.Duration
+	// * nobody is answered and every tool needing confirmation is skipped until it is set
+	Access Access
+
+	mu       sync.Mutex
+	userID   string
+	active   map[string]context.CancelFunc // * channel id to the running request
+	confirms map[string]*confirm           // * button key to the pending EventToolConfirm
+	nextKey  int
+	removers []func()
+}
+
+func New(session Session, run RunFunc) *Bot {
+	return &Bot{
+		session:        session,
+		run:            run,
+		EditInterval:   editInterval,
+		ConfirmTimeout: confirmTimeout,
+		active:         make(map[string]context.CancelFunc),
+		confirms:       make(map[string]*confirm),
+	}
+}
+
+// * session for a bot token with the intents needed to read mentions and DMs
+func NewSession(token string) (*discordgo.Session, error) {
+	s, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, fmt.Errorf("discordgo.New: %w", err)
+	}
+	s.Identify.Intents = discordgo.IntentsGuildMessages |
+		discordgo.IntentsDirectMessages |
+		discordgo.IntentsMessageContent
+	return s, nil
+}
+
+func (b *Bot) Start() error {
+	b.removers = append(b.removers,
+		b.session.AddHandler(func(_ *discordgo.Session, r *discordgo.Ready) {
+			b.onReady(r)
+		}),
+		b.session.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+			go b.onMessage(m)
+		}),
+		b.session.AddHandler(func(_ *discordgo.Session, i *discordgo.InteractionCreate) {
+			b.onInteraction(i)
+		}),
+	)
+	if err := b.session.Open(); err != nil {
+		return fmt.Errorf("session.Open: %w", err)
+	}
+	return nil
+}
+
+// * cancels running requests, then closes the gateway
+func (b *Bot) Close() error {
+	b.mu.Lock()
+	for _, cancel := range b.active {
+		cancel()
+	}
+	removers := b.removers
+	b.removers = nil
+	b.mu.Unlock()
+
+	for _, remove := range removers {
+		remove()
+	}
+	return b.session.Close()
+}
+
+func (b *Bot) onReady(r *discordgo.Ready) {
+	if r.User == nil {
+		return
+	}
+	b.mu.Lock()
+	b.userID = r.User.ID
+	b.mu.Unlock()
+}
+
+// * answers direct messages and messages mentioning the bot, from users or guilds in Access
+func (b *Bot) onMessage(m *discordgo.MessageCreate) {
+	if m.Message == nil || m.Author == nil || m.Author.Bot || !b.Access.allows(m.Message) {
+		return
+	}
+
+	b.mu.Lock()
+	userID := b.userID
+	b.mu.Unlock()
+
+	input, ok := parseInput(m.Message, userID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b.mu.Lock()
+	if _, busy := b.active[m.ChannelID]; busy {
+		b.mu.Unlock()
+		b.reply(m.Message, "[!] Still working on the previous request in this channel")
+		return
+	}
+	b.active[m.ChannelID] = cancel
+	b.mu.Unlock()
+
+	defer func() {
+		b.dropConfirms(m.ChannelID)
+		b.mu.Lock()
+		delete(b.active, m.ChannelID)
+		b.mu.Unlock()
+	}()
+
+	out := newOutput(b.session, m.Message, b.EditInterval)
+	events := make(chan agentTypes.Event, 16)
+	go func() {
+		defer close(events)
+		if err := b.run(ctx, sessionPrefix+m.ChannelID, input, events); err != nil && ctx.Err() == nil {
+			events <- agentTypes.Event{Type: agentTypes.EventError, Err: err}
+		}
+	}()
+
+	// * the run waits on one confirm at a time
+	var pending string
+	expired := time.NewTimer(b.ConfirmTimeout)
+	expired.Stop()
+	defer expired.Stop()
+
+loop:
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				break loop
+			}
+			if ev.Type == agentTypes.EventToolConfirm {
+				status, key := b.askConfirm(m.Message, ev, cancel)
+				out.line(status)
+				if key != "" {
+					pending = key
+					expired.Reset(b.ConfirmTimeout)
+				}
+			}
+			out.handle(ev)
+
+		case <-expired.C:
+			if toolName, ok := b.expireConfirm(pending); ok {
+				out.replace(waitingStatus(toolName), fmt.Sprintf("[x] Confirm timed out: `%s`", toolName))
+				out.flush(true)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		out.line("[x] Stopped")
+	}
+	out.flush(true)
+}
+
+var mentionRegex = regexp.MustCompile(`<@!?(\d+)>`)
+
+func parseInput(m *discordgo.Message, userID string) (string, bool) {
+	isDM := m.GuildID == ""
+	mentioned := false
+	for _, u := range m.Mentions {
+		if u != nil && u.ID == userID {
+			mentioned = true
+		}
+	}
+	if !isDM && !mentioned {
+		return "", false
+	}
+
+	input := mentionRegex.ReplaceAllStringFunc(m.Content, func(s string) string {
+		if mentionRegex.FindStringSubmatch(s)[1] == userID {
+			return ""
+		}
+		return s
+	})
+	input = strings.TrimSpace(input)
+	return input, input != ""
+}
+
+func (b *Bot) reply(m *discordgo.Message, content string) {
+	if _, err := b.session.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content:   content,
+		Reference: m.Reference(),
+	}); err != nil {
+		slog.Warn("failed to send discord message",
+			slog.String("channel", m.ChannelID),
+			slog.String("error", err.Error()))
+	}
+}
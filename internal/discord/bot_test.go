@@ -0,0 +1,468 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+const botID = "100"
+
+// * gateway: handlers registered by the bot, dispatched by the test
+// * rest: messages kept in memory, keyed by id
+type fakeSession struct {
+	mu        sync.Mutex
+	handlers  []any
+	nextID    int
+	messages  map[string]*discordgo.Message
+	order     []string
+	edits     int
+	responses []*discordgo.InteractionResponse
+	confirmCh chan *discordgo.Message
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{
+		messages:  make(map[string]*discordgo.Message),
+		confirmCh: make(chan *discordgo.Message, 4),
+	}
+}
+
+func (f *fakeSession) AddHandler(handler any) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, handler)
+	return func() {}
+}
+
+func (f *fakeSession) Open() error  { return nil }
+func (f *fakeSession) Close() error { return nil }
+
+func (f *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if utf8.RuneCountInString(data.Content) > maxMessageLen {
+		return nil, fmt.Errorf("content over %d", maxMessageLen)
+	}
+	f.nextID++
+	msg := &discordgo.Message{
+		ID:         fmt.Sprintf("m%d", f.nextID),
+		ChannelID:  channelID,
+		Content:    data.Content,
+		Components: data.Components,
+	}
+	f.messages[msg.ID] = msg
+	f.order = append(f.order, msg.ID)
+	if len(data.Components) > 0 {
+		f.confirmCh <- msg
+	}
+	return msg, nil
+}
+
+func (f *fakeSession) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if utf8.RuneCountInString(content) > maxMessageLen {
+		return nil, fmt.Errorf("content over %d", maxMessageLen)
+	}
+	msg, ok := f.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("unknown message %s", messageID)
+	}
+	msg.Content = content
+	f.edits++
+	return msg, nil
+}
+
+func (f *fakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func (f *fakeSession) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "dm-" + recipientID}, nil
+}
+
+func (f *fakeSession) dispatch(event any) {
+	f.mu.Lock()
+	handlers := append([]any(nil), f.handlers...)
+	f.mu.Unlock()
+
+	for _, h := range handlers {
+		switch fn := h.(type) {
+		case func(*discordgo.Session, *discordgo.Ready):
+			if ev, ok := event.(*discordgo.Ready); ok {
+				fn(nil, ev)
+			}
+		case func(*discordgo.Session, *discordgo.MessageCreate):
+			if ev, ok := event.(*discordgo.MessageCreate); ok {
+				fn(nil, ev)
+			}
+		case func(*discordgo.Session, *discordgo.InteractionCreate):
+			if ev, ok := event.(*discordgo.InteractionCreate); ok {
+				fn(nil, ev)
+			}
+		}
+	}
+}
+
+func (f *fakeSession) contents() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, 0, len(f.order))
+	for _, id := range f.order {
+		out = append(out, f.messages[id].Content)
+	}
+	return out
+}
+
+func message(channelID, content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "u1",
+		ChannelID: channelID,
+		GuildID:   "g1",
+		Content:   "<@" + botID + "> " + content,
+		Author:    &discordgo.User{ID: "alice"},
+		Mentions:  []*discordgo.User{{ID: botID}},
+	}}
+}
+
+func click(customID, userID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:   discordgo.InteractionMessageComponent,
+		Data:   discordgo.MessageComponentInteractionData{CustomID: customID},
+		Member: &discordgo.Member{User: &discordgo.User{ID: userID}},
+	}}
+}
+
+func startBot(t *testing.T, run RunFunc) (*Bot, *fakeSession) {
+	t.Helper()
+	session := newFakeSession()
+	bot := New(session, run)
+	bot.EditInterval = 0
+	bot.Access = Access{Users: []string{"alice", "mallory"}, Owner: "owner"}
+	if err := bot.Start(); err != nil {
+		t.Fatal(err)
+	}
+	session.dispatch(&discordgo.Ready{User: &discordgo.User{ID: botID}})
+	t.Cleanup(func() { bot.Close() })
+	return bot, session
+}
+
+func customID(t *testing.T, msg *discordgo.Message, label string) string {
+	t.Helper()
+	row := msg.Components[0].(discordgo.ActionsRow)
+	for _, c := range row.Components {
+		if b := c.(discordgo.Button); b.Label == label {
+			return b.CustomID
+		}
+	}
+	t.Fatalf("button %s not found", label)
+	return ""
+}
+
+func TestBot_StreamsAndConfirms(t *testing.T) {
+	var gotSession, gotInput string
+	var allowed bool
+	run := func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error {
+		gotSession, gotInput = sessionID, input
+		events <- agentTypes.Event{Type: agentTypes.EventAgentResult, Text: "fake"}
+		events <- agentTypes.Event{Type: agentTypes.EventToolCall, ToolName: "write_file", ToolArgs: `{"path":"a.txt"}`}
+		reply := make(chan bool, 1)
+		events <- agentTypes.Event{Type: agentTypes.EventToolConfirm, ToolName: "write_file", ToolID: "call_1", ReplyCh: reply}
+		allowed = <-reply
+		events <- agentTypes.Event{Type: agentTypes.EventTextDelta, Text: "Hello "}
+		events <- agentTypes.Event{Type: agentTypes.EventTextDelta, Text: "world"}
+		events <- agentTypes.Event{Type: agentTypes.EventText, Text: "Hello world"}
+		events <- agentTypes.Event{Type: agentTypes.EventDone}
+		return nil
+	}
+	bot, session := startBot(t, run)
+
+	done := make(chan struct{})
+	go func() {
+		bot.onMessage(message("c1", "write a file"))
+		close(done)
+	}()
+
+	confirmMsg := <-session.confirmCh
+	if confirmMsg.ChannelID != "dm-owner" || !strings.Contains(confirmMsg.Content, "write_file") {
+		t.Errorf("confirm message in %s = %q", confirmMsg.ChannelID, confirmMsg.Content)
+	}
+
+	// * only the owner answers, not even the requester
+	session.dispatch(click(customID(t, confirmMsg, "Yes"), "alice"))
+	session.dispatch(click(customID(t, confirmMsg, "Yes"), "owner"))
+	<-done
+
+	if gotSession != "discord-c1" || gotInput != "write a file" {
+		t.Errorf("session = %q, input = %q", gotSession, gotInput)
+	}
+	if !allowed {
+		t.Error("Yes should allow the tool")
+	}
+
+	session.mu.Lock()
+	responses := session.responses
+	session.mu.Unlock()
+	if len(responses) != 2 || responses[0].Data.Flags != discordgo.MessageFlagsEphemeral ||
+		responses[1].Type != discordgo.InteractionResponseUpdateMessage || len(responses[1].Data.Components) != 0 {
+		t.Errorf("interaction responses = %+v", responses)
+	}
+
+	contents := session.contents()
+	answer := contents[0]
+	if !strings.Contains(answer, "[*] Agent: fake") || !strings.Contains(answer, "Waiting for the owner") ||
+		strings.Count(answer, "Hello world") != 1 {
+		t.Errorf("streamed message = %q", answer)
+	}
+	if session.edits == 0 {
+		t.Error("output should be streamed as edits")
+	}
+
+	// * button of a finished request
+	session.dispatch(click(customID(t, confirmMsg, "Skip"), "owner"))
+	session.mu.Lock()
+	last := session.responses[len(session.responses)-1]
+	session.mu.Unlock()
+	if !strings.Contains(last.Data.Content, "already finished") {
+		t.Errorf("late click response = %q", last.Data.Content)
+	}
+}
+
+func TestBot_StopCancelsRun(t *testing.T) {
+	run := func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error {
+		reply := make(chan bool, 1)
+		events <- agentTypes.Event{Type: agentTypes.EventToolConfirm, ToolName: "run_command", ReplyCh: reply}
+		select {
+		case <-reply:
+		case <-ctx.Done():
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	bot, session := startBot(t, run)
+
+	done := make(chan struct{})
+	go func() {
+		bot.onMessage(message("c2", "run it"))
+		close(done)
+	}()
+
+	confirmMsg := <-session.confirmCh
+	session.dispatch(click(customID(t, confirmMsg, "Stop"), "owner"))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not cancel the run")
+	}
+	if contents := session.contents(); !strings.Contains(contents[0], "[x] Stopped") {
+		t.Errorf("output = %q", contents[0])
+	}
+}
+
+func TestBot_ConfirmTimeout(t *testing.T) {
+	allowed := true
+	run := func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error {
+		reply := make(chan bool, 1)
+		events <- agentTypes.Event{Type: agentTypes.EventToolConfirm, ToolName: "run_command", ReplyCh: reply}
+		allowed = <-reply
+		return nil
+	}
+	bot, session := startBot(t, run)
+	bot.ConfirmTimeout = 50 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		bot.onMessage(message("c7", "run it"))
+		close(done)
+	}()
+
+	confirmMsg := <-session.confirmCh
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("confirm did not time out")
+	}
+	if allowed {
+		t.Error("tool allowed without an answer")
+	}
+	contents := session.contents()
+	if !strings.Contains(contents[0], "[x] Confirm timed out: `run_command`") || strings.Contains(contents[0], "Waiting for the owner") {
+		t.Errorf("output = %q", contents[0])
+	}
+
+	// * the owner answering late is told the request is over
+	session.dispatch(click(customID(t, confirmMsg, "Yes"), "owner"))
+	session.mu.Lock()
+	last := session.responses[len(session.responses)-1]
+	session.mu.Unlock()
+	if !strings.Contains(last.Data.Content, "already finished") {
+		t.Errorf("late click response = %q", last.Data.Content)
+	}
+}
+
+func TestBot_IgnoresUnmentionedAndBusy(t *testing.T) {
+	release := make(chan struct{})
+	var calls int
+	var mu sync.Mutex
+	run := func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return nil
+	}
+	bot, session := startBot(t, run)
+
+	unmentioned := message("c3", "hello")
+	unmentioned.Mentions = nil
+	unmentioned.Content = "hello"
+	bot.onMessage(unmentioned)
+
+	done := make(chan struct{})
+	go func() {
+		bot.onMessage(message("c3", "first"))
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := calls
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run was not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	bot.onMessage(message("c3", "second"))
+	close(release)
+	<-done
+
+	if calls != 1 {
+		t.Errorf("run called %d times, want 1", calls)
+	}
+	found := false
+	for _, c := range session.contents() {
+		if strings.Contains(c, "Still working") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("busy channel should get a notice")
+	}
+}
+
+func TestBot_Access(t *testing.T) {
+	var mu sync.Mutex
+	var inputs []string
+	run := func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error {
+		mu.Lock()
+		inputs = append(inputs, input)
+		mu.Unlock()
+		return nil
+	}
+	bot, _ := startBot(t, run)
+	bot.Access = Access{Users: []string{"alice"}, Guilds: []string{"g2"}}
+
+	from := func(author, guildID, content string) *discordgo.MessageCreate {
+		m := message("c5", content)
+		m.Author = &discordgo.User{ID: author}
+		m.GuildID = guildID
+		return m
+	}
+	bot.onMessage(from("alice", "g1", "allowed user"))
+	bot.onMessage(from("alice", "", "allowed user dm"))
+	bot.onMessage(from("eve", "g1", "other guild"))
+	bot.onMessage(from("eve", "", "stranger dm"))
+	bot.onMessage(from("eve", "g2", "allowed guild"))
+
+	if fmt.Sprint(inputs) != "[allowed user allowed user dm allowed guild]" {
+		t.Errorf("answered %q", inputs)
+	}
+
+	// * deny by default
+	bot.Access = Access{}
+	bot.onMessage(from("alice", "g1", "nobody"))
+	if len(inputs) != 3 {
+		t.Errorf("answered without access: %q", inputs)
+	}
+}
+
+func TestBot_ConfirmWithoutOwner(t *testing.T) {
+	var allowed bool
+	run := func(ctx context.Context, sessionID, input string, events chan<- agentTypes.Event) error {
+		reply := make(chan bool, 1)
+		events <- agentTypes.Event{Type: agentTypes.EventToolConfirm, ToolName: "run_command", ReplyCh: reply}
+		allowed = <-reply
+		return nil
+	}
+	bot, session := startBot(t, run)
+	bot.Access.Owner = ""
+
+	bot.onMessage(message("c6", "run it"))
+	if allowed {
+		t.Error("tool allowed without an owner")
+	}
+	if contents := session.contents(); !strings.Contains(contents[0], "No owner set") {
+		t.Errorf("output = %q", contents)
+	}
+}
+
+func TestLoadAccess(t *testing.T) {
+	home := t.TempDir()
+	if got := LoadAccess(home); got.Owner != "" || got.Users != nil || got.Guilds != nil {
+		t.Errorf("without config = %+v", got)
+	}
+
+	config := `{"discord": {"allowed_users": ["1"], "allowed_guilds": ["2"], "owner": "1"}}`
+	if err := os.WriteFile(filepath.Join(home, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := LoadAccess(home); got.Owner != "1" || fmt.Sprint(got.Users, got.Guilds) != "[1] [2]" {
+		t.Errorf("access = %+v", got)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	long := strings.Repeat("line of text\n", 300)
+	chunks := splitMessage(long, maxMessageLen)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if utf8.RuneCountInString(c) > maxMessageLen {
+			t.Errorf("chunk of %d runes", utf8.RuneCountInString(c))
+		}
+	}
+
+	code := "```go\n" + strings.Repeat("fmt.Println(\"x\")\n", 200) + "```"
+	for i, c := range splitMessage(code, maxMessageLen) {
+		if strings.Count(c, codeFence)%2 != 0 {
+			t.Errorf("chunk %d leaves a code fence open", i)
+		}
+	}
+
+	wide := strings.Repeat("字", 4500)
+	for _, c := range splitMessage(wide, maxMessageLen) {
+		if !utf8.ValidString(c) || utf8.RuneCountInString(c) > maxMessageLen {
+			t.Errorf("invalid chunk of %d runes", utf8.RuneCountInString(c))
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+const (
+	buttonPrefix = "agenvoy"
+	actionYes    = "yes"
+	actionSkip   = "skip"
+	actionStop   = "stop"
+)
+
+type confirm struct {
+	channelID string
+	toolName  string
+	userID    string // * only the owner may answer
+	replyCh   chan bool
+	cancel    context.CancelFunc
+}
+
+// * EventToolConfirm becomes a direct message to Access.Owner with Yes / Skip / Stop buttons,
+// * without an owner the tool is skipped; returns the status line for the requesting channel,
+// * and the button key while the owner has yet to answer
+func (b *Bot) askConfirm(request *discordgo.Message, ev agentTypes.Event, cancel context.CancelFunc) (string, string) {
+	owner := b.Access.Owner
+	if owner == "" {
+		ev.ReplyCh <- false
+		return fmt.Sprintf("[x] No owner set to confirm `%s`", ev.ToolName), ""
+	}
+
+	b.mu.Lock()
+	b.nextKey++
+	key := fmt.Sprintf("%d", b.nextKey)
+	b.confirms[key] = &confirm{
+		channelID: request.ChannelID,
+		toolName:  ev.ToolName,
+		userID:    owner,
+		replyCh:   ev.ReplyCh,
+		cancel:    cancel,
+	}
+	b.mu.Unlock()
+
+	button := func(label, action string, style discordgo.ButtonStyle) discordgo.Button {
+		return discordgo.Button{
+			Label:    label,
+			Style:    style,
+			CustomID: strings.Join([]string{buttonPrefix, action, key}, ":"),
+		}
+	}
+
+	content := fmt.Sprintf("<@%s> in <#%s> wants to run `%s`", request.Author.ID, request.ChannelID, ev.ToolName)
+	if args := strings.TrimSpace(ev.ToolArgs); args != "" {
+		content += "\n```json\n" + truncate(args, 1500) + "\n```"
+	}
+
+	err := b.sendDM(owner, &discordgo.MessageSend{
+		Content: content,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				button("Yes", actionYes, discordgo.SuccessButton),
+				button("Skip", actionSkip, discordgo.SecondaryButton),
+				button("Stop", actionStop, discordgo.DangerButton),
+			}},
+		},
+	})
+	if err != nil {
+		// * nobody can answer, skip the tool instead of hanging the run
+		slog.Warn("failed to send discord confirm",
+			slog.String("owner", owner),
+			slog.String("error", err.Error()))
+		b.takeConfirm(key)
+		ev.ReplyCh <- false
+		return fmt.Sprintf("[x] Could not ask the owner to confirm `%s`", ev.ToolName), ""
+	}
+	return waitingStatus(ev.ToolName), key
+}
+
+func waitingStatus(toolName string) string {
+	return fmt.Sprintf("[?] Waiting for the owner to confirm `%s`", toolName)
+}
+
+// * skips the tool if the owner has not answered yet; reports whether it did
+func (b *Bot) expireConfirm(key string) (string, bool) {
+	c := b.takeConfirm(key)
+	if c == nil {
+		return "", false
+	}
+	c.replyCh <- false
+	return c.toolName, true
+}
+
+func (b *Bot) sendDM(userID string, data *discordgo.MessageSend) error {
+	dm, err := b.session.UserChannelCreate(userID)
+	if err != nil {
+		return fmt.Errorf("session.UserChannelCreate: %w", err)
+	}
+	if _, err := b.session.ChannelMessageSendComplex(dm.ID, data); err != nil {
+		return fmt.Errorf("session.ChannelMessageSendComplex: %w", err)
+	}
+	return nil
+}
+
+func (b *Bot) takeConfirm(key string) *confirm {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.confirms[key]
+	if !ok {
+		return nil
+	}
+	delete(b.confirms, key)
+	return c
+}
+
+// * buttons of a finished request answer "already finished"
+func (b *Bot) dropConfirms(channelID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, c := range b.confirms {
+		if c.channelID == channelID {
+			delete(b.confirms, key)
+		}
+	}
+}
+
+func (b *Bot) onInteraction(i *discordgo.InteractionCreate) {
+	if i.Interaction == nil || i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+
+	parts := strings.Split(i.MessageComponentData().CustomID, ":")
+	if len(parts) != 3 || parts[0] != buttonPrefix {
+		return
+	}
+	action, key := parts[1], parts[2]
+
+	b.mu.Lock()
+	c, ok := b.confirms[key]
+	b.mu.Unlock()
+	if !ok {
+		b.respond(i.Interaction, "[x] This request has already finished")
+		return
+	}
+	if user := interactionUser(i.Interaction); user == nil || user.ID != c.userID {
+		b.respond(i.Interaction, fmt.Sprintf("[x] Only <@%s> can answer this", c.userID))
+		return
+	}
+	if c = b.takeConfirm(key); c == nil {
+		return
+	}
+
+	var status string
+	switch action {
+	case actionYes:
+		status = fmt.Sprintf("[*] Allowed: `%s`", c.toolName)
+		c.replyCh <- true
+	case actionStop:
+		status = "[x] User stopped"
+		c.cancel()
+		c.replyCh <- false
+	default:
+		status = fmt.Sprintf("[x] User skipped: `%s`", c.toolName)
+		c.replyCh <- false
+	}
+
+	if err := b.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    status,
+			Components: []discordgo.MessageComponent{},
+		},
+	}); err != nil {
+		slog.Warn("failed to respond discord interaction",
+			slog.String("error", err.Error()))
+	}
+}
+
+// * only visible to the user who pressed the button
+func (b *Bot) respond(i *discordgo.Interaction, content string) {
+	if err := b.session.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		slog.Warn("failed to respond discord interaction",
+			slog.String("error", err.Error()))
+	}
+}
+
+// * guild interactions carry Member, direct messages carry User
+func interactionUser(i *discordgo.Interaction) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
@@ -0,0 +1,193 @@
+package discord
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+const (
+	maxMessageLen = 2000
+	placeholder   = "[~] Thinking..."
+	codeFence     = "```"
+)
+
+type sentMessage struct {
+	id      string
+	content string
+}
+
+// * renders the events of one request as messages, edited in place and split at the length limit
+type output struct {
+	session  Session
+	request  *discordgo.Message
+	interval time.Duration
+
+	body      strings.Builder
+	streamed  bool // * deltas were shown since the last EventText
+	sent      []sentMessage
+	lastFlush time.Time
+}
+
+func newOutput(session Session, request *discordgo.Message, interval time.Duration) *output {
+	o := &output{
+		session:  session,
+		request:  request,
+		interval: interval,
+	}
+	o.flush(true)
+	return o
+}
+
+func (o *output) handle(ev agentTypes.Event) {
+	switch ev.Type {
+	case agentTypes.EventSkillResult:
+		if ev.Text != "none" {
+			o.line(fmt.Sprintf("[*] Skill: %s", ev.Text))
+		}
+
+	case agentTypes.EventAgentResult:
+		o.line(fmt.Sprintf("[*] Agent: %s", ev.Text))
+
+	case agentTypes.EventTextDelta:
+		o.body.WriteString(ev.Text)
+		o.streamed = true
+
+	case agentTypes.EventText:
+		// * already shown by deltas
+		if !o.streamed {
+			o.line(ev.Text)
+		}
+		o.streamed = false
+
+	case agentTypes.EventToolCall:
+		o.line(fmt.Sprintf("[~] `%s` %s", ev.ToolName, truncate(ev.ToolArgs, 120)))
+
+	case agentTypes.EventToolSkipped:
+		o.line(fmt.Sprintf("[x] Skipped: %s", ev.ToolName))
+
+	case agentTypes.EventCompact:
+		o.line("[-] " + ev.Text)
+
+	case agentTypes.EventRetry:
+		o.line("[~] " + ev.Text)
+
+	case agentTypes.EventFailover:
+		o.line("[!] " + ev.Text)
+
+	case agentTypes.EventError:
+		if ev.Err != nil {
+			o.line(fmt.Sprintf("[!] Error: %v", ev.Err))
+		}
+
+	case agentTypes.EventDone:
+		o.flush(true)
+		return
+	}
+	o.flush(false)
+}
+
+// * status lines always start on their own line
+func (o *output) line(text string) {
+	if o.body.Len() > 0 && !strings.HasSuffix(o.body.String(), "\n") {
+		o.body.WriteString("\n")
+	}
+	o.body.WriteString(strings.TrimSpace(text))
+	o.body.WriteString("\n")
+}
+
+// * rewrites the latest occurrence of a status line, ex. once a confirm is no longer waiting
+func (o *output) replace(old, new string) {
+	body := o.body.String()
+	idx := strings.LastIndex(body, old)
+	if idx < 0 {
+		return
+	}
+	o.body.Reset()
+	o.body.WriteString(body[:idx] + new + body[idx+len(old):])
+}
+
+func (o *output) flush(force bool) {
+	if !force && time.Since(o.lastFlush) < o.interval {
+		return
+	}
+	o.lastFlush = time.Now()
+
+	body := strings.TrimSpace(o.body.String())
+	if body == "" {
+		body = placeholder
+	}
+
+	for i, chunk := range splitMessage(body, maxMessageLen) {
+		if i < len(o.sent) {
+			if o.sent[i].content == chunk {
+				continue
+			}
+			if _, err := o.session.ChannelMessageEdit(o.request.ChannelID, o.sent[i].id, chunk); err != nil {
+				slog.Warn("failed to edit discord message",
+					slog.String("channel", o.request.ChannelID),
+					slog.String("error", err.Error()))
+				continue
+			}
+			o.sent[i].content = chunk
+			continue
+		}
+
+		send := &discordgo.MessageSend{Content: chunk}
+		if i == 0 {
+			send.Reference = o.request.Reference()
+		}
+		msg, err := o.session.ChannelMessageSendComplex(o.request.ChannelID, send)
+		if err != nil {
+			slog.Warn("failed to send discord message",
+				slog.String("channel", o.request.ChannelID),
+				slog.String("error", err.Error()))
+			return
+		}
+		o.sent = append(o.sent, sentMessage{id: msg.ID, content: chunk})
+	}
+}
+
+// * split at newlines when possible, open code fences are closed and reopened across chunks
+func splitMessage(text string, limit int) []string {
+	var chunks []string
+	for utf8.RuneCountInString(text) > limit {
+		// * room to close a fence
+		cut := runeOffset(text, limit-len(codeFence)-1)
+		if idx := strings.LastIndex(text[:cut], "\n"); idx > cut/2 {
+			cut = idx + 1
+		}
+
+		chunk := text[:cut]
+		rest := text[cut:]
+		if strings.Count(chunk, codeFence)%2 == 1 {
+			chunk = strings.TrimRight(chunk, "\n") + "\n" + codeFence
+			rest = codeFence + "\n" + rest
+		}
+		chunks = append(chunks, strings.TrimRight(chunk, "\n"))
+		text = rest
+	}
+	return append(chunks, text)
+}
+
+func runeOffset(text string, n int) int {
+	offset := 0
+	for i := 0; i < n && offset < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+	}
+	return offset
+}
+
+func truncate(text string, n int) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "\n", " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return text[:runeOffset(text, n)] + "…"
+}
@@ -16,14 +16,17 @@ type CompatEntry struct {
 }
 
 type ModelEntry struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	ContextWindow int    `json:"context_window,omitempty"` // * tokens, zero picks from the model name
 }
 
 type Config struct {
-	SessionID string        `json:"session_id,omitempty"`
-	Models    []ModelEntry  `json:"models,omitempty"`
-	Compats   []CompatEntry `json:"compats,omitempty"`
+	SessionID    string            `json:"session_id,omitempty"`
+	Sessions     map[string]string `json:"sessions,omitempty"`      // * work dir to session id, see internal/sessions
+	SessionStore string            `json:"session_store,omitempty"` // * json (default) or sqlite
+	Models       []ModelEntry      `json:"models,omitempty"`
+	Compats      []CompatEntry     `json:"compats,omitempty"`
 }
 
 func Load() (*Config, error) {
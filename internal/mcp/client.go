@@ -0,0 +1,224 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	clientName    = "agenvoy"
+	clientVersion = "0.1.0"
+	// * limit for connecting one server, and for listing what it offers
+	ConnectTimeout = 30 * time.Second
+)
+
+type Client struct {
+	Name string
+	Info InitializeResult
+
+	transport transport
+	nextID    atomic.Int64
+}
+
+func Connect(ctx context.Context, name string, cfg ServerConfig) (*Client, error) {
+	if err := cfg.check(); err != nil {
+		return nil, fmt.Errorf("server %s: %w", name, err)
+	}
+
+	var t transport
+	if cfg.Command != "" {
+		stdio, err := startStdio(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("startStdio: %w", err)
+		}
+		t = stdio
+	} else {
+		t = newHTTPTransport(cfg, &http.Client{})
+	}
+
+	client, err := newClient(ctx, name, t)
+	if err != nil {
+		_ = t.close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// * initialize handshake, then initialized notification
+func newClient(ctx context.Context, name string, t transport) (*Client, error) {
+	c := &Client{
+		Name:      name,
+		transport: t,
+	}
+
+	err := c.call(ctx, "initialize", InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: clientName, Version: clientVersion},
+	}, &c.Info)
+	if err != nil {
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+
+	if err := t.notify(ctx, &Message{JSONRPC: jsonrpcVersion, Method: "notifications/initialized"}); err != nil {
+		return nil, fmt.Errorf("notifications/initialized: %w", err)
+	}
+	return c, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	var raw json.RawMessage
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		raw = data
+	}
+
+	id := c.nextID.Add(1)
+	resp, err := c.transport.roundTrip(ctx, &Message{
+		JSONRPC: jsonrpcVersion,
+		ID:      json.RawMessage(strconv.FormatInt(id, 10)),
+		Method:  method,
+		Params:  raw,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var result ListToolsResult
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", CallToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	cursor := ""
+	for {
+		var result ListResourcesResult
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		resources = append(resources, result.Resources...)
+		if result.NextCursor == "" {
+			return resources, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+func (c *Client) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	var result ReadResourceResult
+	if err := c.call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var prompts []Prompt
+	cursor := ""
+	for {
+		var result ListPromptsResult
+		if err := c.call(ctx, "prompts/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, result.Prompts...)
+		if result.NextCursor == "" {
+			return prompts, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var result GetPromptResult
+	params := map[string]any{"name": name, "arguments": args}
+	if err := c.call(ctx, "prompts/get", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+func cursorParams(cursor string) any {
+	if cursor == "" {
+		return map[string]any{}
+	}
+	return map[string]string{"cursor": cursor}
+}
+
+// * connect every server concurrently, failed servers are skipped with a warning
+func ConnectAll(ctx context.Context, servers map[string]ServerConfig) []*Client {
+	names := sortedNames(servers)
+	clients := make([]*Client, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, ConnectTimeout)
+			defer cancel()
+
+			client, err := Connect(ctx, name, servers[name])
+			if err != nil {
+				slog.Warn("failed to connect mcp server",
+					slog.String("name", name),
+					slog.String("error", err.Error()))
+				return
+			}
+			clients[i] = client
+		}(i, name)
+	}
+	wg.Wait()
+
+	connected := make([]*Client, 0, len(clients))
+	for _, c := range clients {
+		if c != nil {
+			connected = append(connected, c)
+		}
+	}
+	return connected
+}
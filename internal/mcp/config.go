@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// * stdio when Command is set, streamable http when URL is set
+type ServerConfig struct {
+	Command  string            `json:"command,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Dir      string            `json:"cwd,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Disabled bool              `json:"disabled,omitempty"`
+}
+
+type configFile struct {
+	Servers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// * mcp.json in each dir, later dirs override servers with the same name
+func LoadConfig(dirs ...string) map[string]ServerConfig {
+	servers := make(map[string]ServerConfig)
+	for _, dir := range dirs {
+		path := filepath.Join(dir, "mcp.json")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var cfg configFile
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			slog.Warn("failed to parse mcp.json",
+				slog.String("path", path),
+				slog.String("error", err.Error()))
+			continue
+		}
+
+		for name, server := range cfg.Servers {
+			if server.Disabled {
+				delete(servers, name)
+				continue
+			}
+			servers[name] = server
+		}
+	}
+	return servers
+}
+
+// * mcp.json of home, then of work when it is trusted; the project file starts processes,
+// * so a cloned repo or an agent edit must not run anything before the user trusts it
+func LoadTrusted(home, work string) map[string]ServerConfig {
+	if filepath.Clean(home) == filepath.Clean(work) {
+		return LoadConfig(home)
+	}
+
+	trusted, err := IsTrusted(home, work)
+	if err != nil {
+		slog.Warn("failed to check mcp trust",
+			slog.String("error", err.Error()))
+	}
+	if !trusted {
+		if _, err := os.Stat(filepath.Join(work, "mcp.json")); err == nil {
+			slog.Warn("project mcp.json is not trusted, run agenvoy mcp trust to load it",
+				slog.String("path", filepath.Join(work, "mcp.json")))
+		}
+		return LoadConfig(home)
+	}
+	return LoadConfig(home, work)
+}
+
+// * whether {work}/mcp.json is the one last passed to Trust; edits revoke the trust
+func IsTrusted(home, work string) (bool, error) {
+	hash, err := configHash(work)
+	if err != nil || hash == "" {
+		return false, err
+	}
+	list, err := loadTrust(home)
+	if err != nil {
+		return false, err
+	}
+	return list[filepath.Clean(work)] == hash, nil
+}
+
+// * records the hash of {work}/mcp.json in {home}/mcp_trust.json
+func Trust(home, work string) error {
+	hash, err := configHash(work)
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return fmt.Errorf("no mcp.json in %s", work)
+	}
+	list, err := loadTrust(home)
+	if err != nil {
+		return err
+	}
+	list[filepath.Clean(work)] = hash
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "mcp_trust.json"), data, 0600); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+// * empty when there is no mcp.json
+func configHash(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "mcp.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("os.ReadFile: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// * project config dir to the hash of its trusted mcp.json
+func loadTrust(home string) (map[string]string, error) {
+	list := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(home, "mcp_trust.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return list, nil
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return list, nil
+}
+
+func (c ServerConfig) check() error {
+	switch {
+	case c.Command != "" && c.URL != "":
+		return fmt.Errorf("command and url are exclusive")
+	case c.Command == "" && c.URL == "":
+		return fmt.Errorf("command or url is required")
+	}
+	return nil
+}
+
+func sortedNames(servers map[string]ServerConfig) []string {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
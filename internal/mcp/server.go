@@ -0,0 +1,279 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+type ToolHandler func(ctx context.Context, args json.RawMessage) (*CallToolResult, error)
+
+type PromptHandler func(ctx context.Context, args map[string]string) (*GetPromptResult, error)
+
+type serverTool struct {
+	tool    Tool
+	handler ToolHandler
+}
+
+type serverPrompt struct {
+	prompt  Prompt
+	handler PromptHandler
+}
+
+// * serves tools and prompts as newline delimited json-rpc, ex. over stdio
+type Server struct {
+	Info         Implementation
+	Instructions string
+
+	tools   []serverTool
+	prompts []serverPrompt
+
+	writeMu  sync.Mutex
+	w        io.Writer
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+func NewServer(name, version string) *Server {
+	return &Server{
+		Info: Implementation{Name: name, Version: version},
+	}
+}
+
+func (s *Server) AddTool(tool Tool, handler ToolHandler) {
+	if len(tool.InputSchema) == 0 {
+		tool.InputSchema = json.RawMessage(`{"type":"object"}`)
+	}
+	s.tools = append(s.tools, serverTool{tool: tool, handler: handler})
+}
+
+func (s *Server) AddPrompt(prompt Prompt, handler PromptHandler) {
+	s.prompts = append(s.prompts, serverPrompt{prompt: prompt, handler: handler})
+}
+
+// * blocks until r is closed or ctx is done, requests are handled concurrently
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.w = w
+	s.inflight = make(map[string]context.CancelFunc)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err := <-readErr:
+			if err != nil {
+				return fmt.Errorf("scanner.Scan: %w", err)
+			}
+			return nil
+
+		case line := <-lines:
+			if len(line) == 0 {
+				continue
+			}
+
+			var msg Message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				_ = s.write(&Message{
+					JSONRPC: jsonrpcVersion,
+					ID:      json.RawMessage("null"),
+					Error:   &Error{Code: CodeParseError, Message: err.Error()},
+				})
+				continue
+			}
+
+			switch {
+			case msg.IsNotification():
+				s.notification(&msg)
+
+			case msg.IsRequest():
+				reqCtx, reqCancel := context.WithCancel(ctx)
+				key := string(msg.ID)
+				s.mu.Lock()
+				s.inflight[key] = reqCancel
+				s.mu.Unlock()
+
+				wg.Add(1)
+				go func(msg Message) {
+					defer wg.Done()
+					defer func() {
+						s.mu.Lock()
+						delete(s.inflight, key)
+						s.mu.Unlock()
+						reqCancel()
+					}()
+					_ = s.write(s.handle(reqCtx, &msg))
+				}(msg)
+			}
+		}
+	}
+}
+
+func (s *Server) notification(msg *Message) {
+	if msg.Method != "notifications/cancelled" {
+		return
+	}
+
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	cancel, ok := s.inflight[string(params.RequestID)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, msg *Message) *Message {
+	result, err := s.dispatch(ctx, msg)
+
+	reply := &Message{JSONRPC: jsonrpcVersion, ID: msg.ID}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		reply.Error = rpcErr
+		return reply
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		reply.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		return reply
+	}
+	reply.Result = data
+	return reply
+}
+
+func (s *Server) dispatch(ctx context.Context, msg *Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+
+		result := InitializeResult{
+			ProtocolVersion: ProtocolVersion,
+			ServerInfo:      s.Info,
+			Instructions:    s.Instructions,
+		}
+		if len(s.tools) > 0 {
+			result.Capabilities.Tools = &struct{}{}
+		}
+		if len(s.prompts) > 0 {
+			result.Capabilities.Prompts = &struct{}{}
+		}
+		return result, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		result := ListToolsResult{Tools: make([]Tool, 0, len(s.tools))}
+		for _, t := range s.tools {
+			result.Tools = append(result.Tools, t.tool)
+		}
+		return result, nil
+
+	case "tools/call":
+		var params CallToolParams
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		for _, t := range s.tools {
+			if t.tool.Name != params.Name {
+				continue
+			}
+			result, err := t.handler(ctx, params.Arguments)
+			if err != nil {
+				// * tool failures are results so the host model can read them
+				return CallToolResult{
+					Content: []Content{{Type: "text", Text: err.Error()}},
+					IsError: true,
+				}, nil
+			}
+			return result, nil
+		}
+		return nil, &Error{Code: CodeInvalidParams, Message: "unknown tool: " + params.Name}
+
+	case "prompts/list":
+		result := ListPromptsResult{Prompts: make([]Prompt, 0, len(s.prompts))}
+		for _, p := range s.prompts {
+			result.Prompts = append(result.Prompts, p.prompt)
+		}
+		return result, nil
+
+	case "prompts/get":
+		var params struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := unmarshalParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		for _, p := range s.prompts {
+			if p.prompt.Name == params.Name {
+				return p.handler(ctx, params.Arguments)
+			}
+		}
+		return nil, &Error{Code: CodeInvalidParams, Message: "unknown prompt: " + params.Name}
+	}
+
+	return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func (s *Server) write(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func unmarshalParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serverClient(t *testing.T, server *Server) *Client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, serverR, serverW)
+		serverW.Close()
+	}()
+
+	client, err := newClient(context.Background(), "agenvoy", newStreamTransport(clientR, clientW, nil))
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		cancel()
+		<-done
+	})
+	return client
+}
+
+func TestServer_ToolsAndPrompts(t *testing.T) {
+	server := NewServer("agenvoy", "test")
+	server.AddTool(Tool{Name: "upper"}, func(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+		var params struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, err
+		}
+		return &CallToolResult{Content: []Content{{Type: "text", Text: strings.ToUpper(params.Text)}}}, nil
+	})
+	server.AddTool(Tool{Name: "broken"}, func(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+		return nil, fmt.Errorf("disk full")
+	})
+	server.AddPrompt(Prompt{Name: "review", Arguments: []PromptArgument{{Name: "input"}}},
+		func(ctx context.Context, args map[string]string) (*GetPromptResult, error) {
+			return &GetPromptResult{Messages: []PromptMessage{
+				{Role: "user", Content: Content{Type: "text", Text: "review " + args["input"]}},
+			}}, nil
+		})
+
+	client := serverClient(t, server)
+	ctx := context.Background()
+
+	if client.Info.ServerInfo.Name != "agenvoy" || client.Info.Capabilities.Tools == nil || client.Info.Capabilities.Prompts == nil {
+		t.Fatalf("unexpected initialize result: %+v", client.Info)
+	}
+
+	tools, err := client.ListTools(ctx)
+	if err != nil || len(tools) != 2 || string(tools[0].InputSchema) != `{"type":"object"}` {
+		t.Fatalf("ListTools = %+v, %v", tools, err)
+	}
+
+	result, err := client.CallTool(ctx, "upper", json.RawMessage(`{"text":"hi"}`))
+	if err != nil || result.Text() != "HI" {
+		t.Fatalf("CallTool upper = %+v, %v", result, err)
+	}
+
+	result, err = client.CallTool(ctx, "broken", nil)
+	if err != nil || !result.IsError || result.Text() != "disk full" {
+		t.Fatalf("handler errors should be tool results: %+v, %v", result, err)
+	}
+
+	if _, err := client.CallTool(ctx, "missing", nil); err == nil {
+		t.Error("expected json-rpc error for unknown tool")
+	}
+
+	prompts, err := client.ListPrompts(ctx)
+	if err != nil || len(prompts) != 1 || prompts[0].Name != "review" {
+		t.Fatalf("ListPrompts = %+v, %v", prompts, err)
+	}
+
+	prompt, err := client.GetPrompt(ctx, "review", map[string]string{"input": "main.go"})
+	if err != nil || len(prompt.Messages) != 1 || prompt.Messages[0].Content.Text != "review main.go" {
+		t.Fatalf("GetPrompt = %+v, %v", prompt, err)
+	}
+}
+
+func TestServer_Cancelled(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+
+	server := NewServer("agenvoy", "test")
+	server.AddTool(Tool{Name: "slow"}, func(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return nil, ctx.Err()
+	})
+
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(context.Background(), r, io.Discard)
+	}()
+
+	fmt.Fprintln(w, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"slow"}}`)
+	<-started
+	fmt.Fprintln(w, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`)
+
+	select {
+	case err := <-stopped:
+		if err == nil {
+			t.Error("expected context error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool was not cancelled")
+	}
+
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+}
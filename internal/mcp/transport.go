@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	maxMessageSize = 16 * 1024 * 1024
+)
+
+type transport interface {
+	// * send a request and wait for the response with the same id
+	roundTrip(ctx context.Context, msg *Message) (*Message, error)
+	notify(ctx context.Context, msg *Message) error
+	close() error
+}
+
+// * newline delimited json-rpc over a reader / writer pair, ex. stdio of a child process
+type streamTransport struct {
+	writeMu sync.Mutex
+	w       io.WriteCloser
+
+	mu      sync.Mutex
+	pending map[string]chan *Message
+	err     error
+	done    chan struct{}
+
+	onClose func() error
+}
+
+func newStreamTransport(r io.Reader, w io.WriteCloser, onClose func() error) *streamTransport {
+	t := &streamTransport{
+		w:       w,
+		pending: make(map[string]chan *Message),
+		done:    make(chan struct{}),
+		onClose: onClose,
+	}
+	go t.read(r)
+	return t
+}
+
+func startStdio(cfg ServerConfig) (*streamTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stderr = io.Discard
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cmd.StdinPipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cmd.StdoutPipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cmd.Start: %w", err)
+	}
+
+	return newStreamTransport(stdout, stdin, func() error {
+		// * closing stdin is the shutdown signal, kill if it does not exit in time
+		exited := make(chan error, 1)
+		go func() {
+			exited <- cmd.Wait()
+		}()
+		select {
+		case <-exited:
+		case <-time.After(3 * time.Second):
+			_ = cmd.Process.Kill()
+			<-exited
+		}
+		return nil
+	}), nil
+}
+
+func (t *streamTransport) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+
+		switch {
+		case msg.IsResponse():
+			t.mu.Lock()
+			ch, ok := t.pending[string(msg.ID)]
+			delete(t.pending, string(msg.ID))
+			t.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+
+		case msg.IsRequest():
+			// * server to client requests, only ping is supported
+			reply := &Message{JSONRPC: jsonrpcVersion, ID: msg.ID}
+			if msg.Method == "ping" {
+				reply.Result = json.RawMessage(`{}`)
+			} else {
+				reply.Error = &Error{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+			}
+			_ = t.write(reply)
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+
+	t.mu.Lock()
+	t.err = fmt.Errorf("connection closed: %w", err)
+	pending := t.pending
+	t.pending = make(map[string]chan *Message)
+	t.mu.Unlock()
+
+	for _, ch := range pending {
+		close(ch)
+	}
+	close(t.done)
+}
+
+func (t *streamTransport) write(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func (t *streamTransport) roundTrip(ctx context.Context, msg *Message) (*Message, error) {
+	ch := make(chan *Message, 1)
+	key := string(msg.ID)
+
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(msg); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return nil, t.err
+		}
+		return resp, nil
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *streamTransport) notify(ctx context.Context, msg *Message) error {
+	return t.write(msg)
+}
+
+func (t *streamTransport) close() error {
+	err := t.w.Close()
+	if t.onClose != nil {
+		if closeErr := t.onClose(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// * query parameters and top level JSON fields never written to a cassette
+var secretKeys = map[string]bool{
+	"key":           true,
+	"api_key":       true,
+	"access_token":  true,
+	"refresh_token": true,
+	"token":         true,
+}
+
+const redacted = "REDACTED"
+
+// * one request and its response, bodies kept verbatim so SSE streams replay as sent
+type Interaction struct {
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Request  string            `json:"request,omitempty"`
+	Status   int               `json:"status,omitempty"`
+	Header   map[string]string `json:"header,omitempty"`
+	Response string            `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"` // * transport error, no response
+}
+
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return &c, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+// * api keys in the query (gemini ?key=) are replaced, recording and matching use the same form
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	changed := false
+	for k := range query {
+		if secretKeys[strings.ToLower(k)] {
+			query.Set(k, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	clone := *u
+	clone.RawQuery = query.Encode()
+	return clone.String()
+}
+
+// * tokens handed out by auth endpoints, ex. the copilot token refresh
+func redactBody(body string) string {
+	var m map[string]any
+	if json.Unmarshal([]byte(body), &m) != nil {
+		return body
+	}
+	changed := false
+	for k := range m {
+		if secretKeys[strings.ToLower(k)] {
+			m[k] = redacted
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
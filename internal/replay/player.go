@@ -0,0 +1,104 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// * answers from a cassette without touching the network; each interaction is used once
+type Player struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+func NewPlayer(c *Cassette) *Player {
+	return &Player{
+		interactions: c.Interactions,
+		used:         make([]bool, len(c.Interactions)),
+	}
+}
+
+func Open(path string) (*Player, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay.Load: %w", err)
+	}
+	return NewPlayer(c), nil
+}
+
+// * same method and url in recorded order; an identical request body is preferred,
+// * so concurrent calls to one endpoint still get their own answers
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("io.ReadAll: %w", err)
+		}
+		body = data
+	}
+	url := redactURL(req.URL)
+
+	p.mu.Lock()
+	index := -1
+	for i, it := range p.interactions {
+		if p.used[i] || it.Method != req.Method || it.URL != url {
+			continue
+		}
+		if index == -1 {
+			index = i
+		}
+		if it.Request == string(body) {
+			index = i
+			break
+		}
+	}
+	if index != -1 {
+		p.used[index] = true
+	}
+	p.mu.Unlock()
+
+	if index == -1 {
+		return nil, fmt.Errorf("replay: no recorded response for %s %s", req.Method, url)
+	}
+
+	it := p.interactions[index]
+	if it.Error != "" {
+		return nil, errors.New(it.Error)
+	}
+	header := make(http.Header)
+	for k, v := range it.Header {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Status, http.StatusText(it.Status)),
+		StatusCode:    it.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(it.Response))),
+		ContentLength: int64(len(it.Response)),
+		Request:       req,
+	}, nil
+}
+
+// * interactions never asked for, a test can require all of them to be used
+func (p *Player) Unused() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var list []string
+	for i, it := range p.interactions {
+		if !p.used[i] {
+			list = append(list, strings.TrimSpace(it.Method+" "+it.URL))
+		}
+	}
+	return list
+}
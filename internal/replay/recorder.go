@@ -0,0 +1,110 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// * response headers worth keeping, the rest are noise or identify the account
+var keptHeaders = []string{"Content-Type", "Retry-After"}
+
+// * forwards to next and keeps every exchange; request headers are never kept, they carry the api keys
+type Recorder struct {
+	next         http.RoundTripper
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// * next nil is http.DefaultTransport
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("io.ReadAll: %w", err)
+		}
+		body = data
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// * slot taken in request order, filled once the body is read to the end
+	r.mu.Lock()
+	index := len(r.interactions)
+	r.interactions = append(r.interactions, Interaction{
+		Method:  req.Method,
+		URL:     redactURL(req.URL),
+		Request: string(body),
+	})
+	r.mu.Unlock()
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		r.set(index, func(i *Interaction) { i.Error = err.Error() })
+		return nil, err
+	}
+
+	header := make(map[string]string)
+	for _, k := range keptHeaders {
+		if v := resp.Header.Get(k); v != "" {
+			header[k] = v
+		}
+	}
+	r.set(index, func(i *Interaction) {
+		i.Status = resp.StatusCode
+		i.Header = header
+	})
+	// * streams are passed through as they arrive, not buffered before the caller sees them
+	resp.Body = &teeBody{ReadCloser: resp.Body, done: func(data []byte) {
+		r.set(index, func(i *Interaction) { i.Response = redactBody(string(data)) })
+	}}
+	return resp, nil
+}
+
+func (r *Recorder) set(index int, fn func(*Interaction)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.interactions[index])
+}
+
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.interactions...)}
+}
+
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+type teeBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	once sync.Once
+	done func([]byte)
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.buf.Bytes()) })
+	}
+	return n, err
+}
+
+// * a body closed early keeps what was read so far
+func (b *teeBody) Close() error {
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+	return b.ReadCloser.Close()
+}
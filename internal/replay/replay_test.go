@@ -0,0 +1,125 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func newServer(t *testing.T, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "data: {\"echo\":%q}\n\ndata: {\"n\":2}\n\ndata: [DONE]\n\n", string(body))
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"token":"secret-token","expires_at":1}`)
+		case "/limited":
+			w.Header().Set("Retry-After", "3")
+			w.Header().Set("X-Request-Id", "abc")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":"slow down"}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func stream(ctx context.Context, api, input string) (string, error) {
+	var got []string
+	_, err := utils.POSTStream(ctx, nil, api, map[string]string{"Authorization": "Bearer sk-live"}, map[string]any{"input": input}, func(_ string, data []byte) error {
+		got = append(got, string(data))
+		return nil
+	})
+	return strings.Join(got, "|"), err
+}
+
+func TestRecordReplay(t *testing.T) {
+	var hits atomic.Int32
+	srv := newServer(t, &hits)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder := NewRecorder(nil)
+	utils.SetTransport(recorder)
+	t.Cleanup(func() { utils.SetTransport(nil) })
+
+	first, err := stream(ctx, srv.URL+"/stream?key=AIza-secret", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := stream(ctx, srv.URL+"/stream?key=AIza-secret", "b")
+	utils.GET[map[string]any](ctx, nil, srv.URL+"/token", nil)
+	_, code, err := utils.POST[map[string]any](ctx, nil, srv.URL+"/limited", nil, map[string]any{}, "json")
+	if code != 429 || err == nil {
+		t.Fatalf("limited = %d, %v", code, err)
+	}
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// * nothing secret reaches the file
+	cassette, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 4 {
+		t.Fatalf("interactions = %+v", cassette.Interactions)
+	}
+	for _, it := range cassette.Interactions {
+		all := it.URL + it.Request + it.Response + fmt.Sprint(it.Header)
+		if strings.Contains(all, "AIza-secret") || strings.Contains(all, "sk-live") || strings.Contains(all, "secret-token") || strings.Contains(all, "abc") {
+			t.Errorf("secret recorded: %+v", it)
+		}
+	}
+
+	// * replay answers the same way with the server gone
+	srv.Close()
+	recorded := hits.Load()
+	player, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetTransport(player)
+
+	// * out of order, the request body picks the answer
+	if got, err := stream(ctx, srv.URL+"/stream?key=other-key", "b"); err != nil || got != second {
+		t.Errorf("replayed b = %q, %v; want %q", got, err, second)
+	}
+	if got, err := stream(ctx, srv.URL+"/stream?key=other-key", "a"); err != nil || got != first {
+		t.Errorf("replayed a = %q, %v; want %q", got, err, first)
+	}
+	token, _, _ := utils.GET[map[string]any](ctx, nil, srv.URL+"/token", nil)
+	if token["token"] != redacted {
+		t.Errorf("token = %v", token)
+	}
+	if unused := player.Unused(); len(unused) != 1 || !strings.HasSuffix(unused[0], "/limited") {
+		t.Errorf("unused = %v", unused)
+	}
+	_, _, err = utils.POST[map[string]any](ctx, nil, srv.URL+"/limited", nil, map[string]any{}, "json")
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 429 || httpErr.RetryAfter.Seconds() != 3 {
+		t.Errorf("replayed limited = %v", err)
+	}
+
+	// * each interaction answers once
+	if _, err := stream(ctx, srv.URL+"/stream?key=other-key", "a"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("exhausted = %v", err)
+	}
+	if hits.Load() != recorded {
+		t.Errorf("replay reached the server")
+	}
+}
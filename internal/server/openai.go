@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+)
+
+const (
+	// * routes with selectSkill / selectAgent like `agenvoy run`
+	autoModel = "auto"
+)
+
+type chatMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content any    `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
+	User     string        `json:"user,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatMessage `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+}
+
+type modelInfo struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	models := []modelInfo{{ID: autoModel, Object: "model", OwnedBy: "agenvoy"}}
+	for _, entry := range s.registry.Entries {
+		models = append(models, modelInfo{ID: entry.Name, Object: "model", OwnedBy: "agenvoy"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+// * only the final text of the tool loop is returned, tools needing confirmation
+// * are skipped unless the server runs with AllowAll
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeOpenAIError(w, bodyStatus(err), err.Error())
+		return
+	}
+
+	input := chatInput(req.Messages)
+	if input == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "messages must end with a user message")
+		return
+	}
+
+	var agent agentTypes.Agent
+	if req.Model != autoModel && req.Model != "" {
+		a, ok := s.registry.Registry[req.Model]
+		if !ok {
+			writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("model not found: %s", req.Model))
+			return
+		}
+		agent = a
+	}
+
+	// * messages already carry the history, so without user the request is stateless:
+	// * a fresh session with the transcript packed into the input;
+	// * with user the stored session holds the history and only the last message is sent
+	sessionID := req.User
+	if sessionID == "" {
+		sessionID = newRunID()
+	} else {
+		if !sessions.Valid(sessionID) {
+			writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("invalid user: %s", sessionID))
+			return
+		}
+		input = chatInput(req.Messages[len(req.Messages)-1:])
+	}
+	unlock, _ := s.lockSession(sessionID, true)
+	defer unlock()
+
+	cfg := *s.cfg
+	cfg.SessionID = sessionID
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	events := make(chan agentTypes.Event, 16)
+	go func() {
+		defer close(events)
+		var err error
+		if agent == nil {
+			err = exec.Run(ctx, &cfg, s.selector, s.registry, s.scanner, input, events)
+		} else {
+			err = exec.Execute(ctx, &cfg, agent, nil, input, events)
+		}
+		if err != nil {
+			events <- agentTypes.Event{Type: agentTypes.EventError, Err: err}
+		}
+	}()
+
+	model := req.Model
+	if model == "" {
+		model = autoModel
+	}
+	base := chatResponse{
+		ID:      "chatcmpl-" + strings.TrimPrefix(newRunID(), "run_"),
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+
+	if req.Stream {
+		s.streamChat(w, cancel, base, events)
+		return
+	}
+
+	var text string
+	var runErr error
+	for ev := range events {
+		switch ev.Type {
+		case agentTypes.EventText:
+			text = ev.Text
+		case agentTypes.EventToolConfirm:
+			ev.ReplyCh <- false
+		case agentTypes.EventError:
+			runErr = ev.Err
+		}
+	}
+	if runErr != nil && text == "" {
+		writeOpenAIError(w, http.StatusBadGateway, runErr.Error())
+		return
+	}
+
+	stop := "stop"
+	base.Object = "chat.completion"
+	base.Choices = []chatChoice{{
+		Message:      &chatMessage{Role: "assistant", Content: text},
+		FinishReason: &stop,
+	}}
+	writeJSON(w, http.StatusOK, base)
+}
+
+func (s *Server) streamChat(w http.ResponseWriter, cancel context.CancelFunc, base chatResponse, events <-chan agentTypes.Event) {
+	stream := newEventStream(w, false)
+	w.WriteHeader(http.StatusOK)
+
+	base.Object = "chat.completion.chunk"
+	send := func(choice chatChoice) {
+		chunk := base
+		chunk.Choices = []chatChoice{choice}
+		if err := stream.send("", chunk); err != nil {
+			cancel()
+		}
+	}
+
+	send(chatChoice{Delta: &chatMessage{Role: "assistant", Content: ""}})
+
+	// * EventText repeats the deltas, it is only sent when nothing was streamed
+	streamed := false
+	for ev := range events {
+		switch ev.Type {
+		case agentTypes.EventTextDelta:
+			streamed = true
+			send(chatChoice{Delta: &chatMessage{Content: ev.Text}})
+		case agentTypes.EventText:
+			if !streamed {
+				send(chatChoice{Delta: &chatMessage{Content: ev.Text}})
+			}
+			streamed = false
+		case agentTypes.EventToolConfirm:
+			ev.ReplyCh <- false
+		case agentTypes.EventError:
+			if ev.Err != nil {
+				send(chatChoice{Delta: &chatMessage{Content: "\n\n[error] " + ev.Err.Error()}})
+			}
+		}
+	}
+
+	stop := "stop"
+	send(chatChoice{Delta: &chatMessage{}, FinishReason: &stop})
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	_ = stream.flush()
+}
+
+// * the last user message is the input, earlier turns are passed along as a transcript
+func chatInput(messages []chatMessage) string {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return ""
+	}
+	last := strings.TrimSpace(chatContent(messages[len(messages)-1].Content))
+	if len(messages) == 1 {
+		return last
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Conversation so far:\n")
+	for _, m := range messages[:len(messages)-1] {
+		text := strings.TrimSpace(chatContent(m.Content))
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s] %s\n", m.Role, text)
+	}
+	sb.WriteString("\nUser request: ")
+	sb.WriteString(last)
+	return sb.String()
+}
+
+// * content is a string or a list of parts, only text parts are kept
+func chatContent(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []any:
+		var parts []string
+		for _, part := range v {
+			if m, ok := part.(map[string]any); ok && m["type"] == "text" {
+				if text, ok := m["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	writeJSON(w, status, map[string]any{
+		"error": map[string]string{
+			"message": message,
+			"type":    errType,
+		},
+	})
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+)
+
+func TestServer_Models(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/v1/models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Object string      `json:"object"`
+		Data   []modelInfo `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	if list.Object != "list" || len(list.Data) != 2 || list.Data[0].ID != "auto" || list.Data[1].ID != "fake" {
+		t.Errorf("models = %+v", list)
+	}
+}
+
+func chat(t *testing.T, url string, body any) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_ChatCompletions(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.cfg.AllowAll = true
+
+	resp := chat(t, ts.URL, map[string]any{
+		"model":    "auto",
+		"messages": []map[string]any{{"role": "user", "content": "say hi"}},
+	})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	var out chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Object != "chat.completion" || len(out.Choices) != 1 {
+		t.Fatalf("response = %+v", out)
+	}
+	if text, _ := out.Choices[0].Message.Content.(string); !strings.Contains(text, "echo:hi") {
+		t.Errorf("content = %v, want the tool result", out.Choices[0].Message.Content)
+	}
+
+	missing := chat(t, ts.URL, map[string]any{
+		"model":    "missing",
+		"messages": []map[string]any{{"role": "user", "content": "hi"}},
+	})
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown model status = %d", missing.StatusCode)
+	}
+}
+
+func TestServer_ChatCompletionsStream(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	// * without AllowAll the echo tool is skipped and the loop still finishes
+	resp := chat(t, ts.URL, map[string]any{
+		"model":  "fake",
+		"stream": true,
+		"messages": []map[string]any{
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": []map[string]any{{"type": "text", "text": "say hi"}}},
+		},
+	})
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var finished, done bool
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "data: ")
+		if line == "" {
+			continue
+		}
+		if line == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("bad chunk %q: %v", line, err)
+		}
+		if chunk.Object != "chat.completion.chunk" || chunk.Model != "fake" {
+			t.Errorf("chunk = %+v", chunk)
+		}
+		delta := chunk.Choices[0].Delta
+		if text, ok := delta.Content.(string); ok {
+			content.WriteString(text)
+		}
+		if chunk.Choices[0].FinishReason != nil {
+			finished = true
+		}
+	}
+
+	if !strings.Contains(content.String(), "Skipped by user") {
+		t.Errorf("streamed content = %q", content.String())
+	}
+	if !finished || !done {
+		t.Errorf("finished = %v, done = %v", finished, done)
+	}
+}
+
+func TestServer_ChatSessions(t *testing.T) {
+	s, ts := newTestServer(t)
+	agent := s.registry.Fallback.(*scriptedAgent)
+	messages := []map[string]any{
+		{"role": "user", "content": "first"},
+		{"role": "assistant", "content": "answer"},
+		{"role": "user", "content": "second"},
+	}
+	send := func(body map[string]any) []agentTypes.Message {
+		t.Helper()
+		agent.mu.Lock()
+		n := len(agent.inputs)
+		agent.mu.Unlock()
+		resp := chat(t, ts.URL, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d", resp.StatusCode)
+		}
+		agent.mu.Lock()
+		defer agent.mu.Unlock()
+		return agent.inputs[n]
+	}
+	users := func(msgs []agentTypes.Message) []string {
+		var list []string
+		for _, m := range msgs {
+			if text, ok := m.Content.(string); ok && m.Role == "user" {
+				list = append(list, text)
+			}
+		}
+		return list
+	}
+
+	// * without user nothing is replayed, the transcript is the only history
+	send(map[string]any{"model": "fake", "messages": messages})
+	got := users(send(map[string]any{"model": "fake", "messages": messages}))
+	if len(got) != 1 || !strings.Contains(got[0], "[assistant] answer") {
+		t.Errorf("stateless user messages = %q", got)
+	}
+
+	// * with user the stored session is replayed and only the last message is sent
+	send(map[string]any{"model": "fake", "user": "alice", "messages": messages[:1]})
+	got = users(send(map[string]any{"model": "fake", "user": "alice", "messages": messages}))
+	if len(got) != 2 || !strings.HasSuffix(got[0], "\nfirst") || !strings.HasSuffix(got[1], "\nsecond") {
+		t.Errorf("user session messages = %q", got)
+	}
+
+	list, err := sessions.List(s.cfg.ConfigDir, s.cfg.WorkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stateless int
+	for _, info := range list {
+		if strings.HasPrefix(info.ID, "run_") {
+			stateless++
+		}
+	}
+	if len(list) != 3 || stateless != 2 {
+		t.Errorf("sessions = %+v, want alice and one per stateless request", list)
+	}
+
+	invalid := chat(t, ts.URL, map[string]any{"model": "fake", "user": "a b", "messages": messages})
+	invalid.Body.Close()
+	if invalid.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid user status = %d", invalid.StatusCode)
+	}
+}
+
+func TestChatInput(t *testing.T) {
+	if got := chatInput([]chatMessage{{Role: "assistant", Content: "hi"}}); got != "" {
+		t.Errorf("input without trailing user message = %q", got)
+	}
+	got := chatInput([]chatMessage{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "second"},
+	})
+	if !strings.Contains(got, "[assistant] answer") || !strings.HasSuffix(got, "User request: second") {
+		t.Errorf("transcript = %q", got)
+	}
+}
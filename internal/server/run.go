@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+)
+
+type runRequest struct {
+	Input     string `json:"input"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+type run struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	confirms map[string]chan bool // * tool id to the ReplyCh of a pending EventToolConfirm
+}
+
+// * Event plus the fields dropped by its json tags
+type eventPayload struct {
+	agentTypes.Event
+	RunID string `json:"run_id"`
+	Error string `json:"error,omitempty"`
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, bodyStatus(err), err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Input) == "" {
+		writeError(w, http.StatusBadRequest, "input is required")
+		return
+	}
+	if req.SessionID != "" && !sessions.Valid(req.SessionID) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid session_id: %s", req.SessionID))
+		return
+	}
+
+	// * without session_id the run is stateless, a fresh session named after the run
+	runID := newRunID()
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = runID
+	}
+
+	unlock, ok := s.lockSession(sessionID, false)
+	if !ok {
+		writeError(w, http.StatusConflict, "session is busy")
+		return
+	}
+	defer unlock()
+
+	// * AllowAll only comes from the --allow flag of the server
+	cfg := *s.cfg
+	cfg.SessionID = sessionID
+
+	// * the run stops when the client disconnects
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	current := &run{
+		ID:        runID,
+		SessionID: sessionID,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+		confirms:  make(map[string]chan bool),
+	}
+	s.mu.Lock()
+	s.runs[current.ID] = current
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.runs, current.ID)
+		s.mu.Unlock()
+	}()
+
+	stream := newEventStream(w, wantsNDJSON(r))
+	w.Header().Set("X-Run-Id", current.ID)
+	w.WriteHeader(http.StatusOK)
+	stream.flush()
+
+	events := make(chan agentTypes.Event, 16)
+	go func() {
+		defer close(events)
+		if err := exec.Run(ctx, &cfg, s.selector, s.registry, s.scanner, req.Input, events); err != nil {
+			events <- agentTypes.Event{Type: agentTypes.EventError, Err: err}
+		}
+	}()
+
+	// * keep draining after a write error so exec.Run never blocks on events
+	for ev := range events {
+		payload := eventPayload{Event: ev, RunID: current.ID}
+		switch ev.Type {
+		case agentTypes.EventToolConfirm:
+			current.mu.Lock()
+			current.confirms[ev.ToolID] = ev.ReplyCh
+			current.mu.Unlock()
+		case agentTypes.EventError:
+			if ev.Err != nil {
+				payload.Error = ev.Err.Error()
+			}
+		}
+		if err := stream.send(ev.Type.String(), payload); err != nil {
+			cancel()
+		}
+	}
+}
+
+type confirmRequest struct {
+	ToolID string `json:"tool_id,omitempty"`
+	Allow  bool   `json:"allow"`
+}
+
+// * answers a pending EventToolConfirm, tool_id may be omitted when only one is pending
+func (s *Server) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	current, ok := s.getRun(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+
+	var req confirmRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, bodyStatus(err), err.Error())
+		return
+	}
+
+	current.mu.Lock()
+	if req.ToolID == "" && len(current.confirms) == 1 {
+		for id := range current.confirms {
+			req.ToolID = id
+		}
+	}
+	replyCh, ok := current.confirms[req.ToolID]
+	delete(current.confirms, req.ToolID)
+	current.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no pending confirm: %s", req.ToolID))
+		return
+	}
+	replyCh <- req.Allow
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	current, ok := s.getRun(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	current.cancel()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := make([]*run, 0, len(s.runs))
+	for _, current := range s.runs {
+		list = append(list, current)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{"runs": list})
+}
+
+// * sse by default, ndjson when asked for; an empty event name writes a bare data line
+type eventStream struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	ndjson bool
+}
+
+func newEventStream(w http.ResponseWriter, ndjson bool) *eventStream {
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	return &eventStream{
+		w:      w,
+		rc:     http.NewResponseController(w),
+		ndjson: ndjson,
+	}
+}
+
+func (e *eventStream) send(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	switch {
+	case e.ndjson:
+		_, err = fmt.Fprintf(e.w, "%s\n", data)
+	case name == "":
+		_, err = fmt.Fprintf(e.w, "data: %s\n\n", data)
+	default:
+		_, err = fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", name, data)
+	}
+	if err != nil {
+		return fmt.Errorf("fmt.Fprintf: %w", err)
+	}
+	return e.flush()
+}
+
+func (e *eventStream) flush() error {
+	return e.rc.Flush()
+}
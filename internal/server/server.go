@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+)
+
+// * drives exec.Run over http, one streamed response per run
+type Server struct {
+	// * required as bearer token when set
+	Token string
+
+	cfg      *exec.Config
+	selector agentTypes.Agent
+	registry agentTypes.AgentRegistry
+	scanner  *skill.Scanner
+
+	mu       sync.Mutex
+	runs     map[string]*run
+	sessions map[string]*sync.Mutex
+}
+
+func New(cfg *exec.Config, selector agentTypes.Agent, registry agentTypes.AgentRegistry, scanner *skill.Scanner) *Server {
+	if selector == nil {
+		selector = registry.Fallback
+	}
+	return &Server{
+		cfg:      cfg,
+		selector: selector,
+		registry: registry,
+		scanner:  scanner,
+		runs:     make(map[string]*run),
+		sessions: make(map[string]*sync.Mutex),
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/runs", s.handleRun)
+	mux.HandleFunc("GET /v1/runs", s.handleListRuns)
+	mux.HandleFunc("POST /v1/runs/{id}/confirm", s.handleConfirm)
+	mux.HandleFunc("POST /v1/runs/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("DELETE /v1/runs/{id}", s.handleCancel)
+	mux.HandleFunc("GET /v1/sessions", s.handleSessions)
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	return s.auth(mux)
+}
+
+func (s *Server) auth(next http.Handler) http.Handler {
+	if s.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// * one run per session at a time, history is not merged; without wait a busy session fails
+func (s *Server) lockSession(id string, wait bool) (func(), bool) {
+	s.mu.Lock()
+	lock, ok := s.sessions[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.sessions[id] = lock
+	}
+	s.mu.Unlock()
+
+	if wait {
+		lock.Lock()
+	} else if !lock.TryLock() {
+		return nil, false
+	}
+	return lock.Unlock, true
+}
+
+func (s *Server) getRun(id string) (*run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.runs[id]
+	return r, ok
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// * only json bodies are read, a form post from another origin cannot start a run
+var errContentType = errors.New("content type must be application/json")
+
+func decodeBody(r *http.Request, v any) error {
+	defer r.Body.Close()
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errContentType
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("decoder.Decode: %w", err)
+	}
+	return nil
+}
+
+func bodyStatus(err error) int {
+	if errors.Is(err, errContentType) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+// * a server reachable from other hosts, or one that skips confirmation, must not run without a token
+func NeedsToken(addr string, allowAll bool) bool {
+	if allowAll {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return true
+	}
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "run_" + hex.EncodeToString(b)
+}
+
+func wantsNDJSON(r *http.Request) bool {
+	if r.URL.Query().Get("stream") == "ndjson" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
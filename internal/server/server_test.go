@@ -0,0 +1,355 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/agents/exec"
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+	"github.com/pardnchiu/agenvoy/internal/skill"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+// * first Stream call asks for the echo tool, the second answers with its result,
+// * a "block" input waits until the run is cancelled
+type scriptedAgent struct {
+	mu     sync.Mutex
+	calls  int
+	inputs [][]agentTypes.Message // * messages of each call that ends with a user message
+}
+
+func (a *scriptedAgent) Send(ctx context.Context, messages []agentTypes.Message, toolDefs []toolTypes.ToolDef) (*agentTypes.Output, error) {
+	return &agentTypes.Output{Choices: []agentTypes.OutputChoices{textChoice("none")}}, nil
+}
+
+func (a *scriptedAgent) Stream(ctx context.Context, messages []agentTypes.Message, toolDefs []toolTypes.ToolDef, onDelta func(text string)) (*agentTypes.Output, error) {
+	last := messages[len(messages)-1]
+	text, _ := last.Content.(string)
+	if strings.HasSuffix(text, "block") {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	a.mu.Lock()
+	a.calls++
+	call := a.calls
+	if last.Role == "user" {
+		a.inputs = append(a.inputs, messages)
+	}
+	a.mu.Unlock()
+
+	if call == 1 {
+		choice := textChoice("")
+		choice.Message.ToolCalls = []agentTypes.ToolCall{{ID: "call_1", Type: "function"}}
+		choice.Message.ToolCalls[0].Function.Name = "echo"
+		choice.Message.ToolCalls[0].Function.Arguments = `{"text":"hi"}`
+		return &agentTypes.Output{Choices: []agentTypes.OutputChoices{choice}}, nil
+	}
+	onDelta(text)
+	return &agentTypes.Output{Choices: []agentTypes.OutputChoices{textChoice(text)}}, nil
+}
+
+func (a *scriptedAgent) Execute(ctx context.Context, skill *skill.Skill, userInput string, events chan<- agentTypes.Event, allowAll bool) error {
+	return nil
+}
+
+func textChoice(text string) agentTypes.OutputChoices {
+	return agentTypes.OutputChoices{
+		Message: agentTypes.Message{Role: "assistant", Content: text},
+	}
+}
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := exec.NewConfig(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Tools = []toolTypes.Tool{toolTypes.NewTool(toolTypes.ToolDef{
+		Function: toolTypes.ToolFunction{Name: "echo"},
+	}, 0, func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+		var params struct {
+			Text string `json:"text"`
+		}
+		_ = json.Unmarshal(args, &params)
+		return "echo:" + params.Text, nil
+	})}
+
+	agent := &scriptedAgent{}
+	registry := agentTypes.AgentRegistry{
+		Registry: map[string]agentTypes.Agent{"fake": agent},
+		Entries:  []agentTypes.AgentEntry{{Name: "fake"}},
+		Fallback: agent,
+	}
+	s := New(cfg, nil, registry, skill.NewScannerWithPaths(t.TempDir()))
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func post(t *testing.T, url string, body any) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	return resp
+}
+
+func TestServer_RunWithRemoteConfirm(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp := post(t, ts.URL+"/v1/runs", map[string]any{"input": "say hi", "session_id": "dashboard"})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("status = %d, content type = %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	runID := resp.Header.Get("X-Run-Id")
+
+	var seen []string
+	var result, text string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var ev struct {
+			Type   string `json:"type"`
+			RunID  string `json:"run_id"`
+			ToolID string `json:"tool_id"`
+			Text   string `json:"text"`
+			Result string `json:"result"`
+			Error  string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("bad event %q: %v", scanner.Text(), err)
+		}
+		if ev.RunID != runID {
+			t.Errorf("run_id = %s, want %s", ev.RunID, runID)
+		}
+		seen = append(seen, ev.Type)
+
+		switch ev.Type {
+		case "tool_confirm":
+			confirm := post(t, ts.URL+"/v1/runs/"+runID+"/confirm", map[string]any{"tool_id": ev.ToolID, "allow": true})
+			confirm.Body.Close()
+			if confirm.StatusCode != http.StatusNoContent {
+				t.Fatalf("confirm status = %d", confirm.StatusCode)
+			}
+		case "tool_result":
+			result = ev.Result
+		case "text":
+			text = ev.Text
+		case "error":
+			t.Fatalf("unexpected error event: %s", ev.Error)
+		}
+	}
+
+	if result != "echo:hi" || !strings.Contains(text, "echo:hi") {
+		t.Errorf("result = %q, text = %q", result, text)
+	}
+	if seen[len(seen)-1] != "done" {
+		t.Errorf("last event = %s, events = %v", seen[len(seen)-1], seen)
+	}
+}
+
+func TestServer_CancelAndBusySession(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	resp := post(t, ts.URL+"/v1/runs", map[string]any{"input": "block", "session_id": "busy"})
+	defer resp.Body.Close()
+	runID := resp.Header.Get("X-Run-Id")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := s.getRun(runID); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	busy := post(t, ts.URL+"/v1/runs", map[string]any{"input": "again", "session_id": "busy"})
+	busy.Body.Close()
+	if busy.StatusCode != http.StatusConflict {
+		t.Errorf("second run on busy session = %d, want 409", busy.StatusCode)
+	}
+
+	cancel := post(t, ts.URL+"/v1/runs/"+runID+"/cancel", nil)
+	cancel.Body.Close()
+	if cancel.StatusCode != http.StatusNoContent {
+		t.Fatalf("cancel status = %d", cancel.StatusCode)
+	}
+
+	var last string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		last = scanner.Text()
+	}
+	if !strings.Contains(last, `"type":"error"`) {
+		t.Errorf("last event after cancel = %s", last)
+	}
+
+	listResp, err := http.Get(ts.URL + "/v1/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listResp.Body.Close()
+	var list struct {
+		Sessions []sessions.Info `json:"sessions"`
+	}
+	json.NewDecoder(listResp.Body).Decode(&list)
+	if len(list.Sessions) != 1 || list.Sessions[0].ID != "busy" {
+		t.Errorf("sessions = %+v", list.Sessions)
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.Token = "secret"
+	ts.Config.Handler = s.Handler()
+
+	resp, err := http.Get(ts.URL + "/v1/runs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without token = %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/runs", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status with token = %d", resp.StatusCode)
+	}
+}
+
+func TestServer_StatelessRuns(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	first := post(t, ts.URL+"/v1/runs", map[string]any{"input": "block"})
+	defer first.Body.Close()
+	firstID := first.Header.Get("X-Run-Id")
+
+	// * a second run without session_id does not wait for the first
+	second := post(t, ts.URL+"/v1/runs", map[string]any{"input": "block"})
+	defer second.Body.Close()
+	if first.StatusCode != http.StatusOK || second.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, %d, want 200", first.StatusCode, second.StatusCode)
+	}
+
+	current, ok := s.getRun(firstID)
+	if !ok || current.SessionID != firstID {
+		t.Errorf("session of stateless run = %+v, want %s", current, firstID)
+	}
+	for _, resp := range []*http.Response{first, second} {
+		cancel := post(t, ts.URL+"/v1/runs/"+resp.Header.Get("X-Run-Id")+"/cancel", nil)
+		cancel.Body.Close()
+	}
+}
+
+func TestServer_InvalidSessionID(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	// * rejected before a session is locked or the stream starts
+	for _, id := range []string{"../escape", "a b", strings.Repeat("x", 65)} {
+		resp := post(t, ts.URL+"/v1/runs", map[string]any{"input": "say hi", "session_id": id})
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body["error"], "invalid session_id") {
+			t.Errorf("session_id %q = %d %v, want 400", id, resp.StatusCode, body)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.runs) != 0 || len(s.sessions) != 0 {
+		t.Errorf("runs = %d, session locks = %d, want none", len(s.runs), len(s.sessions))
+	}
+}
+
+func TestServer_ContentType(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/runs", strings.NewReader(`{"input":"say hi"}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("content type %q = %d, want 415", contentType, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_AllowAllField(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	resp := post(t, ts.URL+"/v1/runs", map[string]any{"input": "say hi", "allow_all": true})
+	defer resp.Body.Close()
+	runID := resp.Header.Get("X-Run-Id")
+
+	// * allow_all in the request is ignored, the echo tool still asks
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), `"type":"tool_confirm"`) {
+			cancel := post(t, ts.URL+"/v1/runs/"+runID+"/cancel", nil)
+			cancel.Body.Close()
+			return
+		}
+		if strings.Contains(scanner.Text(), `"type":"tool_result"`) {
+			t.Fatal("tool ran without confirmation")
+		}
+	}
+	if s.cfg.AllowAll {
+		t.Error("server config changed")
+	}
+	t.Error("no tool_confirm event")
+}
+
+func TestNeedsToken(t *testing.T) {
+	tests := []struct {
+		addr     string
+		allowAll bool
+		want     bool
+	}{
+		{"127.0.0.1:8080", false, false},
+		{"localhost:8080", false, false},
+		{"[::1]:8080", false, false},
+		{"127.0.0.1:8080", true, true},
+		{":8080", false, true},
+		{"0.0.0.0:8080", false, true},
+		{"192.168.1.10:8080", false, true},
+		{"example.com:8080", false, true},
+	}
+	for _, tt := range tests {
+		if got := NeedsToken(tt.addr, tt.allowAll); got != tt.want {
+			t.Errorf("NeedsToken(%q, %v) = %v, want %v", tt.addr, tt.allowAll, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/pardnchiu/agenvoy/internal/sessions"
+)
+
+// * session folders under ~/.config/agenvoy/sessions, newest first
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	list, err := sessions.List(s.cfg.ConfigDir, s.cfg.WorkDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": list})
+}
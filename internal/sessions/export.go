@@ -0,0 +1,255 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+const (
+	exportVersion  = 1
+	historyFile    = "history.json"
+	summaryFile    = "summary.json"
+	toolFileLayout = "2006-01-02-15-04-05"
+	toolDirLayout  = "2006-01-02"
+)
+
+// * everything a session keeps: history, summary and tool runs
+type Export struct {
+	Version    int            `json:"version"`
+	ID         string         `json:"id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Summary    map[string]any `json:"summary,omitempty"`
+	History    []Turn         `json:"history"`
+	ToolRuns   []ToolRun      `json:"tool_runs,omitempty"`
+}
+
+type Turn struct {
+	Role    string    `json:"role"`
+	Content string    `json:"content"`
+	Time    time.Time `json:"time,omitzero"` // * from the ts: prefix
+}
+
+// * tool calls of one Execute
+type ToolRun struct {
+	Time  time.Time  `json:"time"`
+	Calls []ToolCall `json:"calls"`
+}
+
+type ToolCall struct {
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Args   string `json:"args,omitempty"`
+	Result string `json:"result,omitempty"`
+}
+
+// * tool runs are read from every work dir bound to id and from workDir
+func Load(root *utils.ConfigDirData, workDir, id string) (*Export, error) {
+	store, err := Open(root)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+	if !Valid(id) || !store.Exists(id) {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+
+	export := &Export{
+		Version:    exportVersion,
+		ID:         id,
+		ExportedAt: time.Now(),
+		History:    []Turn{},
+	}
+
+	history, err := store.History(id)
+	if err != nil {
+		return nil, fmt.Errorf("store.History: %w", err)
+	}
+	for _, m := range history {
+		t, content := splitTS(messageText(m.Content))
+		export.History = append(export.History, Turn{Role: m.Role, Content: content, Time: t})
+	}
+
+	summary, err := store.Summary(id)
+	if err != nil {
+		return nil, fmt.Errorf("store.Summary: %w", err)
+	}
+	if summary != nil {
+		if err := json.Unmarshal(summary, &export.Summary); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal %s: %w", summaryFile, err)
+		}
+	}
+
+	workDirs, err := boundDirs(root, id)
+	if err != nil {
+		return nil, err
+	}
+	if workDir != "" {
+		if abs, err := absDir(workDir); err == nil && !slices.Contains(workDirs, abs) {
+			workDirs = append(workDirs, abs)
+		}
+	}
+	logs, err := store.ToolRuns(id, workDirs)
+	if err != nil {
+		return nil, fmt.Errorf("store.ToolRuns: %w", err)
+	}
+	for _, log := range logs {
+		export.ToolRuns = append(export.ToolRuns, ToolRun{Time: log.Time, Calls: pairToolCalls(log.Messages)})
+	}
+
+	return export, nil
+}
+
+// * assistant tool_calls are matched with tool results by id, older files only have results
+func pairToolCalls(messages []agentTypes.Message) []ToolCall {
+	var calls []ToolCall
+	index := make(map[string]int)
+	for _, m := range messages {
+		switch m.Role {
+		case "assistant":
+			for _, tc := range m.ToolCalls {
+				index[tc.ID] = len(calls)
+				calls = append(calls, ToolCall{
+					ID:   tc.ID,
+					Name: tc.Function.Name,
+					Args: tc.Function.Arguments,
+				})
+			}
+		case "tool":
+			if i, ok := index[m.ToolCallID]; ok && m.ToolCallID != "" {
+				calls[i].Result = messageText(m.Content)
+				continue
+			}
+			calls = append(calls, ToolCall{ID: m.ToolCallID, Result: messageText(m.Content)})
+		}
+	}
+	return calls
+}
+
+// * writes the export as a new session, tool runs go under workDir
+func Import(root *utils.ConfigDirData, workDir string, export *Export, id string) (_ string, err error) {
+	if id == "" {
+		id = export.ID
+	}
+	workDir, err = absDir(workDir)
+	if err != nil {
+		return "", err
+	}
+	if err := Create(root, id); err != nil {
+		return "", err
+	}
+	store, err := Open(root)
+	if err != nil {
+		return "", fmt.Errorf("Open: %w", err)
+	}
+	defer func() {
+		// * no half imported session
+		if err != nil {
+			store.Delete(id, []string{workDir})
+		}
+	}()
+
+	history := make([]agentTypes.Message, 0, len(export.History))
+	for _, turn := range export.History {
+		content := turn.Content
+		if !turn.Time.IsZero() {
+			content = fmt.Sprintf("ts:%d\n%s", turn.Time.Unix(), content)
+		}
+		history = append(history, agentTypes.Message{Role: turn.Role, Content: content})
+	}
+	if err := store.AppendHistory(id, history...); err != nil {
+		return "", fmt.Errorf("store.AppendHistory: %w", err)
+	}
+
+	if len(export.Summary) > 0 {
+		summary, err := json.Marshal(export.Summary)
+		if err != nil {
+			return "", fmt.Errorf("json.Marshal: %w", err)
+		}
+		if err := store.SetSummary(id, summary); err != nil {
+			return "", fmt.Errorf("store.SetSummary: %w", err)
+		}
+		if err := RecordSummary(store, id, len(history)); err != nil {
+			return "", err
+		}
+	}
+
+	for _, run := range export.ToolRuns {
+		log := ToolLog{Time: run.Time, WorkDir: workDir, Messages: unpairToolCalls(run.Calls)}
+		if err := store.AppendToolRun(id, workDir, log); err != nil {
+			return "", fmt.Errorf("store.AppendToolRun: %w", err)
+		}
+	}
+	return id, nil
+}
+
+func unpairToolCalls(calls []ToolCall) []agentTypes.Message {
+	assistant := agentTypes.Message{Role: "assistant"}
+	results := make([]agentTypes.Message, 0, len(calls))
+	for _, c := range calls {
+		if c.Name != "" {
+			tc := agentTypes.ToolCall{ID: c.ID, Type: "function"}
+			tc.Function.Name = c.Name
+			tc.Function.Arguments = c.Args
+			assistant.ToolCalls = append(assistant.ToolCalls, tc)
+		}
+		results = append(results, agentTypes.Message{Role: "tool", Content: c.Result, ToolCallID: c.ID})
+	}
+	if len(assistant.ToolCalls) == 0 {
+		return results
+	}
+	return append([]agentTypes.Message{assistant}, results...)
+}
+
+// * ts:{unix}\n{content}, as written by getSession and Execute
+func splitTS(content string) (time.Time, string) {
+	rest, ok := strings.CutPrefix(content, "ts:")
+	if !ok {
+		return time.Time{}, content
+	}
+	sec, body, ok := strings.Cut(rest, "\n")
+	if !ok {
+		return time.Time{}, content
+	}
+	ts, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, content
+	}
+	return time.Unix(ts, 0), body
+}
+
+func messageText(content any) string {
+	switch v := content.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+func boundDirs(root *utils.ConfigDirData, id string) ([]string, error) {
+	bindings, err := readBindings(root)
+	if err != nil {
+		return nil, fmt.Errorf("readBindings: %w", err)
+	}
+	var dirs []string
+	for dir, bound := range bindings {
+		if bound == id {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
@@ -0,0 +1,158 @@
+package sessions
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func seedSession(t *testing.T) (*utils.ConfigDirData, string) {
+	t.Helper()
+	root, base := newRoot(t)
+	repo := filepath.Join(base, "repo")
+
+	if err := Create(root, "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Switch(root, repo, "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root.Home, sessionDir, "demo")
+	writeFile(t, filepath.Join(dir, historyFile), `[
+		{"role":"user","content":"ts:1760000000\nprice of <b>2330</b>?"},
+		{"role":"assistant","content":"ts:1760000060\n`+"```"+`\nabout 1000\n`+"```"+`"}
+	]`)
+	writeFile(t, filepath.Join(dir, summaryFile), `{
+		"core_discussion":"TSMC price",
+		"discussion_log":[{"time":"2026-02-27 23:57","topic":"2330","conclusion":"resolved"}],
+		"key_data":["1000 TWD"]
+	}`)
+
+	runTime := time.Unix(1760000030, 0).Local()
+	writeFile(t, filepath.Join(utils.ProjectDir(repo), sessionDir, "demo", runTime.Format(toolDirLayout), runTime.Format(toolFileLayout)+".json"), `[
+		{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"fetch_yahoo_finance","arguments":"{\"symbol\":\"2330.TW\"}"}}]},
+		{"role":"tool","content":"`+"```"+`\n1000\n`+"```"+`","tool_call_id":"call_1"}
+	]`)
+	return root, repo
+}
+
+func TestLoad(t *testing.T) {
+	root, repo := seedSession(t)
+
+	export, err := Load(root, repo, "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.History) != 2 || export.History[0].Content != "price of <b>2330</b>?" ||
+		export.History[0].Time.Unix() != 1760000000 {
+		t.Errorf("history = %+v", export.History)
+	}
+	if len(export.ToolRuns) != 1 || len(export.ToolRuns[0].Calls) != 1 {
+		t.Fatalf("tool runs = %+v", export.ToolRuns)
+	}
+	call := export.ToolRuns[0].Calls[0]
+	if call.Name != "fetch_yahoo_finance" || call.Result != "```\n1000\n```" || !strings.Contains(call.Args, "2330.TW") {
+		t.Errorf("call = %+v", call)
+	}
+	if export.ToolRuns[0].Time.Unix() != 1760000030 {
+		t.Errorf("tool run time = %v", export.ToolRuns[0].Time)
+	}
+}
+
+func TestRender(t *testing.T) {
+	root, repo := seedSession(t)
+	export, err := Load(root, repo, "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Unix(1760000000, 0).Local().Format(timeLayout)
+
+	var md bytes.Buffer
+	if err := Render(&md, export, FormatMarkdown, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"### [0] User · " + date, "### Core Discussion", "- 2026-02-27 23:57 2330: resolved", "#### `fetch_yahoo_finance`", "````\n```\n1000\n```\n````"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown missing %q:\n%s", want, md.String())
+		}
+	}
+	if strings.Contains(md.String(), embedMarker) {
+		t.Error("show output should not embed data")
+	}
+
+	var html bytes.Buffer
+	if err := Render(&html, export, FormatHTML, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "&lt;b&gt;2330&lt;/b&gt;") || !strings.Contains(html.String(), "<time>"+date+"</time>") {
+		t.Errorf("html = %s", html.String())
+	}
+
+	if err := Render(&html, export, "pdf", false); err == nil {
+		t.Error("unsupported format accepted")
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			root, repo := seedSession(t)
+			export, err := Load(root, repo, "demo")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := Render(&buf, export, format, true); err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := Parse(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Import(root, repo, parsed, ""); err == nil {
+				t.Error("import over an existing session should fail")
+			}
+			id, err := Import(root, repo, parsed, "copy")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			copied, err := Load(root, repo, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(copied.History) != len(export.History) || copied.History[1].Content != export.History[1].Content ||
+				!copied.History[1].Time.Equal(export.History[1].Time) {
+				t.Errorf("history = %+v", copied.History)
+			}
+			if copied.Summary["core_discussion"] != "TSMC price" {
+				t.Errorf("summary = %+v", copied.Summary)
+			}
+			if len(copied.ToolRuns) != 1 || copied.ToolRuns[0].Calls[0].Name != "fetch_yahoo_finance" {
+				t.Errorf("tool runs = %+v", copied.ToolRuns)
+			}
+		})
+	}
+
+	if _, err := Parse([]byte("# Session demo\n")); err == nil {
+		t.Error("markdown without embedded data should not parse")
+	}
+}
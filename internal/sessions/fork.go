@@ -0,0 +1,188 @@
+package sessions
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * summary snapshots of the json backend, next to summary.json
+const snapshotFile = "summaries.json"
+
+// * called after history is written, an unchanged summary is not recorded twice
+func RecordSummary(store Store, id string, turns int) error {
+	summary, err := store.Summary(id)
+	if err != nil {
+		return fmt.Errorf("store.Summary: %w", err)
+	}
+	if summary == nil {
+		return nil
+	}
+
+	snapshots, err := store.Snapshots(id)
+	if err != nil {
+		return fmt.Errorf("store.Snapshots: %w", err)
+	}
+	if n := len(snapshots); n > 0 && bytes.Equal(snapshots[n-1].Summary, summary) {
+		return nil
+	}
+	if err := store.AppendSnapshot(id, Snapshot{Turns: turns, Summary: summary}); err != nil {
+		return fmt.Errorf("store.AppendSnapshot: %w", err)
+	}
+	return nil
+}
+
+// * copies the first index history entries of id into newID, index < 0 counts from the end
+func Fork(root *utils.ConfigDirData, id, newID string, index int) (err error) {
+	store, history, cut, err := readHistory(root, id, index)
+	if err != nil {
+		return err
+	}
+	workDirs, err := boundDirs(root, id)
+	if err != nil {
+		return err
+	}
+	if err := Create(root, newID); err != nil {
+		return err
+	}
+	defer func() {
+		// * no half forked session
+		if err != nil {
+			store.Delete(newID, workDirs)
+		}
+	}()
+
+	if err := store.AppendHistory(newID, history[:cut]...); err != nil {
+		return fmt.Errorf("store.AppendHistory: %w", err)
+	}
+
+	snapshots, summary, err := keptSummary(store, id, len(history), cut)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if err := store.AppendSnapshot(newID, snapshot); err != nil {
+			return fmt.Errorf("store.AppendSnapshot: %w", err)
+		}
+	}
+	if summary != nil {
+		if err := store.SetSummary(newID, summary); err != nil {
+			return fmt.Errorf("store.SetSummary: %w", err)
+		}
+	}
+
+	logs, err := store.ToolRuns(id, workDirs)
+	if err != nil {
+		return fmt.Errorf("store.ToolRuns: %w", err)
+	}
+	before := boundary(history, cut)
+	for _, log := range logs {
+		if !before.IsZero() && !log.Time.Before(before) {
+			continue
+		}
+		if err := store.AppendToolRun(newID, log.WorkDir, log); err != nil {
+			return fmt.Errorf("store.AppendToolRun: %w", err)
+		}
+	}
+	return nil
+}
+
+// * keeps the first index history entries of id in place, index < 0 counts from the end
+func Rewind(root *utils.ConfigDirData, id string, index int) error {
+	store, history, cut, err := readHistory(root, id, index)
+	if err != nil {
+		return err
+	}
+
+	_, summary, err := keptSummary(store, id, len(history), cut)
+	if err != nil {
+		return err
+	}
+	if err := store.TruncateHistory(id, cut); err != nil {
+		return fmt.Errorf("store.TruncateHistory: %w", err)
+	}
+	if err := store.TruncateSnapshots(id, cut); err != nil {
+		return fmt.Errorf("store.TruncateSnapshots: %w", err)
+	}
+	// * without a summary the next reply writes a new one from the kept history
+	if err := store.SetSummary(id, summary); err != nil {
+		return fmt.Errorf("store.SetSummary: %w", err)
+	}
+
+	before := boundary(history, cut)
+	if before.IsZero() {
+		return nil
+	}
+	workDirs, err := boundDirs(root, id)
+	if err != nil {
+		return err
+	}
+	if err := store.RemoveToolRuns(id, workDirs, before); err != nil {
+		return fmt.Errorf("store.RemoveToolRuns: %w", err)
+	}
+	return nil
+}
+
+func readHistory(root *utils.ConfigDirData, id string, index int) (Store, []agentTypes.Message, int, error) {
+	store, err := Open(root)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("Open: %w", err)
+	}
+	if !Valid(id) || !store.Exists(id) {
+		return nil, nil, 0, fmt.Errorf("session not found: %s", id)
+	}
+
+	history, err := store.History(id)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("store.History: %w", err)
+	}
+
+	cut := index
+	if cut < 0 {
+		cut += len(history)
+	}
+	if cut < 0 || cut > len(history) {
+		return nil, nil, 0, fmt.Errorf("index %d out of range, history has %d entries", index, len(history))
+	}
+	return store, history, cut, nil
+}
+
+// * snapshots covering history[:cut] and the summary that goes with them
+func keptSummary(store Store, id string, turns, cut int) ([]Snapshot, []byte, error) {
+	snapshots, err := store.Snapshots(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("store.Snapshots: %w", err)
+	}
+	kept := make([]Snapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		if s.Turns <= cut {
+			kept = append(kept, s)
+		}
+	}
+
+	if cut == turns {
+		// * nothing dropped, the current summary still applies
+		summary, err := store.Summary(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("store.Summary: %w", err)
+		}
+		return kept, summary, nil
+	}
+	if n := len(kept); n > 0 {
+		return kept, kept[n-1].Summary, nil
+	}
+	return kept, nil, nil
+}
+
+// * tool runs are written after the reply of their turn, so runs before the first dropped entry are kept;
+// * zero means every run is kept
+func boundary(history []agentTypes.Message, cut int) time.Time {
+	if cut >= len(history) {
+		return time.Time{}
+	}
+	t, _ := splitTS(messageText(history[cut].Content))
+	return t
+}
@@ -0,0 +1,135 @@
+package sessions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * two turns, a summary snapshot and a tool run after each reply
+func seedTurns(t *testing.T) (*utils.ConfigDirData, string) {
+	t.Helper()
+	root, base := newRoot(t)
+	repo := filepath.Join(base, "repo")
+	if err := Create(root, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Switch(root, repo, "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root.Home, sessionDir, "main")
+	history := []map[string]string{
+		{"role": "user", "content": "ts:1760000000\nfirst"},
+		{"role": "assistant", "content": "ts:1760000010\nfirst answer"},
+		{"role": "user", "content": "ts:1760000100\nsecond"},
+		{"role": "assistant", "content": "ts:1760000110\nsecond answer"},
+	}
+	for i, summary := range []string{`{"core_discussion":"first"}`, `{"core_discussion":"second"}`} {
+		data, _ := json.Marshal(history[:2*(i+1)])
+		writeFile(t, filepath.Join(dir, historyFile), string(data))
+		writeFile(t, filepath.Join(dir, summaryFile), summary)
+		if err := RecordSummary(store, "main", 2*(i+1)); err != nil {
+			t.Fatal(err)
+		}
+		// * unchanged summary is not recorded twice
+		if err := RecordSummary(store, "main", 2*(i+1)); err != nil {
+			t.Fatal(err)
+		}
+
+		runTime := time.Unix(int64(1760000010+100*i), 0).Local()
+		writeFile(t, filepath.Join(utils.ProjectDir(repo), sessionDir, "main", runTime.Format(toolDirLayout), runTime.Format(toolFileLayout)+".json"),
+			`[{"role":"tool","content":"run","tool_call_id":"call"}]`)
+	}
+	return root, repo
+}
+
+func TestFork(t *testing.T) {
+	root, repo := seedTurns(t)
+
+	store, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := store.Snapshots("main")
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("snapshots = %+v, %v", snapshots, err)
+	}
+
+	if err := Fork(root, "main", "retry", 2); err != nil {
+		t.Fatal(err)
+	}
+	forked, err := Load(root, repo, "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forked.History) != 2 || forked.History[1].Content != "first answer" {
+		t.Errorf("history = %+v", forked.History)
+	}
+	if forked.Summary["core_discussion"] != "first" {
+		t.Errorf("summary = %+v", forked.Summary)
+	}
+	if len(forked.ToolRuns) != 1 {
+		t.Errorf("tool runs = %+v", forked.ToolRuns)
+	}
+
+	// * source is untouched
+	source, err := Load(root, repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(source.History) != 4 || source.Summary["core_discussion"] != "second" || len(source.ToolRuns) != 2 {
+		t.Errorf("source changed: %+v", source)
+	}
+
+	if err := Fork(root, "main", "full", 4); err != nil {
+		t.Fatal(err)
+	}
+	if full, _ := Load(root, repo, "full"); full.Summary["core_discussion"] != "second" {
+		t.Errorf("full fork summary = %+v", full.Summary)
+	}
+
+	if err := Fork(root, "main", "bad", 5); err == nil {
+		t.Error("out of range index accepted")
+	}
+	if exists(root, "bad") {
+		t.Error("failed fork left a session behind")
+	}
+}
+
+func TestRewind(t *testing.T) {
+	root, repo := seedTurns(t)
+
+	if err := Rewind(root, "main", -2); err != nil {
+		t.Fatal(err)
+	}
+	rewound, err := Load(root, repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rewound.History) != 2 || rewound.Summary["core_discussion"] != "first" || len(rewound.ToolRuns) != 1 {
+		t.Errorf("rewound = %+v", rewound)
+	}
+
+	if err := Rewind(root, "main", 0); err != nil {
+		t.Fatal(err)
+	}
+	empty, err := Load(root, repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.History) != 0 || empty.Summary != nil || len(empty.ToolRuns) != 0 {
+		t.Errorf("rewind to 0 = %+v", empty)
+	}
+	if _, err := os.Stat(filepath.Join(root.Home, sessionDir, "main", summaryFile)); !os.IsNotExist(err) {
+		t.Errorf("summary.json should be removed: %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * {home}/sessions/{id}/history.json, summary.json, summaries.json, usage.json,
+// * tool runs in {work dir}/.config/agenvoy/sessions/{id}/{date}/{date-time}.json
+type jsonStore struct {
+	dir string
+}
+
+func newJSONStore(home string) *jsonStore {
+	return &jsonStore{dir: filepath.Join(home, sessionDir)}
+}
+
+func (s *jsonStore) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+func (s *jsonStore) Exists(id string) bool {
+	if !Valid(id) {
+		return false
+	}
+	info, err := os.Stat(s.path(id))
+	return err == nil && info.IsDir()
+}
+
+func (s *jsonStore) Create(id string) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	if err := os.Mkdir(s.path(id), 0755); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("session already exists: %s", id)
+		}
+		return fmt.Errorf("os.Mkdir: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonStore) List() ([]Meta, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+
+	list := make([]Meta, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !Valid(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, Meta{
+			ID:        entry.Name(),
+			UpdatedAt: lastUpdate(s.path(entry.Name()), info.ModTime()),
+		})
+	}
+	return list, nil
+}
+
+// * folder mtime does not change when history.json is rewritten
+func lastUpdate(dir string, fallback time.Time) time.Time {
+	latest := fallback
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return latest
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func (s *jsonStore) Rename(oldID, newID string, workDirs []string) error {
+	if err := os.Rename(s.path(oldID), s.path(newID)); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+	for _, dir := range workDirs {
+		workPath := filepath.Join(utils.ProjectDir(dir), sessionDir)
+		if _, err := os.Stat(filepath.Join(workPath, oldID)); err != nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(workPath, oldID), filepath.Join(workPath, newID)); err != nil {
+			slog.Warn("failed to rename session logs",
+				slog.String("path", workPath),
+				slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (s *jsonStore) Delete(id string, workDirs []string) error {
+	if err := os.RemoveAll(s.path(id)); err != nil {
+		return fmt.Errorf("os.RemoveAll: %w", err)
+	}
+	for _, dir := range workDirs {
+		if err := os.RemoveAll(filepath.Join(utils.ProjectDir(dir), sessionDir, id)); err != nil {
+			slog.Warn("failed to remove session logs",
+				slog.String("path", dir),
+				slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (s *jsonStore) History(id string) ([]agentTypes.Message, error) {
+	var history []agentTypes.Message
+	if err := readJSON(filepath.Join(s.path(id), historyFile), &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// * the json layout has no append, history.json is rewritten
+func (s *jsonStore) AppendHistory(id string, messages ...agentTypes.Message) error {
+	history, err := s.History(id)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		history = []agentTypes.Message{}
+	}
+	return writeJSON(filepath.Join(s.path(id), historyFile), append(history, messages...))
+}
+
+func (s *jsonStore) TruncateHistory(id string, n int) error {
+	history, err := s.History(id)
+	if err != nil {
+		return err
+	}
+	if n >= len(history) {
+		return nil
+	}
+	return writeJSON(filepath.Join(s.path(id), historyFile), history[:n])
+}
+
+func (s *jsonStore) Summary(id string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.path(id), summaryFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	return data, nil
+}
+
+func (s *jsonStore) SetSummary(id string, summary []byte) error {
+	path := filepath.Join(s.path(id), summaryFile)
+	if summary == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("os.Remove: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, summary, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonStore) Snapshots(id string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	if err := readJSON(filepath.Join(s.path(id), snapshotFile), &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (s *jsonStore) AppendSnapshot(id string, snapshot Snapshot) error {
+	snapshots, err := s.Snapshots(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(s.path(id), snapshotFile), append(snapshots, snapshot))
+}
+
+func (s *jsonStore) TruncateSnapshots(id string, turns int) error {
+	snapshots, err := s.Snapshots(id)
+	if err != nil {
+		return err
+	}
+	kept := make([]Snapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		if snapshot.Turns <= turns {
+			kept = append(kept, snapshot)
+		}
+	}
+	if len(kept) == len(snapshots) {
+		return nil
+	}
+	return writeJSON(filepath.Join(s.path(id), snapshotFile), kept)
+}
+
+func (s *jsonStore) ToolRuns(id string, workDirs []string) ([]ToolLog, error) {
+	var logs []ToolLog
+	for _, wd := range workDirs {
+		files, err := toolRunFiles(filepath.Join(utils.ProjectDir(wd), sessionDir, id))
+		if err != nil {
+			return nil, err
+		}
+		for file, t := range files {
+			var messages []agentTypes.Message
+			if err := readJSON(file, &messages); err != nil {
+				// * a broken log does not hide the others
+				continue
+			}
+			logs = append(logs, ToolLog{Time: t, WorkDir: wd, Messages: messages})
+		}
+	}
+	sortToolLogs(logs)
+	return logs, nil
+}
+
+func (s *jsonStore) AppendToolRun(id, workDir string, log ToolLog) error {
+	t := log.Time.Local()
+	dir := filepath.Join(utils.ProjectDir(workDir), sessionDir, id, t.Format(toolDirLayout))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	return writeJSON(filepath.Join(dir, t.Format(toolFileLayout)+".json"), log.Messages)
+}
+
+func (s *jsonStore) RemoveToolRuns(id string, workDirs []string, from time.Time) error {
+	for _, wd := range workDirs {
+		files, err := toolRunFiles(filepath.Join(utils.ProjectDir(wd), sessionDir, id))
+		if err != nil {
+			return err
+		}
+		for file, t := range files {
+			if t.Before(from) {
+				continue
+			}
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("os.Remove: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *jsonStore) Usage(id string) ([]UsageLog, error) {
+	var logs []UsageLog
+	if err := readJSON(filepath.Join(s.path(id), usageFile), &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (s *jsonStore) AppendUsage(id string, log UsageLog) error {
+	logs, err := s.Usage(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(s.path(id), usageFile), append(logs, log))
+}
+
+// * {date}/{date-time}.json under dir, keyed by path
+func toolRunFiles(dir string) (map[string]time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("filepath.Glob: %w", err)
+	}
+	runs := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		t, err := time.ParseInLocation(toolFileLayout, strings.TrimSuffix(filepath.Base(file), ".json"), time.Local)
+		if err != nil {
+			continue
+		}
+		runs[file] = t
+	}
+	return runs, nil
+}
+
+// * a missing file leaves v as is
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("json.Unmarshal %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
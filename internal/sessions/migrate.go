@@ -0,0 +1,111 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * copies every json session into sessions.db and switches session_store to sqlite;
+// * sessions already in the database are skipped, the json files are left in place
+func Migrate(root *utils.ConfigDirData) ([]string, error) {
+	src := newJSONStore(root.Home)
+	dst, err := openSQLite(filepath.Join(root.Home, dbFile))
+	if err != nil {
+		return nil, err
+	}
+
+	metas, err := src.List()
+	if err != nil {
+		return nil, fmt.Errorf("src.List: %w", err)
+	}
+	bindings, err := readBindings(root)
+	if err != nil {
+		return nil, fmt.Errorf("readBindings: %w", err)
+	}
+
+	var migrated []string
+	for _, meta := range metas {
+		if dst.Exists(meta.ID) {
+			continue
+		}
+		var workDirs []string
+		for dir, id := range bindings {
+			if id == meta.ID {
+				workDirs = append(workDirs, dir)
+			}
+		}
+		if err := copySession(src, dst, meta, workDirs); err != nil {
+			// * no half migrated session, a rerun picks it up again
+			dst.Delete(meta.ID, nil)
+			return migrated, fmt.Errorf("copySession %s: %w", meta.ID, err)
+		}
+		migrated = append(migrated, meta.ID)
+	}
+
+	err = updateConfig(root, func(raw map[string]json.RawMessage) (bool, error) {
+		raw[storeKey] = json.RawMessage(`"` + BackendSQLite + `"`)
+		return true, nil
+	})
+	if err != nil {
+		return migrated, fmt.Errorf("updateConfig: %w", err)
+	}
+	return migrated, nil
+}
+
+func copySession(src Store, dst *sqliteStore, meta Meta, workDirs []string) error {
+	if err := dst.Create(meta.ID); err != nil {
+		return err
+	}
+
+	history, err := src.History(meta.ID)
+	if err != nil {
+		return fmt.Errorf("src.History: %w", err)
+	}
+	if err := dst.AppendHistory(meta.ID, history...); err != nil {
+		return fmt.Errorf("dst.AppendHistory: %w", err)
+	}
+
+	summary, err := src.Summary(meta.ID)
+	if err != nil {
+		return fmt.Errorf("src.Summary: %w", err)
+	}
+	if summary != nil {
+		if err := dst.SetSummary(meta.ID, summary); err != nil {
+			return fmt.Errorf("dst.SetSummary: %w", err)
+		}
+	}
+
+	snapshots, err := src.Snapshots(meta.ID)
+	if err != nil {
+		return fmt.Errorf("src.Snapshots: %w", err)
+	}
+	for _, snapshot := range snapshots {
+		if err := dst.AppendSnapshot(meta.ID, snapshot); err != nil {
+			return fmt.Errorf("dst.AppendSnapshot: %w", err)
+		}
+	}
+
+	logs, err := src.ToolRuns(meta.ID, workDirs)
+	if err != nil {
+		return fmt.Errorf("src.ToolRuns: %w", err)
+	}
+	for _, log := range logs {
+		if err := dst.AppendToolRun(meta.ID, log.WorkDir, log); err != nil {
+			return fmt.Errorf("dst.AppendToolRun: %w", err)
+		}
+	}
+
+	usage, err := src.Usage(meta.ID)
+	if err != nil {
+		return fmt.Errorf("src.Usage: %w", err)
+	}
+	for _, log := range usage {
+		if err := dst.AppendUsage(meta.ID, log); err != nil {
+			return fmt.Errorf("dst.AppendUsage: %w", err)
+		}
+	}
+	return dst.setUpdatedAt(meta.ID, meta.UpdatedAt)
+}
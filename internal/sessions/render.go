@@ -0,0 +1,302 @@
+package sessions
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed template/session.html
+var htmlTemplate string
+
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+
+	// * json embedded in md / html exports so they can be imported back
+	embedMarker = "agenvoy-session"
+	timeLayout  = "2006-01-02 15:04:05"
+)
+
+var Formats = []string{FormatMarkdown, FormatJSON, FormatHTML}
+
+// * known summary fields first, in the order the summary prompt lists them
+var summaryOrder = []string{
+	"core_discussion", "confirmed_needs", "constraints", "excluded_options",
+	"key_data", "current_conclusion", "pending_questions", "discussion_log",
+}
+
+var sessionTemplate = template.Must(template.New("session").Funcs(template.FuncMap{
+	"date": formatTime,
+	"role": roleLabel,
+}).Parse(htmlTemplate))
+
+type summarySection struct {
+	Title string
+	Text  string
+	Items []string
+}
+
+// * importable embeds the export json in md and html, so Parse can read it back
+func Render(w io.Writer, e *Export, format string, importable bool) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("enc.Encode: %w", err)
+		}
+		return nil
+
+	case FormatMarkdown:
+		var b strings.Builder
+		writeMarkdown(&b, e)
+		if importable {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("json.Marshal: %w", err)
+			}
+			// * json.Marshal escapes > so the comment cannot be closed early
+			fmt.Fprintf(&b, "\n<!-- %s %s -->\n", embedMarker, data)
+		}
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return fmt.Errorf("io.WriteString: %w", err)
+		}
+		return nil
+
+	case FormatHTML:
+		view := struct {
+			*Export
+			Sections []summarySection
+			Marker   string
+			Data     template.JS
+		}{Export: e, Sections: summarySections(e.Summary), Marker: embedMarker}
+		if importable {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("json.Marshal: %w", err)
+			}
+			view.Data = template.JS(data)
+		}
+		if err := sessionTemplate.Execute(w, view); err != nil {
+			return fmt.Errorf("sessionTemplate.Execute: %w", err)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// * json exports, or md / html exports written with importable
+func Parse(data []byte) (*Export, error) {
+	text := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(text, "{") {
+		embedded, ok := findEmbedded(text)
+		if !ok {
+			return nil, fmt.Errorf("no %s data found, export as json, or md / html with agenvoy session export", embedMarker)
+		}
+		text = embedded
+	}
+
+	var e Export
+	if err := json.Unmarshal([]byte(text), &e); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if e.Version == 0 || e.Version > exportVersion {
+		return nil, fmt.Errorf("unsupported export version: %d", e.Version)
+	}
+	if !Valid(e.ID) {
+		return nil, fmt.Errorf("invalid session id: %s", e.ID)
+	}
+	return &e, nil
+}
+
+func findEmbedded(text string) (string, bool) {
+	// * markdown comment
+	if _, rest, ok := strings.Cut(text, "<!-- "+embedMarker+" "); ok {
+		if data, _, ok := strings.Cut(rest, " -->"); ok {
+			return data, true
+		}
+	}
+	// * html script tag
+	if _, rest, ok := strings.Cut(text, `id="`+embedMarker+`">`); ok {
+		if data, _, ok := strings.Cut(rest, "</script>"); ok {
+			return strings.TrimSpace(data), true
+		}
+	}
+	return "", false
+}
+
+func writeMarkdown(b *strings.Builder, e *Export) {
+	fmt.Fprintf(b, "# Session %s\n\n", e.ID)
+	fmt.Fprintf(b, "Exported %s\n", formatTime(e.ExportedAt))
+
+	if sections := summarySections(e.Summary); len(sections) > 0 {
+		b.WriteString("\n## Summary\n")
+		for _, s := range sections {
+			fmt.Fprintf(b, "\n### %s\n\n", s.Title)
+			if s.Text != "" {
+				b.WriteString(s.Text + "\n")
+			}
+			for _, item := range s.Items {
+				fmt.Fprintf(b, "- %s\n", item)
+			}
+		}
+	}
+
+	b.WriteString("\n## Conversation\n")
+	// * [index] is what fork and rewind take
+	for i, turn := range e.History {
+		fmt.Fprintf(b, "\n### [%d] %s", i, roleLabel(turn.Role))
+		if date := formatTime(turn.Time); date != "" {
+			fmt.Fprintf(b, " · %s", date)
+		}
+		fmt.Fprintf(b, "\n\n%s\n", strings.TrimSpace(turn.Content))
+	}
+
+	if len(e.ToolRuns) > 0 {
+		b.WriteString("\n## Tool Calls\n")
+		for _, run := range e.ToolRuns {
+			fmt.Fprintf(b, "\n### %s\n", formatTime(run.Time))
+			for _, call := range run.Calls {
+				name := call.Name
+				if name == "" {
+					name = call.ID
+				}
+				fmt.Fprintf(b, "\n#### `%s`\n", name)
+				if call.Args != "" {
+					writeFenced(b, "json", call.Args)
+				}
+				writeFenced(b, "", call.Result)
+			}
+		}
+	}
+}
+
+// * the fence is longer than any backtick run in text
+func writeFenced(b *strings.Builder, lang, text string) {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(b, "\n%s%s\n%s\n%s\n", fence, lang, strings.TrimRight(text, "\n"), fence)
+}
+
+func summarySections(summary map[string]any) []summarySection {
+	keys := make([]string, 0, len(summary))
+	for _, key := range summaryOrder {
+		if _, ok := summary[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	var rest []string
+	for key := range summary {
+		if !slices.Contains(summaryOrder, key) {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	sections := make([]summarySection, 0, len(keys))
+	for _, key := range keys {
+		section := summarySection{Title: titleCase(key)}
+		switch v := summary[key].(type) {
+		case nil:
+			continue
+		case []any:
+			if len(v) == 0 {
+				continue
+			}
+			for _, item := range v {
+				section.Items = append(section.Items, formatValue(item))
+			}
+		case map[string]any:
+			for _, k := range sortedKeys(v) {
+				section.Items = append(section.Items, fmt.Sprintf("%s: %s", k, formatValue(v[k])))
+			}
+		default:
+			section.Text = formatValue(v)
+			if section.Text == "" {
+				continue
+			}
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// * discussion_log entries read as "{time} {topic}: {conclusion}"
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if topic, ok := v["topic"].(string); ok {
+			text := topic
+			if t, ok := v["time"].(string); ok && t != "" {
+				text = t + " " + text
+			}
+			if c, ok := v["conclusion"]; ok {
+				text += ": " + formatValue(c)
+			}
+			return text
+		}
+		parts := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			parts = append(parts, fmt.Sprintf("%s: %s", k, formatValue(v[k])))
+		}
+		return strings.Join(parts, "; ")
+	case nil:
+		return ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// * core_discussion -> Core Discussion
+func titleCase(key string) string {
+	words := strings.Fields(strings.ReplaceAll(key, "_", " "))
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+func roleLabel(role string) string {
+	if role == "" {
+		return ""
+	}
+	return titleCase(role)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(timeLayout)
+}
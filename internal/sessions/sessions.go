@@ -0,0 +1,348 @@
+package sessions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * session data lives in the Store picked by Open, config.json maps each work dir to its session:
+// * {"sessions": {"/path/to/repo": "repo-1a2b3c"}}
+const (
+	configFile = "config.json"
+	sessionDir = "sessions"
+	bindingKey = "sessions"
+)
+
+var idRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type Info struct {
+	ID        string    `json:"id"`
+	WorkDirs  []string  `json:"work_dirs,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Current   bool      `json:"current,omitempty"`
+}
+
+func Valid(id string) bool {
+	return idRegex.MatchString(id)
+}
+
+// * session bound to workDir, a default named after the folder is bound on first use
+func Current(root *utils.ConfigDirData, workDir string) (string, error) {
+	workDir, err := absDir(workDir)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	err = updateBindings(root, func(bindings map[string]string) (bool, error) {
+		if bound, ok := bindings[workDir]; ok && Valid(bound) {
+			id = bound
+			return false, nil
+		}
+		id = defaultID(workDir)
+		bindings[workDir] = id
+		return true, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("updateBindings: %w", err)
+	}
+	return id, nil
+}
+
+// * {folder}-{6 hex of the path}, so two repos with the same folder name stay apart
+func defaultID(workDir string) string {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '-'
+	}, filepath.Base(workDir))
+	base = strings.Trim(base, "-")
+	if len(base) > 48 {
+		base = base[:48]
+	}
+	if base == "" {
+		base = "session"
+	}
+
+	sum := sha256.Sum256([]byte(workDir))
+	return base + "-" + hex.EncodeToString(sum[:])[:6]
+}
+
+func Create(root *utils.ConfigDirData, id string) error {
+	if !Valid(id) {
+		return fmt.Errorf("invalid session id: %s", id)
+	}
+	store, err := Open(root)
+	if err != nil {
+		return fmt.Errorf("Open: %w", err)
+	}
+	return store.Create(id)
+}
+
+// * bind workDir to an existing session
+func Switch(root *utils.ConfigDirData, workDir, id string) error {
+	if !exists(root, id) {
+		return fmt.Errorf("session not found: %s", id)
+	}
+
+	workDir, err := absDir(workDir)
+	if err != nil {
+		return err
+	}
+
+	err = updateBindings(root, func(bindings map[string]string) (bool, error) {
+		bindings[workDir] = id
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("updateBindings: %w", err)
+	}
+	return nil
+}
+
+// * newest first, Current marks the session bound to workDir
+func List(root *utils.ConfigDirData, workDir string) ([]Info, error) {
+	store, err := Open(root)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+	metas, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("store.List: %w", err)
+	}
+
+	bindings, err := readBindings(root)
+	if err != nil {
+		return nil, fmt.Errorf("readBindings: %w", err)
+	}
+	boundTo := make(map[string][]string)
+	for dir, id := range bindings {
+		boundTo[id] = append(boundTo[id], dir)
+	}
+
+	current := ""
+	if workDir != "" {
+		if abs, err := absDir(workDir); err == nil {
+			current = bindings[abs]
+		}
+	}
+
+	list := make([]Info, 0, len(metas))
+	for _, meta := range metas {
+		dirs := boundTo[meta.ID]
+		sort.Strings(dirs)
+		list = append(list, Info{
+			ID:        meta.ID,
+			WorkDirs:  dirs,
+			UpdatedAt: meta.UpdatedAt,
+			Current:   meta.ID == current,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UpdatedAt.After(list[j].UpdatedAt)
+	})
+	return list, nil
+}
+
+// * moves the session data and every binding to newID
+func Rename(root *utils.ConfigDirData, oldID, newID string) error {
+	if !Valid(newID) {
+		return fmt.Errorf("invalid session id: %s", newID)
+	}
+	store, err := Open(root)
+	if err != nil {
+		return fmt.Errorf("Open: %w", err)
+	}
+	if !Valid(oldID) || !store.Exists(oldID) {
+		return fmt.Errorf("session not found: %s", oldID)
+	}
+	if store.Exists(newID) {
+		return fmt.Errorf("session already exists: %s", newID)
+	}
+
+	err = updateBindings(root, func(bindings map[string]string) (bool, error) {
+		var workDirs []string
+		for dir, id := range bindings {
+			if id == oldID {
+				bindings[dir] = newID
+				workDirs = append(workDirs, dir)
+			}
+		}
+		if err := store.Rename(oldID, newID, workDirs); err != nil {
+			return false, fmt.Errorf("store.Rename: %w", err)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("updateBindings: %w", err)
+	}
+	return nil
+}
+
+// * removes the session data and unbinds it, the next run in those work dirs starts a default session
+func Delete(root *utils.ConfigDirData, id string) error {
+	store, err := Open(root)
+	if err != nil {
+		return fmt.Errorf("Open: %w", err)
+	}
+	if !Valid(id) || !store.Exists(id) {
+		return fmt.Errorf("session not found: %s", id)
+	}
+
+	err = updateBindings(root, func(bindings map[string]string) (bool, error) {
+		var workDirs []string
+		for dir, bound := range bindings {
+			if bound == id {
+				delete(bindings, dir)
+				workDirs = append(workDirs, dir)
+			}
+		}
+		if err := store.Delete(id, workDirs); err != nil {
+			return false, fmt.Errorf("store.Delete: %w", err)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("updateBindings: %w", err)
+	}
+	return nil
+}
+
+func exists(root *utils.ConfigDirData, id string) bool {
+	if !Valid(id) {
+		return false
+	}
+	store, err := Open(root)
+	return err == nil && store.Exists(id)
+}
+
+func absDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("filepath.Abs: %w", err)
+	}
+	return abs, nil
+}
+
+func readBindings(root *utils.ConfigDirData) (map[string]string, error) {
+	unlock, err := lockConfig(root.Home)
+	if err != nil {
+		return nil, fmt.Errorf("lockConfig: %w", err)
+	}
+	defer unlock()
+
+	raw, err := readConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBindings(raw), nil
+}
+
+// * fn runs under the config lock, other keys of config.json are kept as is
+func updateBindings(root *utils.ConfigDirData, fn func(bindings map[string]string) (bool, error)) error {
+	return updateConfig(root, func(raw map[string]json.RawMessage) (bool, error) {
+		bindings := decodeBindings(raw)
+		changed, err := fn(bindings)
+		if err != nil || !changed {
+			return false, err
+		}
+		raw[bindingKey], err = json.Marshal(bindings)
+		if err != nil {
+			return false, fmt.Errorf("json.Marshal: %w", err)
+		}
+		return true, nil
+	})
+}
+
+func updateConfig(root *utils.ConfigDirData, fn func(raw map[string]json.RawMessage) (bool, error)) error {
+	unlock, err := lockConfig(root.Home)
+	if err != nil {
+		return fmt.Errorf("lockConfig: %w", err)
+	}
+	defer unlock()
+
+	raw, err := readConfig(root)
+	if err != nil {
+		return err
+	}
+
+	changed, err := fn(raw)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(root.Home, configFile), data, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+func readConfig(root *utils.ConfigDirData) (map[string]json.RawMessage, error) {
+	raw := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(filepath.Join(root.Home, configFile))
+	switch {
+	case os.IsNotExist(err):
+		return raw, nil
+	case err != nil:
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if raw == nil {
+		raw = make(map[string]json.RawMessage)
+	}
+	return raw, nil
+}
+
+func decodeBindings(raw map[string]json.RawMessage) map[string]string {
+	bindings := make(map[string]string)
+	if data, ok := raw[bindingKey]; ok {
+		if err := json.Unmarshal(data, &bindings); err != nil || bindings == nil {
+			bindings = make(map[string]string)
+		}
+	}
+	return bindings
+}
+
+func lockConfig(dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	lockPath := filepath.Join(dir, configFile+".lock")
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("syscall.Flock: %w", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
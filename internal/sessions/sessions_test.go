@@ -0,0 +1,130 @@
+package sessions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+func newRoot(t *testing.T) (*utils.ConfigDirData, string) {
+	t.Helper()
+	base := t.TempDir()
+	root, err := utils.NewConfigDir(filepath.Join(base, "home"), filepath.Join(base, "work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, base
+}
+
+func TestCurrent_BoundPerWorkDir(t *testing.T) {
+	root, base := newRoot(t)
+
+	// * other keys of config.json must survive
+	if err := os.WriteFile(filepath.Join(root.Home, configFile), []byte(`{"models":[{"name":"x"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repoA := filepath.Join(base, "a", "repo")
+	repoB := filepath.Join(base, "b", "repo")
+
+	idA, err := Current(root, repoA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idB, err := Current(root, repoB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idA == idB {
+		t.Fatalf("two work dirs share session %s", idA)
+	}
+	if !strings.HasPrefix(idA, "repo-") || !Valid(idA) {
+		t.Errorf("default id = %q", idA)
+	}
+
+	again, err := Current(root, repoA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != idA {
+		t.Errorf("Current changed from %s to %s", idA, again)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root.Home, configFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["models"]; !ok {
+		t.Errorf("config.json lost models: %s", data)
+	}
+}
+
+func TestSessions_Lifecycle(t *testing.T) {
+	root, base := newRoot(t)
+	repo := filepath.Join(base, "repo")
+	other := filepath.Join(base, "other")
+
+	if err := Create(root, "bad name"); err == nil {
+		t.Error("Create accepted an invalid id")
+	}
+	if err := Create(root, "work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(root, "work"); err == nil {
+		t.Error("Create accepted a duplicate id")
+	}
+	if err := Switch(root, repo, "missing"); err == nil {
+		t.Error("Switch accepted a missing session")
+	}
+	if err := Switch(root, repo, "work"); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := Current(root, repo); id != "work" {
+		t.Errorf("Current = %q, want work", id)
+	}
+	if _, err := Current(root, other); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := List(root, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found *Info
+	for i := range list {
+		if list[i].ID == "work" {
+			found = &list[i]
+		}
+	}
+	if found == nil || !found.Current || len(found.WorkDirs) != 1 || found.WorkDirs[0] != repo {
+		t.Errorf("list = %+v", list)
+	}
+
+	if err := Rename(root, "work", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := Current(root, repo); id != "renamed" {
+		t.Errorf("binding after rename = %q", id)
+	}
+	if _, err := os.Stat(filepath.Join(root.Home, sessionDir, "renamed")); err != nil {
+		t.Errorf("renamed folder: %v", err)
+	}
+
+	if err := Delete(root, "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(root, "renamed") {
+		t.Error("session folder still exists")
+	}
+	if id, _ := Current(root, repo); id == "renamed" || id != defaultID(repo) {
+		t.Errorf("Current after delete = %q", id)
+	}
+}
@@ -0,0 +1,429 @@
+package sessions
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	_ "modernc.org/sqlite"
+)
+
+// * one entry per schema version, PRAGMA user_version records how many have run
+var schema = []string{
+	`CREATE TABLE sessions (
+		id         TEXT PRIMARY KEY,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		summary    TEXT
+	);
+	CREATE TABLE messages (
+		id         INTEGER PRIMARY KEY,
+		session_id TEXT NOT NULL REFERENCES sessions(id) ON UPDATE CASCADE ON DELETE CASCADE,
+		seq        INTEGER NOT NULL,
+		role       TEXT NOT NULL,
+		body       TEXT NOT NULL,
+		ts         INTEGER NOT NULL DEFAULT 0,
+		data       TEXT NOT NULL,
+		UNIQUE (session_id, seq)
+	);
+	CREATE VIRTUAL TABLE messages_fts USING fts5(body, content='messages', content_rowid='id', tokenize='trigram');
+	CREATE TRIGGER messages_ai AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (rowid, body) VALUES (new.id, new.body);
+	END;
+	CREATE TRIGGER messages_ad AFTER DELETE ON messages BEGIN
+		INSERT INTO messages_fts (messages_fts, rowid, body) VALUES ('delete', old.id, old.body);
+	END;
+	CREATE TABLE snapshots (
+		id         INTEGER PRIMARY KEY,
+		session_id TEXT NOT NULL REFERENCES sessions(id) ON UPDATE CASCADE ON DELETE CASCADE,
+		turns      INTEGER NOT NULL,
+		summary    TEXT NOT NULL
+	);
+	CREATE TABLE tool_runs (
+		id         INTEGER PRIMARY KEY,
+		session_id TEXT NOT NULL REFERENCES sessions(id) ON UPDATE CASCADE ON DELETE CASCADE,
+		work_dir   TEXT NOT NULL,
+		ts         INTEGER NOT NULL,
+		data       TEXT NOT NULL
+	);
+	CREATE INDEX tool_runs_session ON tool_runs (session_id, ts);`,
+	`CREATE TABLE usage (
+		id                 INTEGER PRIMARY KEY,
+		session_id         TEXT NOT NULL REFERENCES sessions(id) ON UPDATE CASCADE ON DELETE CASCADE,
+		ts                 INTEGER NOT NULL,
+		model              TEXT NOT NULL,
+		input_tokens       INTEGER NOT NULL DEFAULT 0,
+		output_tokens      INTEGER NOT NULL DEFAULT 0,
+		cache_read_tokens  INTEGER NOT NULL DEFAULT 0,
+		cache_write_tokens INTEGER NOT NULL DEFAULT 0,
+		cost               REAL NOT NULL DEFAULT 0
+	);
+	CREATE INDEX usage_session ON usage (session_id, ts);`,
+}
+
+// * rows are only appended or deleted, a turn never rewrites the history before it
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+	if err := migrateSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func migrateSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("db.QueryRow: %w", err)
+	}
+	for ; version < len(schema); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("db.Begin: %w", err)
+		}
+		if _, err := tx.Exec(schema[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("schema v%d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("tx.Commit: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Exists(id string) bool {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE id = ?", id).Scan(&n)
+	return err == nil && n > 0
+}
+
+func (s *sqliteStore) Create(id string) error {
+	now := time.Now().UnixMilli()
+	res, err := s.db.Exec("INSERT INTO sessions (id, created_at, updated_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", id, now, now)
+	if err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("session already exists: %s", id)
+	}
+	return nil
+}
+
+func (s *sqliteStore) List() ([]Meta, error) {
+	rows, err := s.db.Query("SELECT id, updated_at FROM sessions")
+	if err != nil {
+		return nil, fmt.Errorf("db.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Meta
+	for rows.Next() {
+		var meta Meta
+		var updated int64
+		if err := rows.Scan(&meta.ID, &updated); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		meta.UpdatedAt = time.UnixMilli(updated)
+		list = append(list, meta)
+	}
+	return list, rows.Err()
+}
+
+// * tool runs belong to the session row, workDirs are not needed
+func (s *sqliteStore) Rename(oldID, newID string, _ []string) error {
+	if _, err := s.db.Exec("UPDATE sessions SET id = ? WHERE id = ?", newID, oldID); err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Delete(id string, _ []string) error {
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE id = ?", id); err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) History(id string) ([]agentTypes.Message, error) {
+	rows, err := s.db.Query("SELECT data FROM messages WHERE session_id = ? ORDER BY seq", id)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query: %w", err)
+	}
+	return scanMessages(rows)
+}
+
+func (s *sqliteStore) AppendHistory(id string, messages ...agentTypes.Message) error {
+	return s.update(id, func(tx *sql.Tx) error {
+		var seq int
+		if err := tx.QueryRow("SELECT COALESCE(MAX(seq) + 1, 0) FROM messages WHERE session_id = ?", id).Scan(&seq); err != nil {
+			return fmt.Errorf("tx.QueryRow: %w", err)
+		}
+		for i, m := range messages {
+			data, err := json.Marshal(m)
+			if err != nil {
+				return fmt.Errorf("json.Marshal: %w", err)
+			}
+			t, body := splitTS(messageText(m.Content))
+			var ts int64
+			if !t.IsZero() {
+				ts = t.Unix()
+			}
+			if _, err := tx.Exec("INSERT INTO messages (session_id, seq, role, body, ts, data) VALUES (?, ?, ?, ?, ?, ?)",
+				id, seq+i, m.Role, body, ts, string(data)); err != nil {
+				return fmt.Errorf("tx.Exec: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) TruncateHistory(id string, n int) error {
+	return s.update(id, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM messages WHERE session_id = ? AND seq >= ?", id, n); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) Summary(id string) ([]byte, error) {
+	var summary []byte
+	err := s.db.QueryRow("SELECT summary FROM sessions WHERE id = ?", id).Scan(&summary)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("db.QueryRow: %w", err)
+	}
+	return summary, nil
+}
+
+func (s *sqliteStore) SetSummary(id string, summary []byte) error {
+	return s.update(id, func(tx *sql.Tx) error {
+		var value any
+		if summary != nil {
+			value = string(summary)
+		}
+		if _, err := tx.Exec("UPDATE sessions SET summary = ? WHERE id = ?", value, id); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) Snapshots(id string) ([]Snapshot, error) {
+	rows, err := s.db.Query("SELECT turns, summary FROM snapshots WHERE session_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		var summary []byte
+		if err := rows.Scan(&snapshot.Turns, &summary); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		snapshot.Summary = summary
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+func (s *sqliteStore) AppendSnapshot(id string, snapshot Snapshot) error {
+	return s.update(id, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT INTO snapshots (session_id, turns, summary) VALUES (?, ?, ?)",
+			id, snapshot.Turns, string(snapshot.Summary)); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) TruncateSnapshots(id string, turns int) error {
+	if _, err := s.db.Exec("DELETE FROM snapshots WHERE session_id = ? AND turns > ?", id, turns); err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	return nil
+}
+
+// * every run of the session, whichever work dir it happened in
+func (s *sqliteStore) ToolRuns(id string, _ []string) ([]ToolLog, error) {
+	rows, err := s.db.Query("SELECT ts, work_dir, data FROM tool_runs WHERE session_id = ? ORDER BY ts, id", id)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []ToolLog
+	for rows.Next() {
+		var ts int64
+		var log ToolLog
+		var data []byte
+		if err := rows.Scan(&ts, &log.WorkDir, &data); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		if err := json.Unmarshal(data, &log.Messages); err != nil {
+			continue
+		}
+		log.Time = time.Unix(ts, 0)
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
+
+func (s *sqliteStore) AppendToolRun(id, workDir string, log ToolLog) error {
+	data, err := json.Marshal(log.Messages)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	return s.update(id, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT INTO tool_runs (session_id, work_dir, ts, data) VALUES (?, ?, ?, ?)",
+			id, workDir, log.Time.Unix(), string(data)); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) RemoveToolRuns(id string, _ []string, from time.Time) error {
+	if _, err := s.db.Exec("DELETE FROM tool_runs WHERE session_id = ? AND ts >= ?", id, from.Unix()); err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	return nil
+}
+
+// * one row per model of a run, rows of the same ts are folded back into one log
+func (s *sqliteStore) Usage(id string) ([]UsageLog, error) {
+	rows, err := s.db.Query(`SELECT ts, model, input_tokens, output_tokens, cache_read_tokens, cache_write_tokens, cost
+		FROM usage WHERE session_id = ? ORDER BY ts, id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []UsageLog
+	for rows.Next() {
+		var ts int64
+		var m agentTypes.ModelUsage
+		if err := rows.Scan(&ts, &m.Model, &m.Usage.InputTokens, &m.Usage.OutputTokens,
+			&m.Usage.CacheReadTokens, &m.Usage.CacheWriteTokens, &m.Cost); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		t := time.UnixMilli(ts)
+		if n := len(logs); n > 0 && logs[n-1].Time.Equal(t) {
+			logs[n-1].Models = append(logs[n-1].Models, m)
+			continue
+		}
+		logs = append(logs, UsageLog{Time: t, Models: []agentTypes.ModelUsage{m}})
+	}
+	return logs, rows.Err()
+}
+
+func (s *sqliteStore) AppendUsage(id string, log UsageLog) error {
+	return s.update(id, func(tx *sql.Tx) error {
+		for _, m := range log.Models {
+			if _, err := tx.Exec(`INSERT INTO usage (session_id, ts, model, input_tokens, output_tokens, cache_read_tokens, cache_write_tokens, cost)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				id, log.Time.UnixMilli(), m.Model, m.Usage.InputTokens, m.Usage.OutputTokens,
+				m.Usage.CacheReadTokens, m.Usage.CacheWriteTokens, m.Cost); err != nil {
+				return fmt.Errorf("tx.Exec: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// * trigram fts for keywords of 3+ characters, shorter ones cannot hit the index and use LIKE
+func (s *sqliteStore) SearchHistory(id, keyword string, after time.Time, skip, limit int) ([]agentTypes.Message, error) {
+	var since int64
+	if !after.IsZero() {
+		since = after.Unix()
+	}
+
+	match, arg := "messages_fts MATCH ?", `"`+strings.ReplaceAll(keyword, `"`, `""`)+`"`
+	from := "messages m JOIN messages_fts ON messages_fts.rowid = m.id"
+	if utf8.RuneCountInString(keyword) < 3 {
+		match, arg = `m.body LIKE ? ESCAPE '\'`, "%"+strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(keyword)+"%"
+		from = "messages m"
+	}
+
+	rows, err := s.db.Query(`SELECT m.data FROM `+from+`
+		WHERE `+match+` AND m.session_id = ?
+			AND m.seq < (SELECT COUNT(*) FROM messages WHERE session_id = ?) - ?
+			AND (? = 0 OR m.ts = 0 OR m.ts >= ?)
+		ORDER BY m.seq DESC LIMIT ?`,
+		arg, id, id, skip, since, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query: %w", err)
+	}
+	return scanMessages(rows)
+}
+
+// * fn runs in a transaction that also bumps updated_at
+func (s *sqliteStore) update(id string, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("db.Begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	res, err := tx.Exec("UPDATE sessions SET updated_at = ? WHERE id = ?", time.Now().UnixMilli(), id)
+	if err != nil {
+		return fmt.Errorf("tx.Exec: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("session not found: %s", id)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
+
+// * migrated sessions keep the time they were last used
+func (s *sqliteStore) setUpdatedAt(id string, t time.Time) error {
+	if _, err := s.db.Exec("UPDATE sessions SET updated_at = ? WHERE id = ?", t.UnixMilli(), id); err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	return nil
+}
+
+func scanMessages(rows *sql.Rows) ([]agentTypes.Message, error) {
+	defer rows.Close()
+
+	var messages []agentTypes.Message
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		var m agentTypes.Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,149 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+// * "session_store" in config.json picks the backend, json is the default
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+
+	storeKey = "session_store"
+	dbFile   = "sessions.db"
+)
+
+// * session data only, work dir bindings stay in config.json whatever the backend
+type Store interface {
+	Exists(id string) bool
+	Create(id string) error
+	List() ([]Meta, error)
+	// * workDirs are the bound dirs, the json backend keeps tool runs under each of them
+	Rename(oldID, newID string, workDirs []string) error
+	Delete(id string, workDirs []string) error
+
+	History(id string) ([]agentTypes.Message, error)
+	AppendHistory(id string, messages ...agentTypes.Message) error
+	TruncateHistory(id string, n int) error
+
+	Summary(id string) ([]byte, error) // * nil when there is none
+	SetSummary(id string, summary []byte) error
+	Snapshots(id string) ([]Snapshot, error)
+	AppendSnapshot(id string, snapshot Snapshot) error
+	TruncateSnapshots(id string, turns int) error
+
+	ToolRuns(id string, workDirs []string) ([]ToolLog, error)
+	AppendToolRun(id, workDir string, log ToolLog) error
+	RemoveToolRuns(id string, workDirs []string, from time.Time) error
+
+	Usage(id string) ([]UsageLog, error)
+	AppendUsage(id string, log UsageLog) error
+}
+
+// * backends with an index for search_history, others fall back to a scan of history.json
+type Searcher interface {
+	// * newest first, the last skip entries are already in context; after zero means no time filter
+	SearchHistory(id, keyword string, after time.Time, skip, limit int) ([]agentTypes.Message, error)
+}
+
+type Meta struct {
+	ID        string
+	UpdatedAt time.Time
+}
+
+// * summary.json as it was after a turn, so fork and rewind can roll it back
+type Snapshot struct {
+	Turns   int             `json:"turns"` // * history length the summary covers
+	Summary json.RawMessage `json:"summary"`
+}
+
+// * session.Tools of one Execute
+type ToolLog struct {
+	Time     time.Time
+	WorkDir  string // * work dir the run happened in
+	Messages []agentTypes.Message
+}
+
+var (
+	dbMu sync.Mutex
+	dbs  = make(map[string]*sqliteStore)
+)
+
+// * sqlite stores are kept open per file for the life of the process
+func Open(root *utils.ConfigDirData) (Store, error) {
+	backend, err := Backend(root)
+	if err != nil {
+		return nil, err
+	}
+	if backend != BackendSQLite {
+		return newJSONStore(root.Home), nil
+	}
+	return openSQLite(filepath.Join(root.Home, dbFile))
+}
+
+func openSQLite(path string) (*sqliteStore, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if s, ok := dbs[path]; ok {
+		return s, nil
+	}
+	s, err := newSQLiteStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("newSQLiteStore: %w", err)
+	}
+	dbs[path] = s
+	return s, nil
+}
+
+// * closes the sqlite stores opened by Open
+func Close() {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	for path, s := range dbs {
+		s.db.Close()
+		delete(dbs, path)
+	}
+}
+
+func Backend(root *utils.ConfigDirData) (string, error) {
+	unlock, err := lockConfig(root.Home)
+	if err != nil {
+		return "", fmt.Errorf("lockConfig: %w", err)
+	}
+	defer unlock()
+
+	raw, err := readConfig(root)
+	if err != nil {
+		return "", err
+	}
+	var backend string
+	if data, ok := raw[storeKey]; ok {
+		if err := json.Unmarshal(data, &backend); err != nil {
+			return "", fmt.Errorf("json.Unmarshal %s: %w", storeKey, err)
+		}
+	}
+	switch backend {
+	case "", BackendJSON:
+		return BackendJSON, nil
+	case BackendSQLite:
+		return BackendSQLite, nil
+	default:
+		return "", fmt.Errorf("unknown %s: %s", storeKey, backend)
+	}
+}
+
+func sortToolLogs(logs []ToolLog) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Time.Before(logs[j].Time)
+	})
+}
@@ -0,0 +1,304 @@
+package sessions
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+)
+
+func newSQLite(t *testing.T) *sqliteStore {
+	t.Helper()
+	store, err := openSQLite(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Close)
+	return store
+}
+
+func message(role string, ts int64, content string) agentTypes.Message {
+	return agentTypes.Message{Role: role, Content: fmt.Sprintf("ts:%d\n%s", ts, content)}
+}
+
+func TestStores(t *testing.T) {
+	backends := map[string]func(t *testing.T) (Store, string){
+		BackendJSON: func(t *testing.T) (Store, string) {
+			root, base := newRoot(t)
+			return newJSONStore(root.Home), filepath.Join(base, "repo")
+		},
+		BackendSQLite: func(t *testing.T) (Store, string) {
+			return newSQLite(t), filepath.Join(t.TempDir(), "repo")
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			store, repo := open(t)
+
+			if err := store.Create("main"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Create("main"); err == nil {
+				t.Error("duplicate create accepted")
+			}
+			if !store.Exists("main") || store.Exists("other") {
+				t.Error("Exists is wrong")
+			}
+
+			if err := store.AppendHistory("main", message("user", 1760000000, "hi"), message("assistant", 1760000010, "hello")); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.AppendHistory("main", message("user", 1760000100, "again")); err != nil {
+				t.Fatal(err)
+			}
+			if history, err := store.History("main"); err != nil || len(history) != 3 || history[2].Content != "ts:1760000100\nagain" {
+				t.Fatalf("history = %+v, %v", history, err)
+			}
+			if err := store.TruncateHistory("main", 1); err != nil {
+				t.Fatal(err)
+			}
+			if history, _ := store.History("main"); len(history) != 1 {
+				t.Errorf("truncated history = %+v", history)
+			}
+
+			if summary, err := store.Summary("main"); err != nil || summary != nil {
+				t.Errorf("empty summary = %s, %v", summary, err)
+			}
+			if err := store.SetSummary("main", []byte(`{"core_discussion":"a"}`)); err != nil {
+				t.Fatal(err)
+			}
+			if err := RecordSummary(store, "main", 1); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.SetSummary("main", []byte(`{"core_discussion":"b"}`)); err != nil {
+				t.Fatal(err)
+			}
+			if err := RecordSummary(store, "main", 3); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.TruncateSnapshots("main", 2); err != nil {
+				t.Fatal(err)
+			}
+			if snapshots, _ := store.Snapshots("main"); len(snapshots) != 1 || string(snapshots[0].Summary) != `{"core_discussion":"a"}` {
+				t.Errorf("snapshots = %+v", snapshots)
+			}
+			if err := store.SetSummary("main", nil); err != nil {
+				t.Fatal(err)
+			}
+			if summary, _ := store.Summary("main"); summary != nil {
+				t.Errorf("summary not removed: %s", summary)
+			}
+
+			for _, ts := range []int64{1760000020, 1760000200} {
+				log := ToolLog{Time: time.Unix(ts, 0), WorkDir: repo, Messages: []agentTypes.Message{{Role: "tool", Content: "run", ToolCallID: "call"}}}
+				if err := store.AppendToolRun("main", repo, log); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.RemoveToolRuns("main", []string{repo}, time.Unix(1760000100, 0)); err != nil {
+				t.Fatal(err)
+			}
+			logs, err := store.ToolRuns("main", []string{repo})
+			if err != nil || len(logs) != 1 || logs[0].Time.Unix() != 1760000020 || logs[0].WorkDir != repo {
+				t.Fatalf("tool runs = %+v, %v", logs, err)
+			}
+
+			runs := []UsageLog{
+				{Time: time.UnixMilli(1760000020500), Models: []agentTypes.ModelUsage{
+					{Model: "copilot@gpt-4.1", Usage: agentTypes.Usage{InputTokens: 300, OutputTokens: 2}},
+					{Model: "claude@claude-sonnet-4-5", Usage: agentTypes.Usage{InputTokens: 1000, OutputTokens: 200, CacheReadTokens: 500}, Cost: 0.00615},
+				}},
+				{Time: time.UnixMilli(1760000200000), Models: []agentTypes.ModelUsage{
+					{Model: "claude@claude-sonnet-4-5", Usage: agentTypes.Usage{InputTokens: 2000, OutputTokens: 100}, Cost: 0.0075},
+				}},
+			}
+			for _, run := range runs {
+				if err := store.AppendUsage("main", run); err != nil {
+					t.Fatal(err)
+				}
+			}
+			usage, err := store.Usage("main")
+			if err != nil || len(usage) != 2 || len(usage[0].Models) != 2 || !usage[0].Time.Equal(runs[0].Time) || usage[0].Models[1] != runs[0].Models[1] {
+				t.Fatalf("usage = %+v, %v", usage, err)
+			}
+
+			if err := store.Rename("main", "renamed", []string{repo}); err != nil {
+				t.Fatal(err)
+			}
+			if usage, _ := store.Usage("renamed"); len(usage) != 2 {
+				t.Errorf("renamed usage = %+v", usage)
+			}
+			if history, _ := store.History("renamed"); len(history) != 1 {
+				t.Errorf("renamed history = %+v", history)
+			}
+			if logs, _ := store.ToolRuns("renamed", []string{repo}); len(logs) != 1 {
+				t.Errorf("renamed tool runs = %+v", logs)
+			}
+			if list, err := store.List(); err != nil || len(list) != 1 || list[0].ID != "renamed" {
+				t.Errorf("list = %+v, %v", list, err)
+			}
+
+			if err := store.Delete("renamed", []string{repo}); err != nil {
+				t.Fatal(err)
+			}
+			if store.Exists("renamed") {
+				t.Error("deleted session still exists")
+			}
+			if logs, _ := store.ToolRuns("renamed", []string{repo}); len(logs) != 0 {
+				t.Errorf("tool runs left after delete: %+v", logs)
+			}
+		})
+	}
+}
+
+func TestSQLiteSearchHistory(t *testing.T) {
+	store := newSQLite(t)
+	if err := store.Create("main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendHistory("main",
+		message("user", 1700000000, "old Deploy notes"),
+		message("user", 1760000000, "台積電股價多少"),
+		message("assistant", 1760000010, "deploy finished, 50% done"),
+		message("user", 1760000020, "filler"),
+		message("assistant", 1760000030, "filler"),
+		message("user", 1760000040, "filler"),
+		message("assistant", 1760000050, "deploy is in context"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keyword string
+		after   time.Time
+		want    []string
+	}{
+		// * newest first, the last 4 entries are skipped
+		{"DEPLOY", time.Time{}, []string{"deploy finished, 50% done", "old Deploy notes"}},
+		{"deploy", time.Unix(1750000000, 0), []string{"deploy finished, 50% done"}},
+		{"台積電", time.Time{}, []string{"台積電股價多少"}},
+		// * shorter than a trigram
+		{"股價", time.Time{}, []string{"台積電股價多少"}},
+		{"0%", time.Time{}, []string{"deploy finished, 50% done"}},
+		{"%", time.Time{}, []string{"deploy finished, 50% done"}},
+		{`"quoted"`, time.Time{}, nil},
+	}
+	for _, tt := range tests {
+		found, err := store.SearchHistory("main", tt.keyword, tt.after, 4, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.keyword, err)
+		}
+		var got []string
+		for _, m := range found {
+			_, body := splitTS(messageText(m.Content))
+			got = append(got, body)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s = %q, want %q", tt.keyword, got, tt.want)
+		}
+	}
+
+	// * rewound entries leave the index
+	if err := store.TruncateHistory("main", 1); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.SearchHistory("main", "台積電", time.Time{}, 0, 10); len(found) != 0 {
+		t.Errorf("truncated entry still found: %+v", found)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	root, repo := seedTurns(t)
+	t.Cleanup(Close)
+
+	run := UsageLog{Time: time.UnixMilli(1760000000000), Models: []agentTypes.ModelUsage{
+		{Model: "claude@claude-sonnet-4-5", Usage: agentTypes.Usage{InputTokens: 1000, OutputTokens: 100}, Cost: 0.0045},
+	}}
+	if err := newJSONStore(root.Home).AppendUsage("main", run); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := Migrate(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 1 || migrated[0] != "main" {
+		t.Errorf("migrated = %v", migrated)
+	}
+	if backend, _ := Backend(root); backend != BackendSQLite {
+		t.Errorf("backend = %s", backend)
+	}
+	if again, err := Migrate(root); err != nil || len(again) != 0 {
+		t.Errorf("second migrate = %v, %v", again, err)
+	}
+
+	store, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(Searcher); !ok {
+		t.Fatalf("store = %T", store)
+	}
+	list, err := List(root, repo)
+	if err != nil || len(list) != 1 || !list[0].Current {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+
+	reports, err := Usage(root, nil, time.Time{})
+	if err != nil || len(reports) != 1 || reports[0].Runs != 1 || reports[0].Models[0] != run.Models[0] {
+		t.Errorf("usage = %+v, %v", reports, err)
+	}
+
+	migratedExport, err := Load(root, repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migratedExport.History) != 4 || migratedExport.Summary["core_discussion"] != "second" || len(migratedExport.ToolRuns) != 2 {
+		t.Errorf("migrated = %+v", migratedExport)
+	}
+
+	// * fork and rewind work the same on the sqlite store
+	if err := Fork(root, "main", "retry", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rewind(root, "main", -2); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"retry", "main"} {
+		e, err := Load(root, repo, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(e.History) != 2 || e.Summary["core_discussion"] != "first" || len(e.ToolRuns) != 1 {
+			t.Errorf("%s = %+v", id, e)
+		}
+	}
+}
+
+func TestSumUsage(t *testing.T) {
+	logs := []UsageLog{
+		{Time: time.Unix(1760000100, 0), Models: []agentTypes.ModelUsage{
+			{Model: "copilot@gpt-4.1", Usage: agentTypes.Usage{InputTokens: 300, OutputTokens: 2}},
+			{Model: "claude@claude-sonnet-4-5", Usage: agentTypes.Usage{InputTokens: 1000, OutputTokens: 200}, Cost: 0.006},
+		}},
+		{Time: time.Unix(1760000000, 0), Models: []agentTypes.ModelUsage{
+			{Model: "claude@claude-sonnet-4-5", Usage: agentTypes.Usage{InputTokens: 2000, OutputTokens: 100, CacheReadTokens: 50}, Cost: 0.0075},
+		}},
+	}
+
+	report := SumUsage("main", logs)
+	if report.Runs != 2 || report.First.Unix() != 1760000000 || report.Last.Unix() != 1760000100 {
+		t.Errorf("report = %+v", report)
+	}
+	// * priced models first
+	if len(report.Models) != 2 || report.Models[0].Model != "claude@claude-sonnet-4-5" || report.Models[0].Usage.InputTokens != 3000 || report.Models[0].Usage.CacheReadTokens != 50 {
+		t.Fatalf("models = %+v", report.Models)
+	}
+	total, cost := report.Total()
+	if total.Total() != 3652 || cost < 0.01349 || cost > 0.01351 {
+		t.Errorf("total = %+v, %f", total, cost)
+	}
+}
@@ -0,0 +1,110 @@
+package sessions
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	agentTypes "github.com/pardnchiu/agenvoy/internal/agents/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+const usageFile = "usage.json"
+
+// * token usage of one run, cost in USD as priced when the run finished
+type UsageLog struct {
+	Time   time.Time               `json:"time"`
+	Models []agentTypes.ModelUsage `json:"models"`
+}
+
+// * usage of a session added up per model
+type UsageReport struct {
+	ID     string                  `json:"id"`
+	Runs   int                     `json:"runs"`
+	First  time.Time               `json:"first,omitempty"`
+	Last   time.Time               `json:"last,omitempty"`
+	Models []agentTypes.ModelUsage `json:"models"`
+}
+
+func (r UsageReport) Total() (agentTypes.Usage, float64) {
+	var total agentTypes.Usage
+	var cost float64
+	for _, m := range r.Models {
+		total.Add(&m.Usage)
+		cost += m.Cost
+	}
+	return total, cost
+}
+
+// * models sorted by cost, then tokens
+func SumUsage(id string, logs []UsageLog) UsageReport {
+	report := UsageReport{ID: id, Runs: len(logs)}
+	index := make(map[string]int)
+	for _, log := range logs {
+		if report.First.IsZero() || log.Time.Before(report.First) {
+			report.First = log.Time
+		}
+		if log.Time.After(report.Last) {
+			report.Last = log.Time
+		}
+		for _, m := range log.Models {
+			i, ok := index[m.Model]
+			if !ok {
+				i = len(report.Models)
+				index[m.Model] = i
+				report.Models = append(report.Models, agentTypes.ModelUsage{Model: m.Model})
+			}
+			report.Models[i].Usage.Add(&m.Usage)
+			report.Models[i].Cost += m.Cost
+		}
+	}
+	sort.SliceStable(report.Models, func(i, j int) bool {
+		a, b := report.Models[i], report.Models[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		return a.Usage.Total() > b.Usage.Total()
+	})
+	return report
+}
+
+// * empty ids means every session, runs before since are left out when since is set
+func Usage(root *utils.ConfigDirData, ids []string, since time.Time) ([]UsageReport, error) {
+	store, err := Open(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		metas, err := store.List()
+		if err != nil {
+			return nil, fmt.Errorf("store.List: %w", err)
+		}
+		for _, meta := range metas {
+			ids = append(ids, meta.ID)
+		}
+		sort.Strings(ids)
+	}
+
+	reports := make([]UsageReport, 0, len(ids))
+	for _, id := range ids {
+		if !store.Exists(id) {
+			return nil, fmt.Errorf("session not found: %s", id)
+		}
+		logs, err := store.Usage(id)
+		if err != nil {
+			return nil, fmt.Errorf("store.Usage: %w", err)
+		}
+		if !since.IsZero() {
+			kept := logs[:0]
+			for _, log := range logs {
+				if !log.Time.Before(since) {
+					kept = append(kept, log)
+				}
+			}
+			logs = kept
+		}
+		reports = append(reports, SumUsage(id, logs))
+	}
+	return reports, nil
+}
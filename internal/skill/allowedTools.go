@@ -0,0 +1,50 @@
+package skill
+
+import (
+	"path"
+	"strings"
+)
+
+// * Claude Code tool names in allowed-tools mapped to the built-in tools doing the same job
+var claudeTools = map[string][]string{
+	"Read":      {"read_file"},
+	"Write":     {"write_file"},
+	"Edit":      {"patch_edit"},
+	"MultiEdit": {"patch_edit"},
+	"Bash":      {"run_command"},
+	"Glob":      {"glob_files"},
+	"Grep":      {"search_content"},
+	"LS":        {"list_files"},
+	"WebFetch":  {"fetch_page"},
+	"WebSearch": {"search_web"},
+}
+
+// * entries are tool names, globs such as api_*, or Claude names; a scoped entry such as
+// * Bash(git:*) grants nothing, run_command cannot hold a tool to the pattern
+func (s *Skill) AllowsTool(name string) bool {
+	if s == nil || len(s.AllowedTools) == 0 {
+		return true
+	}
+	for _, entry := range s.AllowedTools {
+		entry = strings.TrimSpace(entry)
+		if isScoped(entry) {
+			continue
+		}
+		if mapped, ok := claudeTools[entry]; ok {
+			for _, tool := range mapped {
+				if tool == name {
+					return true
+				}
+			}
+			continue
+		}
+		if ok, _ := path.Match(entry, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isScoped(entry string) bool {
+	return strings.Contains(entry, "(")
+}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // ---
@@ -16,10 +19,54 @@ import (
 // ---
 var (
 	headerRegex = regexp.MustCompile(`(?s)^---\n(.*?)\n---\n?(.*)$`)
-	nameRegex   = regexp.MustCompile(`(?m)^name:\s*(.+)$`)
-	descRegex   = regexp.MustCompile(`(?m)^description:\s*(.+)$`)
+	// * fallback for frontmatter that is not valid yaml, ex. an unquoted "description: Use when: ..."
+	nameRegex = regexp.MustCompile(`(?m)^name:\s*(.+)$`)
+	descRegex = regexp.MustCompile(`(?m)^description:\s*(.+)$`)
+	// * keys limiting what a skill may do, a header setting them must be read in full
+	limitRegex = regexp.MustCompile(`(?m)^(allowed-tools|model|max-iterations)\s*:`)
 )
 
+type frontmatter struct {
+	Name          string         `yaml:"name"`
+	Description   string         `yaml:"description"`
+	AllowedTools  toolList       `yaml:"allowed-tools"`
+	Model         string         `yaml:"model"`
+	MaxIterations int            `yaml:"max-iterations"`
+	Arguments     []Argument     `yaml:"arguments"`
+	Version       string         `yaml:"version"`
+	Metadata      map[string]any `yaml:",inline"`
+}
+
+// * "Read, Grep, Bash(git:*)" as Claude writes it, or a yaml list
+type toolList []string
+
+func (l *toolList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		for _, name := range strings.Split(node.Value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				*l = append(*l, name)
+			}
+		}
+		return nil
+	}
+	var names []string
+	if err := node.Decode(&names); err != nil {
+		return err
+	}
+	*l = names
+	return nil
+}
+
+// * a plain string is the name
+func (a *Argument) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		a.Name = node.Value
+		return nil
+	}
+	type plain Argument
+	return node.Decode((*plain)(a))
+}
+
 func parser(path string) (*Skill, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -49,14 +96,46 @@ func parser(path string) (*Skill, error) {
 	}
 	skill.Body = body
 
-	matches := nameRegex.FindSubmatch(header)
-	if matches != nil {
-		skill.Name = strings.TrimSpace(string(matches[1]))
+	var fm frontmatter
+	if err := yaml.Unmarshal(header, &fm); err != nil {
+		// * dropping the limits would run the skill with every tool
+		if limit := limitRegex.FindSubmatch(header); limit != nil {
+			return nil, fmt.Errorf("yaml.Unmarshal: %s is set but the frontmatter is not valid yaml: %w", limit[1], err)
+		}
+		slog.Warn("failed to parse skill frontmatter, reading name and description only",
+			slog.String("path", absPath),
+			slog.String("error", err.Error()))
+
+		matches := nameRegex.FindSubmatch(header)
+		if matches != nil {
+			skill.Name = strings.TrimSpace(string(matches[1]))
+		}
+
+		matches = descRegex.FindSubmatch(header)
+		if matches != nil {
+			skill.Description = strings.TrimSpace(string(matches[1]))
+		}
+		return skill, nil
 	}
 
-	matches = descRegex.FindSubmatch(header)
-	if matches != nil {
-		skill.Description = strings.TrimSpace(string(matches[1]))
+	if name := strings.TrimSpace(fm.Name); name != "" {
+		skill.Name = name
+	}
+	skill.Description = strings.TrimSpace(fm.Description)
+	skill.AllowedTools = fm.AllowedTools
+	for _, entry := range fm.AllowedTools {
+		if isScoped(entry) {
+			slog.Warn("scoped allowed-tools entry grants nothing",
+				slog.String("path", absPath),
+				slog.String("entry", entry))
+		}
+	}
+	skill.Model = strings.TrimSpace(fm.Model)
+	skill.MaxIterations = max(fm.MaxIterations, 0)
+	skill.Arguments = fm.Arguments
+	skill.Version = strings.TrimSpace(fm.Version)
+	if len(fm.Metadata) > 0 {
+		skill.Metadata = fm.Metadata
 	}
 
 	return skill, nil
@@ -73,3 +152,15 @@ func extractHeader(content []byte) ([]byte, string, error) {
 
 	return frontmatter, body, nil
 }
+
+// * relative scripts/, templates/ and assets/ references become absolute paths
+func (s *Skill) Resolve(content string) string {
+	for _, prefix := range []string{"scripts/", "templates/", "assets/"} {
+		resolved := filepath.Join(s.Path, prefix)
+
+		if _, err := os.Stat(resolved); err == nil {
+			content = strings.ReplaceAll(content, prefix, resolved+string(filepath.Separator))
+		}
+	}
+	return content
+}
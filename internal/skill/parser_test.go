@@ -293,4 +293,132 @@ func TestParser(t *testing.T) {
 			t.Errorf("Name = %q, want %q", skill.Name, "override-name")
 		}
 	})
+
+	t.Run("full yaml frontmatter", func(t *testing.T) {
+		dir := t.TempDir()
+		skillDir := filepath.Join(dir, "release")
+		os.MkdirAll(skillDir, 0755)
+		path := filepath.Join(skillDir, "SKILL.md")
+
+		content := `---
+name: "release-notes"
+description: >
+  Generate release notes from git history.
+  Use when: the user asks for a changelog.
+allowed-tools: Read, Grep, Bash(git log:*)
+model: claude@claude-sonnet-4-5
+max-iterations: 24
+arguments:
+  - name: since
+    description: tag to start from
+    required: true
+  - until
+version: 1.2
+license: MIT
+metadata:
+  owner: docs-team
+---
+# Steps`
+		os.WriteFile(path, []byte(content), 0644)
+
+		skill, err := parser(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if skill.Name != "release-notes" {
+			t.Errorf("Name = %q", skill.Name)
+		}
+		if skill.Description != "Generate release notes from git history. Use when: the user asks for a changelog." {
+			t.Errorf("Description = %q", skill.Description)
+		}
+		if len(skill.AllowedTools) != 3 || skill.AllowedTools[2] != "Bash(git log:*)" {
+			t.Errorf("AllowedTools = %q", skill.AllowedTools)
+		}
+		if skill.Model != "claude@claude-sonnet-4-5" || skill.MaxIterations != 24 || skill.Version != "1.2" {
+			t.Errorf("Model = %q, MaxIterations = %d, Version = %q", skill.Model, skill.MaxIterations, skill.Version)
+		}
+		if len(skill.Arguments) != 2 || skill.Arguments[0] != (Argument{Name: "since", Description: "tag to start from", Required: true}) || skill.Arguments[1].Name != "until" {
+			t.Errorf("Arguments = %+v", skill.Arguments)
+		}
+		owner, _ := skill.Metadata["metadata"].(map[string]any)
+		if skill.Metadata["license"] != "MIT" || owner["owner"] != "docs-team" || len(skill.Metadata) != 2 {
+			t.Errorf("Metadata = %v", skill.Metadata)
+		}
+		if skill.Body != "# Steps" {
+			t.Errorf("Body = %q", skill.Body)
+		}
+	})
+
+	t.Run("allowed-tools as a list", func(t *testing.T) {
+		dir := t.TempDir()
+		skillDir := filepath.Join(dir, "lister")
+		os.MkdirAll(skillDir, 0755)
+		path := filepath.Join(skillDir, "SKILL.md")
+
+		os.WriteFile(path, []byte("---\nallowed-tools:\n  - read_file\n  - api_*\n---\nbody"), 0644)
+
+		skill, err := parser(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(skill.AllowedTools) != 2 || skill.AllowedTools[1] != "api_*" {
+			t.Errorf("AllowedTools = %q", skill.AllowedTools)
+		}
+	})
+
+	t.Run("invalid yaml keeps name and description", func(t *testing.T) {
+		dir := t.TempDir()
+		skillDir := filepath.Join(dir, "loose")
+		os.MkdirAll(skillDir, 0755)
+		path := filepath.Join(skillDir, "SKILL.md")
+
+		os.WriteFile(path, []byte("---\nname: loose-skill\ndescription: Fetch data. Use when: asked for data\n---\nbody"), 0644)
+
+		skill, err := parser(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if skill.Name != "loose-skill" || skill.Description != "Fetch data. Use when: asked for data" {
+			t.Errorf("Name = %q, Description = %q", skill.Name, skill.Description)
+		}
+	})
+
+	t.Run("invalid yaml with limits is rejected", func(t *testing.T) {
+		for name, header := range map[string]string{
+			"tools": "allowed-tools: Read, Grep",
+			"model": "model: claude@claude-sonnet-4-5",
+			"iter":  "max-iterations: 4",
+		} {
+			skillDir := filepath.Join(t.TempDir(), name)
+			os.MkdirAll(skillDir, 0755)
+			path := filepath.Join(skillDir, "SKILL.md")
+			os.WriteFile(path, []byte("---\nname: loose\ndescription: Use when: asked\n"+header+"\n---\nbody"), 0644)
+
+			if skill, err := parser(path); err == nil {
+				t.Errorf("%s: parsed without its limits: %+v", name, skill)
+			}
+		}
+	})
+}
+
+func TestSkill_AllowsTool(t *testing.T) {
+	s := &Skill{AllowedTools: []string{"Read", "Bash(git log:*)", "api_*", "search_web"}}
+	for name, want := range map[string]bool{
+		"read_file":      true,
+		"run_command":    false, // * Bash(git log:*) is scoped, not all of Bash
+		"api_weather":    true,
+		"search_web":     true,
+		"write_file":     false,
+		"search_content": false,
+	} {
+		if got := s.AllowsTool(name); got != want {
+			t.Errorf("AllowsTool(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !(&Skill{AllowedTools: []string{"Bash"}}).AllowsTool("run_command") {
+		t.Error("Bash should allow run_command")
+	}
+	if !(&Skill{}).AllowsTool("write_file") {
+		t.Error("no allowed-tools should allow every tool")
+	}
 }
@@ -5,12 +5,16 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
 
 type Scanner struct {
-	paths  []string
+	paths   []string
+	extra   []*Skill
+	scanned map[string]*Skill // * by AbsPath, what the last Scan and rescans found
+	// * replaced as a whole under mu, never changed in place; read it through Snapshot
 	Skills *SkillList
 	mu     sync.RWMutex
 }
@@ -23,11 +27,25 @@ type Skill struct {
 	Content     string
 	Body        string
 	Hash        string
+
+	// * optional frontmatter fields
+	AllowedTools  []string // * tools the skill may call, empty means all
+	Model         string   // * preferred agent, ex. claude@claude-sonnet-4-5
+	MaxIterations int      // * tool loop limit, zero means the default
+	Arguments     []Argument
+	Version       string
+	Metadata      map[string]any // * every other frontmatter field
+}
+
+type Argument struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
 }
 
 type SkillList struct {
 	ByName map[string]*Skill
-	ByPath map[string]*Skill
+	ByPath map[string]*Skill // * every scanned SKILL.md, shadowed duplicates included
 	Paths  []string
 }
 
@@ -38,7 +56,20 @@ func NewScanner() *Scanner {
 	}
 
 	cwd, _ := os.Getwd()
-	paths := []string{
+	return NewScannerWithPaths(DefaultPaths(cwd, home)...)
+}
+
+func NewScannerWithPaths(paths ...string) *Scanner {
+	scanner := &Scanner{
+		paths: paths,
+	}
+	scanner.Scan()
+
+	return scanner
+}
+
+func DefaultPaths(cwd, home string) []string {
+	return []string{
 		filepath.Join(cwd, ".claude", "skills"),
 		filepath.Join(cwd, ".skills"),
 		filepath.Join(home, ".claude", "skills"),
@@ -49,22 +80,25 @@ func NewScanner() *Scanner {
 		"/mnt/skills/user",
 		"/mnt/skills/examples",
 	}
+}
 
-	scanner := &Scanner{
-		paths: paths,
-	}
-	scanner.Scan()
+// * added skills are kept across Scan and win over scanned skills with the same name
+func (s *Scanner) Add(skills ...*Skill) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return scanner
+	for _, skill := range skills {
+		if skill == nil || skill.Name == "" {
+			continue
+		}
+		s.extra = append(s.extra, skill)
+	}
+	if s.Skills != nil {
+		s.Skills = s.build()
+	}
 }
 
 func (s *Scanner) Scan() {
-	list := &SkillList{
-		ByName: make(map[string]*Skill),
-		ByPath: make(map[string]*Skill),
-		Paths:  s.paths,
-	}
-
 	// * concurrent scan path list
 	var wg sync.WaitGroup
 	skillChan := make(chan *Skill, 100)
@@ -86,12 +120,9 @@ func (s *Scanner) Scan() {
 		close(errChan)
 	}()
 
+	byPath := make(map[string]*Skill)
 	for skill := range skillChan {
-		if _, ok := list.ByName[skill.Name]; ok {
-			continue
-		}
-		list.ByName[skill.Name] = skill
-		list.ByPath[skill.AbsPath] = skill
+		byPath[skill.AbsPath] = skill
 	}
 
 	var errs []error
@@ -102,10 +133,58 @@ func (s *Scanner) Scan() {
 	}
 
 	s.mu.Lock()
-	s.Skills = list
+	s.scanned = byPath
+	s.Skills = s.build()
 	s.mu.Unlock()
 }
 
+// * names go to added skills first, then to the earliest scan path; caller holds mu
+func (s *Scanner) build() *SkillList {
+	list := &SkillList{
+		ByName: make(map[string]*Skill),
+		ByPath: make(map[string]*Skill, len(s.scanned)),
+		Paths:  s.paths,
+	}
+	for _, skill := range s.extra {
+		list.ByName[skill.Name] = skill
+	}
+
+	scanned := make([]*Skill, 0, len(s.scanned))
+	for path, skill := range s.scanned {
+		list.ByPath[path] = skill
+		scanned = append(scanned, skill)
+	}
+	sort.Slice(scanned, func(i, j int) bool {
+		ri, rj := s.rootIndex(scanned[i].AbsPath), s.rootIndex(scanned[j].AbsPath)
+		if ri != rj {
+			return ri < rj
+		}
+		return scanned[i].AbsPath < scanned[j].AbsPath
+	})
+	for _, skill := range scanned {
+		if _, ok := list.ByName[skill.Name]; !ok {
+			list.ByName[skill.Name] = skill
+		}
+	}
+	for _, skill := range s.extra {
+		if skill.AbsPath != "" {
+			list.ByPath[skill.AbsPath] = skill
+		}
+	}
+	return list
+}
+
+// * position in paths of the root holding {root}/{name}/SKILL.md
+func (s *Scanner) rootIndex(absPath string) int {
+	root := filepath.Dir(filepath.Dir(absPath))
+	for i, path := range s.paths {
+		if abs, err := filepath.Abs(path); err == nil && abs == root {
+			return i
+		}
+	}
+	return len(s.paths)
+}
+
 func (s *Scanner) scan(root string, skillChan chan<- *Skill) error {
 	// * path not exists
 	if _, err := os.Stat(root); os.IsNotExist(err) {
@@ -144,9 +223,25 @@ func (s *Scanner) scan(root string, skillChan chan<- *Skill) error {
 }
 
 func (s *Scanner) List() []string {
-	names := make([]string, 0, len(s.Skills.ByName))
-	for name := range s.Skills.ByName {
+	list := s.Snapshot()
+	names := make([]string, 0, len(list.ByName))
+	for name := range list.ByName {
 		names = append(names, strings.TrimSpace(name))
 	}
 	return names
 }
+
+// * the current skills; a later Scan or watch swaps in a new list and leaves this one as is
+func (s *Scanner) Snapshot() *SkillList {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.Skills == nil {
+		return &SkillList{ByName: map[string]*Skill{}, ByPath: map[string]*Skill{}, Paths: s.paths}
+	}
+	return s.Skills
+}
+
+func (s *Scanner) Get(name string) *Skill {
+	return s.Snapshot().ByName[name]
+}
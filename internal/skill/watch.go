@@ -0,0 +1,111 @@
+package skill
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// * editors save in several steps, changes within this window are read once
+	watchDebounce = 200 * time.Millisecond
+)
+
+// * re-reads {dir}/SKILL.md of each skill dir, a missing file removes the skill;
+// * a new list is swapped in only when a Hash changed, reports whether it was
+func (s *Scanner) Rescan(dirs ...string) bool {
+	parsed := make(map[string]*Skill, len(dirs))
+	for _, dir := range dirs {
+		path, err := filepath.Abs(filepath.Join(dir, "SKILL.md"))
+		if err != nil {
+			continue
+		}
+		skill, err := parser(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("failed to parse skill",
+					slog.String("path", path),
+					slog.String("error", err.Error()))
+			}
+			skill = nil
+		}
+		parsed[path] = skill
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for path, skill := range parsed {
+		old, ok := s.scanned[path]
+		if (skill == nil && !ok) || (skill != nil && ok && old.Hash == skill.Hash) {
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return false
+	}
+
+	// * the published map is shared with snapshots, so the next one is a copy
+	next := make(map[string]*Skill, len(s.scanned)+len(parsed))
+	for path, skill := range s.scanned {
+		next[path] = skill
+	}
+	for path, skill := range parsed {
+		if skill == nil {
+			delete(next, path)
+		} else {
+			next[path] = skill
+		}
+	}
+	s.scanned = next
+	s.Skills = s.build()
+	return true
+}
+
+// * runs fn with the changed skill dirs once they have been quiet for watchDebounce
+func debounce(ctx context.Context, changes <-chan string, fn func(dirs []string)) {
+	pending := make(map[string]bool)
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dir, ok := <-changes:
+			if !ok {
+				return
+			}
+			pending[dir] = true
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			dirs := make([]string, 0, len(pending))
+			for dir := range pending {
+				dirs = append(dirs, dir)
+			}
+			clear(pending)
+			fn(dirs)
+		}
+	}
+}
+
+// * skill dirs in root, hidden ones skipped like Scan does
+func skillDirs(root string) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && e.Name()[0] != '.' {
+			dirs = append(dirs, filepath.Join(root, e.Name()))
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,152 @@
+//go:build linux
+
+package skill
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// * skills appearing in or leaving a scan path
+	rootMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ONLYDIR
+	// * SKILL.md written, replaced or removed in a skill dir
+	dirMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+		unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+)
+
+// * inotify on the scan paths and the skill dirs in them, blocks until ctx is done;
+// * scan paths missing when it starts are not watched
+func (s *Scanner) Watch(ctx context.Context) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("unix.InotifyInit1: %w", err)
+	}
+	// * non-blocking fd goes to the runtime poller, so Close ends a pending Read
+	file := os.NewFile(uintptr(fd), "inotify")
+	defer file.Close()
+
+	w := &inotify{
+		fd:    fd,
+		roots: make(map[int]string),
+		dirs:  make(map[int]string),
+	}
+	w.watchAll(s.paths)
+
+	changes := make(chan string, 64)
+	go debounce(ctx, changes, func(dirs []string) {
+		s.Rescan(dirs...)
+	})
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("file.Read: %w", err)
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			// * events were dropped, nothing short of a full scan is reliable
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				w.watchAll(s.paths)
+				s.Scan()
+				continue
+			}
+			for _, dir := range w.handle(int(event.Wd), event.Mask, name) {
+				select {
+				case changes <- dir:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
+
+type inotify struct {
+	fd    int
+	roots map[int]string // * watch descriptor to scan path
+	dirs  map[int]string // * watch descriptor to skill dir
+}
+
+func (w *inotify) watchAll(paths []string) {
+	for _, root := range paths {
+		wd, err := unix.InotifyAddWatch(w.fd, root, rootMask)
+		if err != nil {
+			if !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.ENOTDIR) {
+				slog.Warn("failed to watch skill path",
+					slog.String("path", root),
+					slog.String("error", err.Error()))
+			}
+			continue
+		}
+		w.roots[wd] = root
+		for _, dir := range skillDirs(root) {
+			w.watchDir(dir)
+		}
+	}
+}
+
+func (w *inotify) watchDir(dir string) {
+	wd, err := unix.InotifyAddWatch(w.fd, dir, dirMask)
+	if err != nil {
+		// * gone again before it could be watched, its rescan drops it
+		if !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.ENOTDIR) {
+			slog.Warn("failed to watch skill dir",
+				slog.String("path", dir),
+				slog.String("error", err.Error()))
+		}
+		return
+	}
+	w.dirs[wd] = dir
+}
+
+// * skill dirs to rescan for one event
+func (w *inotify) handle(wd int, mask uint32, name string) []string {
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.roots, wd)
+		delete(w.dirs, wd)
+		return nil
+	}
+
+	if root, ok := w.roots[wd]; ok {
+		if mask&unix.IN_ISDIR == 0 || name == "" || name[0] == '.' {
+			return nil
+		}
+		dir := filepath.Join(root, name)
+		// * a dir moved in may already hold its SKILL.md, the rescan reads it
+		if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			w.watchDir(dir)
+		}
+		return []string{dir}
+	}
+
+	dir, ok := w.dirs[wd]
+	if !ok {
+		return nil
+	}
+	if mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 || name == "SKILL.md" {
+		return []string{dir}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build !linux
+
+package skill
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var watchInterval = 2 * time.Second
+
+// * no inotify here, SKILL.md modtime and size are polled every watchInterval;
+// * blocks until ctx is done, scan paths missing when it starts are still picked up
+func (s *Scanner) Watch(ctx context.Context) error {
+	seen := s.stat()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			next := s.stat()
+			var dirs []string
+			for dir, sig := range next {
+				if seen[dir] != sig {
+					dirs = append(dirs, dir)
+				}
+			}
+			for dir := range seen {
+				if _, ok := next[dir]; !ok {
+					dirs = append(dirs, dir)
+				}
+			}
+			if len(dirs) > 0 {
+				s.Rescan(dirs...)
+			}
+			seen = next
+		}
+	}
+}
+
+// * skill dir to the modtime and size of its SKILL.md
+func (s *Scanner) stat() map[string]string {
+	sigs := make(map[string]string)
+	for _, root := range s.paths {
+		for _, dir := range skillDirs(root) {
+			info, err := os.Stat(filepath.Join(dir, "SKILL.md"))
+			if err != nil {
+				continue
+			}
+			sigs[dir] = fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return sigs
+}
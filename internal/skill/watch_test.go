@@ -0,0 +1,143 @@
+package skill
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ---------- Rescan ----------
+
+func writeSkill(t *testing.T, root, name, desc string) string {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\nname: " + name + "\ndescription: " + desc + "\n---\nbody"
+	if err := os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestScanner_Rescan(t *testing.T) {
+	dir1 := t.TempDir()
+	dir2 := t.TempDir()
+	alpha := writeSkill(t, dir1, "alpha", "first")
+	dup1 := writeSkill(t, dir1, "dup", "from dir1")
+	writeSkill(t, dir2, "dup", "from dir2")
+
+	s := &Scanner{paths: []string{dir1, dir2}}
+	s.Add(&Skill{Name: "added", Description: "extra"})
+	s.Scan()
+	if got := s.Get("dup").Description; got != "from dir1" {
+		t.Fatalf("dup = %q, want the first scan path", got)
+	}
+
+	// unchanged hash leaves the list as is
+	before := s.Snapshot()
+	if s.Rescan(alpha) {
+		t.Error("Rescan of an unchanged skill reported a change")
+	}
+	if s.Snapshot() != before {
+		t.Error("unchanged Rescan swapped the list")
+	}
+
+	// edit swaps in a new list, the old snapshot keeps the old skill
+	writeSkill(t, dir1, "alpha", "edited")
+	if !s.Rescan(alpha) {
+		t.Error("Rescan of an edited skill reported no change")
+	}
+	if got := s.Get("alpha").Description; got != "edited" {
+		t.Errorf("alpha = %q, want edited", got)
+	}
+	if got := before.ByName["alpha"].Description; got != "first" {
+		t.Errorf("old snapshot alpha = %q, want first", got)
+	}
+
+	// new skill dir
+	beta := writeSkill(t, dir2, "beta", "new")
+	if !s.Rescan(beta) || s.Get("beta") == nil {
+		t.Error("new skill not picked up")
+	}
+
+	// removing the winning duplicate promotes the other one
+	os.RemoveAll(dup1)
+	if !s.Rescan(dup1) {
+		t.Error("Rescan of a removed skill reported no change")
+	}
+	if got := s.Get("dup").Description; got != "from dir2" {
+		t.Errorf("dup = %q, want from dir2", got)
+	}
+	if s.Rescan(dup1) {
+		t.Error("Rescan of an already removed skill reported a change")
+	}
+
+	if s.Get("added") == nil {
+		t.Error("added skill lost on Rescan")
+	}
+}
+
+// ---------- Watch ----------
+
+func TestScanner_Watch(t *testing.T) {
+	root := t.TempDir()
+	writeSkill(t, root, "alpha", "first")
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	s := &Scanner{paths: []string{root, missing}}
+	s.Scan()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Watch(ctx)
+	}()
+	// readers race the swaps, run with -race
+	go func() {
+		for ctx.Err() == nil {
+			_ = s.List()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	eventually := func(what string, ok func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+			if ok() {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Errorf("%s not seen by Watch", what)
+	}
+
+	beta := writeSkill(t, root, "beta", "new")
+	eventually("new skill", func() bool {
+		return s.Get("beta") != nil
+	})
+
+	writeSkill(t, root, "alpha", "edited")
+	eventually("edited skill", func() bool {
+		return s.Get("alpha").Description == "edited"
+	})
+
+	os.RemoveAll(beta)
+	eventually("removed skill", func() bool {
+		return s.Get("beta") == nil
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Watch() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Watch did not return after cancel")
+	}
+}
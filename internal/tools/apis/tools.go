@@ -0,0 +1,86 @@
+package apis
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pardnchiu/agenvoy/internal/tools/apiAdapter"
+	"github.com/pardnchiu/agenvoy/internal/tools/apis/googleRSS"
+	"github.com/pardnchiu/agenvoy/internal/tools/apis/weatherReport"
+	"github.com/pardnchiu/agenvoy/internal/tools/apis/yahooFinance"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+//go:embed embed/tools.json
+var toolsJSON []byte
+
+func Tools() ([]toolTypes.Tool, error) {
+	return toolTypes.Bind(toolsJSON, map[string]toolTypes.Binding{
+		"send_http_request": {
+			Flags: toolTypes.FlagNetwork,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					URL         string            `json:"url"`
+					Method      string            `json:"method"`
+					Headers     map[string]string `json:"headers"`
+					Body        map[string]any    `json:"body"`
+					ContentType string            `json:"content_type"`
+					Timeout     int               `json:"timeout"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return apiAdapter.Send(params.URL, params.Method, params.Headers, params.Body, params.ContentType, params.Timeout)
+			},
+		},
+
+		"fetch_yahoo_finance": {
+			Flags: toolTypes.FlagReadOnly | toolTypes.FlagNetwork,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Symbol   string `json:"symbol"`
+					Interval string `json:"interval"`
+					Range    string `json:"range"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return yahooFinance.Fetch(params.Symbol, params.Interval, params.Range)
+			},
+		},
+
+		"fetch_google_rss": {
+			Flags: toolTypes.FlagReadOnly | toolTypes.FlagNetwork,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Keyword string `json:"keyword"`
+					Time    string `json:"time"`
+					Lang    string `json:"lang"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return googleRSS.Fetch(params.Keyword, params.Time, params.Lang)
+			},
+		},
+
+		"fetch_weather": {
+			Flags: toolTypes.FlagReadOnly | toolTypes.FlagNetwork,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					City           string      `json:"city"`
+					Days           int         `json:"days"`
+					HourlyInterval json.Number `json:"hourly_interval"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("failed to unmarshal json (fetch_weather): %w", err)
+				}
+				hourlyInterval, _ := params.HourlyInterval.Int64()
+				return weatherReport.Fetch(params.City, params.Days, int(hourlyInterval))
+
+			},
+		},
+	})
+}
@@ -5,8 +5,10 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"log/slog"
+	"time"
 
+	"github.com/pardnchiu/agenvoy/internal/sessions"
 	"github.com/pardnchiu/agenvoy/internal/tools/apiAdapter"
 	"github.com/pardnchiu/agenvoy/internal/tools/apis"
 	"github.com/pardnchiu/agenvoy/internal/tools/apis/searchWeb"
@@ -18,17 +20,91 @@ import (
 )
 
 //go:embed embed/tools.json
-var toolsMap []byte
+var toolsJSON []byte
 
 //go:embed embed/commands.json
 var allowCommand []byte
 
-func NewExecutor(workPath, sessionID string) (*toolTypes.Executor, error) {
-	var tools []toolTypes.Tool
-	if err := json.Unmarshal(toolsMap, &tools); err != nil {
-		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+func Tools() ([]toolTypes.Tool, error) {
+	return toolTypes.Bind(toolsJSON, map[string]toolTypes.Binding{
+		"run_command": {
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Command string `json:"command"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return runCommand(ctx, e, params.Command)
+			},
+		},
+
+		"fetch_page": {
+			Flags: toolTypes.FlagReadOnly | toolTypes.FlagNetwork,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					URL string `json:"url"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("failed to unmarshal json (fetch_page): %w", err)
+				}
+				return browser.Load(params.URL)
+			},
+		},
+
+		"search_web": {
+			Flags: toolTypes.FlagReadOnly | toolTypes.FlagNetwork,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Query string `json:"query"`
+					Range string `json:"range"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("failed to unmarshal json (search_web): %w", err)
+				}
+				return searchWeb.Search(ctx, params.Query, searchWeb.TimeRange(params.Range))
+			},
+		},
+
+		"calculate": {
+			Flags: toolTypes.FlagReadOnly,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Expression string `json:"expression"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return calculator.Calc(params.Expression)
+			},
+		},
+	})
+}
+
+// * built-in file, api, shell and browser tools
+func Builtins() ([]toolTypes.Tool, error) {
+	var all []toolTypes.Tool
+	for _, fn := range []func() ([]toolTypes.Tool, error){file.Tools, apis.Tools, Tools} {
+		list, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list...)
 	}
+	return all, nil
+}
+
+// * configDir is the root config dir, custom tools are registered after the built-in and api tools
+func NewExecutor(workPath string, configDir *utils.ConfigDirData, sessionID string, custom ...toolTypes.Tool) (*toolTypes.Executor, error) {
+	return newExecutor(workPath, configDir, sessionID, true, custom...)
+}
+
+// * built-in and api tools only, servers from mcp.json are not mounted
+func NewLocalExecutor(workPath string, configDir *utils.ConfigDirData) (*toolTypes.Executor, error) {
+	return newExecutor(workPath, configDir, "", false)
+}
 
+func newExecutor(workPath string, configDir *utils.ConfigDirData, sessionID string, withMCP bool, custom ...toolTypes.Tool) (*toolTypes.Executor, error) {
 	var commands []string
 	if err := json.Unmarshal(allowCommand, &commands); err != nil {
 		return nil, fmt.Errorf("json.Unmarshal: %w", err)
@@ -39,11 +115,21 @@ func NewExecutor(workPath, sessionID string) (*toolTypes.Executor, error) {
 		allowedCommand[cmd] = true
 	}
 
+	builtins, err := Builtins()
+	if err != nil {
+		return nil, fmt.Errorf("Builtins: %w", err)
+	}
+
+	registry := toolTypes.NewRegistry()
+	if err := registry.Register(builtins...); err != nil {
+		return nil, fmt.Errorf("registry.Register: %w", err)
+	}
+
 	apiToolbox := apiAdapter.New()
 
-	if configDir, err := utils.GetConfigDir("apis"); err == nil {
-		apiToolbox.Load(configDir.Home)
-		apiToolbox.Load(configDir.Work)
+	if apiDir, err := configDir.Sub("apis"); err == nil {
+		apiToolbox.Load(apiDir.Home)
+		apiToolbox.Load(apiDir.Work)
 	}
 
 	for _, tool := range apiToolbox.GetTools() {
@@ -51,23 +137,88 @@ func NewExecutor(workPath, sessionID string) (*toolTypes.Executor, error) {
 		if err != nil {
 			continue
 		}
-		var t toolTypes.Tool
-		if err := json.Unmarshal(data, &t); err != nil {
+		var def toolTypes.ToolDef
+		if err := json.Unmarshal(data, &def); err != nil {
 			continue
 		}
-		tools = append(tools, t)
+		if err := registry.Register(toolTypes.NewTool(def, toolTypes.FlagNetwork, apiHandler(def.Function.Name))); err != nil {
+			continue
+		}
+	}
+
+	if withMCP {
+		for _, tool := range mcpTools(configDir) {
+			if err := registry.Register(tool); err != nil {
+				slog.Warn("failed to register mcp tool",
+					slog.String("name", tool.Name()),
+					slog.String("error", err.Error()))
+				continue
+			}
+		}
+	}
+
+	if err := registry.Register(custom...); err != nil {
+		return nil, fmt.Errorf("registry.Register: %w", err)
+	}
+
+	sessionDir, err := configDir.Sub("sessions")
+	if err != nil {
+		return nil, fmt.Errorf("configDir.Sub: %w", err)
 	}
 
 	return &toolTypes.Executor{
 		WorkPath:       workPath,
 		SessionID:      sessionID,
+		SessionDir:     sessionDir.Home,
+		SearchHistory:  historySearch(configDir, sessionID),
+		Allowed:        file.ListAllowed(workPath, configDir.Home),
 		AllowedCommand: allowedCommand,
 		Exclude:        file.ListExcludes(workPath),
-		Tools:          tools,
+		Registry:       registry,
 		APIToolbox:     apiToolbox,
 	}, nil
 }
 
+// * nil unless the session store has a search index
+func historySearch(configDir *utils.ConfigDirData, sessionID string) toolTypes.HistorySearch {
+	if sessionID == "" {
+		return nil
+	}
+	store, err := sessions.Open(configDir)
+	if err != nil {
+		slog.Warn("failed to open session store",
+			slog.String("error", err.Error()))
+		return nil
+	}
+	searcher, ok := store.(sessions.Searcher)
+	if !ok {
+		return nil
+	}
+	return func(sessionID, keyword string, after time.Time, skip, limit int) ([]toolTypes.HistoryEntry, error) {
+		messages, err := searcher.SearchHistory(sessionID, keyword, after, skip, limit)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]toolTypes.HistoryEntry, 0, len(messages))
+		for _, m := range messages {
+			content, _ := m.Content.(string)
+			entries = append(entries, toolTypes.HistoryEntry{Role: m.Role, Content: content})
+		}
+		return entries, nil
+	}
+}
+
+// * api_* tools from .config/agenvoy/apis
+func apiHandler(name string) toolTypes.Handler {
+	return func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+		var params map[string]any
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		return e.APIToolbox.Execute(name, params)
+	}
+}
+
 func normalizeArgs(args json.RawMessage) json.RawMessage {
 	var m map[string]any
 	if err := json.Unmarshal(args, &m); err != nil {
@@ -88,66 +239,5 @@ func normalizeArgs(args json.RawMessage) json.RawMessage {
 }
 
 func Execute(ctx context.Context, e *toolTypes.Executor, name string, args json.RawMessage) (string, error) {
-	args = normalizeArgs(args)
-	// * get all api tools
-	if strings.HasPrefix(name, "api_") && e.APIToolbox != nil && e.APIToolbox.IsExist(name) {
-		var params map[string]any
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return e.APIToolbox.Execute(name, params)
-	}
-
-	switch name {
-	case "read_file", "list_files", "glob_files", "search_content", "search_history", "write_file", "patch_edit":
-		return file.Routes(e, name, args)
-
-	case "send_http_request", "fetch_yahoo_finance", "fetch_google_rss", "fetch_weather":
-		return apis.Routes(e, name, args)
-
-	case "run_command":
-		var params struct {
-			Command string `json:"command"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return runCommand(ctx, e, params.Command)
-
-	case "fetch_page":
-		var params struct {
-			URL string `json:"url"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("failed to unmarshal json (%s): %w", name, err)
-		}
-
-		result, err := browser.Load(params.URL)
-		if err != nil {
-			return "", err
-		}
-		return result, nil
-
-	case "search_web":
-		var params struct {
-			Query string `json:"query"`
-			Range string `json:"range"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("failed to unmarshal json (%s): %w", name, err)
-		}
-		return searchWeb.Search(ctx, params.Query, searchWeb.TimeRange(params.Range))
-
-	case "calculate":
-		var params struct {
-			Expression string `json:"expression"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return calculator.Calc(params.Expression)
-
-	default:
-		return "", fmt.Errorf("unknown tool: %s", name)
-	}
+	return e.Registry.Execute(ctx, e, name, normalizeArgs(args))
 }
@@ -0,0 +1,152 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+// * work path first, then "allowed_paths" in {configHome}/config.json; the project config
+// * is inside the sandbox, so it could widen the sandbox by itself and is never read.
+// * A list applies to every work path, an object maps work paths to their own lists
+func ListAllowed(workPath, configHome string) []string {
+	allowed := []string{workPath}
+
+	data, err := os.ReadFile(filepath.Join(configHome, "config.json"))
+	if err != nil {
+		return allowed
+	}
+
+	var cfg struct {
+		AllowedPaths json.RawMessage `json:"allowed_paths"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil || len(cfg.AllowedPaths) == 0 {
+		return allowed
+	}
+
+	var paths []string
+	if err := json.Unmarshal(cfg.AllowedPaths, &paths); err != nil {
+		var byProject map[string][]string
+		if err := json.Unmarshal(cfg.AllowedPaths, &byProject); err != nil {
+			slog.Warn("failed to parse allowed_paths, using work path only",
+				slog.String("error", err.Error()))
+			return allowed
+		}
+		for project, list := range byProject {
+			if filepath.Clean(expandHome(project)) == filepath.Clean(workPath) {
+				paths = list
+				break
+			}
+		}
+	}
+
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		path = expandHome(path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workPath, path)
+		}
+		allowed = append(allowed, filepath.Clean(path))
+	}
+	return allowed
+}
+
+// * resolve symlinks and .. first, then the real path must be inside one of allowed folders
+func ResolvePath(e *toolTypes.Executor, path string) (string, error) {
+	fullPath := getFullPath(e, expandHome(path))
+
+	resolved, err := evalPath(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path (%s): %w", path, err)
+	}
+
+	roots := e.Allowed
+	if len(roots) == 0 {
+		roots = []string{e.WorkPath}
+	}
+	for _, root := range roots {
+		realRoot, err := evalPath(root)
+		if err != nil {
+			continue
+		}
+		if isWithin(realRoot, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("path is outside allowed folders: %s", path)
+}
+
+var ErrConfigPath = errors.New("path is inside the agenvoy config folder")
+
+// * ResolvePath for tools which write; {workPath}/.config/agenvoy holds the project's
+// * mcp servers, api tools and routes, so it is never written from inside the sandbox
+func ResolveWritePath(e *toolTypes.Executor, path string) (string, error) {
+	resolved, err := ResolvePath(e, path)
+	if err != nil {
+		return "", err
+	}
+
+	workPath, err := evalPath(e.WorkPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path (%s): %w", e.WorkPath, err)
+	}
+	if isWithin(filepath.Join(workPath, ".config", "agenvoy"), resolved) {
+		return "", fmt.Errorf("%w: %s", ErrConfigPath, path)
+	}
+	return resolved, nil
+}
+
+// * like filepath.EvalSymlinks, but allow the last parts not exist yet (ex. write new file)
+func evalPath(path string) (string, error) {
+	path = filepath.Clean(path)
+
+	var missing []string
+	current := path
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path, nil
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
+
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -1,7 +1,9 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -546,7 +548,7 @@ func TestExtractSec(t *testing.T) {
 
 func TestSearchHistory_EarlyExit(t *testing.T) {
 	t.Run("empty keyword", func(t *testing.T) {
-		_, err := searchHistory("session-id", "", "")
+		_, err := searchHistory("", "session-id", "", "")
 		if err == nil {
 			t.Fatal("expected error for empty keyword")
 		}
@@ -556,7 +558,7 @@ func TestSearchHistory_EarlyExit(t *testing.T) {
 	})
 
 	t.Run("empty sessionID", func(t *testing.T) {
-		_, err := searchHistory("", "keyword", "")
+		_, err := searchHistory("", "", "keyword", "")
 		if err == nil {
 			t.Fatal("expected error for empty sessionID")
 		}
@@ -609,14 +611,29 @@ func TestListExcludes_NonexistentDir(t *testing.T) {
 	_ = result // should not panic
 }
 
-// ---------- Routes ----------
+// ---------- Tools ----------
 
-func TestRoutes(t *testing.T) {
+// execute dispatches through a registry of file tools, as tools.Execute does.
+func execute(e *toolTypes.Executor, name string, args json.RawMessage) (string, error) {
+	if e.Registry == nil {
+		list, err := Tools()
+		if err != nil {
+			return "", err
+		}
+		e.Registry = toolTypes.NewRegistry()
+		if err := e.Registry.Register(list...); err != nil {
+			return "", err
+		}
+	}
+	return e.Registry.Execute(context.Background(), e, name, args)
+}
+
+func TestTools(t *testing.T) {
 	e := newExec(t)
 	writeTemp(t, e, "hello.txt", "hello world")
 
 	t.Run("read_file", func(t *testing.T) {
-		got, err := Routes(e, "read_file", []byte(`{"path":"hello.txt"}`))
+		got, err := execute(e, "read_file", []byte(`{"path":"hello.txt"}`))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -626,7 +643,7 @@ func TestRoutes(t *testing.T) {
 	})
 
 	t.Run("write_file", func(t *testing.T) {
-		_, err := Routes(e, "write_file", []byte(`{"path":"out.txt","content":"written"}`))
+		_, err := execute(e, "write_file", []byte(`{"path":"out.txt","content":"written"}`))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -637,7 +654,7 @@ func TestRoutes(t *testing.T) {
 	})
 
 	t.Run("list_files", func(t *testing.T) {
-		got, err := Routes(e, "list_files", []byte(`{"path":"","recursive":false}`))
+		got, err := execute(e, "list_files", []byte(`{"path":"","recursive":false}`))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -647,7 +664,7 @@ func TestRoutes(t *testing.T) {
 	})
 
 	t.Run("glob_files", func(t *testing.T) {
-		got, err := Routes(e, "glob_files", []byte(`{"pattern":"*.txt"}`))
+		got, err := execute(e, "glob_files", []byte(`{"pattern":"*.txt"}`))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -657,7 +674,7 @@ func TestRoutes(t *testing.T) {
 	})
 
 	t.Run("search_content", func(t *testing.T) {
-		got, err := Routes(e, "search_content", []byte(`{"pattern":"hello","file_pattern":""}`))
+		got, err := execute(e, "search_content", []byte(`{"pattern":"hello","file_pattern":""}`))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -667,7 +684,7 @@ func TestRoutes(t *testing.T) {
 	})
 
 	t.Run("patch_edit", func(t *testing.T) {
-		_, err := Routes(e, "patch_edit", []byte(`{"path":"hello.txt","old_string":"hello","new_string":"hi"}`))
+		_, err := execute(e, "patch_edit", []byte(`{"path":"hello.txt","old_string":"hello","new_string":"hi"}`))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -678,21 +695,21 @@ func TestRoutes(t *testing.T) {
 	})
 
 	t.Run("search_history empty keyword", func(t *testing.T) {
-		_, err := Routes(e, "search_history", []byte(`{"keyword":"","time_range":""}`))
+		_, err := execute(e, "search_history", []byte(`{"keyword":"","time_range":""}`))
 		if err == nil {
 			t.Fatal("expected error for empty keyword in search_history")
 		}
 	})
 
 	t.Run("unknown tool", func(t *testing.T) {
-		_, err := Routes(e, "unknown_tool", []byte(`{}`))
+		_, err := execute(e, "unknown_tool", []byte(`{}`))
 		if err == nil {
 			t.Fatal("expected error for unknown tool")
 		}
 	})
 
 	t.Run("invalid json", func(t *testing.T) {
-		_, err := Routes(e, "read_file", []byte(`{bad json`))
+		_, err := execute(e, "read_file", []byte(`{bad json`))
 		if err == nil {
 			t.Fatal("expected error for invalid json")
 		}
@@ -762,7 +779,7 @@ func setupHistoryFile(t *testing.T, sessionID string, entries []historyEntry) st
 
 func TestSearchHistory_FileNotFound(t *testing.T) {
 	t.Setenv("HOME", t.TempDir())
-	got, err := searchHistory("nonexistent-session", "keyword", "")
+	got, err := searchHistory("", "nonexistent-session", "keyword", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -779,7 +796,7 @@ func TestSearchHistory_BadJSON(t *testing.T) {
 	os.MkdirAll(sessDir, 0755)
 	os.WriteFile(filepath.Join(sessDir, "history.json"), []byte("not valid json"), 0644)
 
-	_, err := searchHistory("sess-bad", "keyword", "")
+	_, err := searchHistory("", "sess-bad", "keyword", "")
 	if err == nil {
 		t.Fatal("expected error for bad JSON history file")
 	}
@@ -798,7 +815,7 @@ func TestSearchHistory_WithMatches(t *testing.T) {
 	}
 	setupHistoryFile(t, "sess-match", entries)
 
-	got, err := searchHistory("sess-match", "target", "")
+	got, err := searchHistory("", "sess-match", "target", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -818,7 +835,7 @@ func TestSearchHistory_NoMatches(t *testing.T) {
 	}
 	setupHistoryFile(t, "sess-nomatch", entries)
 
-	got, err := searchHistory("sess-nomatch", "xyzzy_not_here", "")
+	got, err := searchHistory("", "sess-nomatch", "xyzzy_not_here", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -841,7 +858,7 @@ func TestSearchHistory_TimeRange(t *testing.T) {
 	setupHistoryFile(t, "sess-timerange", entries)
 
 	// With "1d" range, entries with ts=1000 (year 1970) are filtered out
-	got, err := searchHistory("sess-timerange", "target", "1d")
+	got, err := searchHistory("", "sess-timerange", "target", "1d")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -850,13 +867,13 @@ func TestSearchHistory_TimeRange(t *testing.T) {
 	}
 }
 
-// ---------- Routes: list_files recursive + search_history with session ----------
+// ---------- Tools: list_files recursive + search_history with session ----------
 
-func TestRoutes_ListFiles_Recursive(t *testing.T) {
+func TestTools_ListFiles_Recursive(t *testing.T) {
 	e := newExec(t)
 	writeTemp(t, e, "sub/deep.txt", "deep content")
 
-	got, err := Routes(e, "list_files", []byte(`{"path":"","recursive":true}`))
+	got, err := execute(e, "list_files", []byte(`{"path":"","recursive":true}`))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -865,9 +882,9 @@ func TestRoutes_ListFiles_Recursive(t *testing.T) {
 	}
 }
 
-// ---------- Routes: invalid JSON for each remaining case ----------
+// ---------- Tools: invalid JSON for each remaining case ----------
 
-func TestRoutes_InvalidJSON_AllCases(t *testing.T) {
+func TestTools_InvalidJSON_AllCases(t *testing.T) {
 	e := newExec(t)
 	cases := []string{
 		"write_file",
@@ -879,7 +896,7 @@ func TestRoutes_InvalidJSON_AllCases(t *testing.T) {
 	}
 	for _, name := range cases {
 		t.Run(name, func(t *testing.T) {
-			_, err := Routes(e, name, []byte(`{bad json`))
+			_, err := execute(e, name, []byte(`{bad json`))
 			if err == nil {
 				t.Fatalf("expected json.Unmarshal error for %s with invalid JSON", name)
 			}
@@ -922,7 +939,7 @@ func TestListExcludes_UnreadableIgnoreFile(t *testing.T) {
 	_ = result
 }
 
-func TestRoutes_SearchHistory_WithSession(t *testing.T) {
+func TestTools_SearchHistory_WithSession(t *testing.T) {
 	entries := []historyEntry{
 		{Role: "user", Content: "route target entry"},
 		{Role: "assistant", Content: "route response"},
@@ -938,7 +955,7 @@ func TestRoutes_SearchHistory_WithSession(t *testing.T) {
 		SessionID: "route-sess",
 	}
 
-	got, err := Routes(e, "search_history", []byte(`{"keyword":"route target","time_range":""}`))
+	got, err := execute(e, "search_history", []byte(`{"keyword":"route target","time_range":""}`))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -946,3 +963,185 @@ func TestRoutes_SearchHistory_WithSession(t *testing.T) {
 		t.Errorf("expected 'route target' in results, got: %s", got)
 	}
 }
+
+// ---------- ResolvePath (sandbox) ----------
+
+func TestResolvePath_Sandbox(t *testing.T) {
+	e := newExec(t)
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	writeTemp(t, e, "inside.txt", "inside")
+
+	t.Run("relative inside", func(t *testing.T) {
+		if _, err := ResolvePath(e, "inside.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("new file inside", func(t *testing.T) {
+		if _, err := ResolvePath(e, "new/dir/file.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("absolute outside", func(t *testing.T) {
+		if _, err := ResolvePath(e, filepath.Join(outside, "secret.txt")); err == nil {
+			t.Fatal("expected error for path outside work path")
+		}
+	})
+
+	t.Run("dot dot escape", func(t *testing.T) {
+		rel, _ := filepath.Rel(e.WorkPath, filepath.Join(outside, "secret.txt"))
+		if _, err := ResolvePath(e, rel); err == nil {
+			t.Fatalf("expected error for %s", rel)
+		}
+	})
+
+	t.Run("home path", func(t *testing.T) {
+		if _, err := ResolvePath(e, "~/.ssh/authorized_keys"); err == nil {
+			t.Fatal("expected error for home path")
+		}
+	})
+
+	t.Run("symlink escape", func(t *testing.T) {
+		link := filepath.Join(e.WorkPath, "link")
+		if err := os.Symlink(outside, link); err != nil {
+			t.Skipf("symlink not supported: %v", err)
+		}
+		if _, err := ResolvePath(e, "link/secret.txt"); err == nil {
+			t.Fatal("expected error for symlink pointing outside")
+		}
+		if _, err := ResolvePath(e, "link/new.txt"); err == nil {
+			t.Fatal("expected error for new file under symlink pointing outside")
+		}
+	})
+
+	t.Run("extra allowed root", func(t *testing.T) {
+		e2 := &toolTypes.Executor{
+			WorkPath: e.WorkPath,
+			Allowed:  []string{e.WorkPath, outside},
+		}
+		got, err := read(e2, filepath.Join(outside, "secret.txt"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "secret" {
+			t.Errorf("read() = %q, want %q", got, "secret")
+		}
+	})
+}
+
+func TestFileTools_RejectOutsidePaths(t *testing.T) {
+	e := newExec(t)
+	outside := t.TempDir()
+	target := filepath.Join(outside, "target.txt")
+	os.WriteFile(target, []byte("data"), 0644)
+
+	if _, err := read(e, target); err == nil {
+		t.Error("read: expected error for outside path")
+	}
+	if _, err := write(e, target, "overwrite"); err == nil {
+		t.Error("write: expected error for outside path")
+	}
+	if _, err := patch(e, target, "data", "new"); err == nil {
+		t.Error("patch: expected error for outside path")
+	}
+	if _, err := list(e, outside, false); err == nil {
+		t.Error("list: expected error for outside path")
+	}
+
+	data, _ := os.ReadFile(target)
+	if string(data) != "data" {
+		t.Errorf("outside file was modified: %q", data)
+	}
+}
+
+func TestSearch_SkipsSymlinkOutside(t *testing.T) {
+	e := newExec(t)
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "leak.txt"), []byte("needle outside"), 0644)
+	if err := os.Symlink(filepath.Join(outside, "leak.txt"), filepath.Join(e.WorkPath, "leak.txt")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	writeTemp(t, e, "ok.txt", "needle inside")
+
+	got, err := search(e, "needle", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(got, "outside") {
+		t.Errorf("symlinked file outside work path should be skipped, got: %s", got)
+	}
+	if !strings.Contains(got, "inside") {
+		t.Errorf("expected inside match, got: %s", got)
+	}
+}
+
+// ---------- ListAllowed ----------
+
+func TestListAllowed(t *testing.T) {
+	dir := t.TempDir()
+	home := t.TempDir()
+	if got := ListAllowed(dir, home); len(got) != 1 || got[0] != dir {
+		t.Fatalf("ListAllowed without config = %v, want [%s]", got, dir)
+	}
+
+	// * the project config is inside the sandbox and never widens it
+	projectConfig := filepath.Join(dir, ".config", "agenvoy")
+	os.MkdirAll(projectConfig, 0755)
+	os.WriteFile(filepath.Join(projectConfig, "config.json"), []byte(`{"allowed_paths": ["/"]}`), 0644)
+	if got := ListAllowed(dir, home); len(got) != 1 {
+		t.Fatalf("ListAllowed read the project config: %v", got)
+	}
+
+	os.WriteFile(filepath.Join(home, "config.json"),
+		[]byte(`{"allowed_paths": ["../shared", "/opt/data", ""]}`), 0644)
+	got := ListAllowed(dir, home)
+	want := []string{dir, filepath.Join(filepath.Dir(dir), "shared"), "/opt/data"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ListAllowed() = %v, want %v", got, want)
+	}
+
+	// * per project lists only apply to their own work path
+	data, _ := json.Marshal(map[string]any{"allowed_paths": map[string][]string{
+		dir:          {"../shared"},
+		"/elsewhere": {"/"},
+	}})
+	os.WriteFile(filepath.Join(home, "config.json"), data, 0644)
+	got = ListAllowed(dir, home)
+	want = []string{dir, filepath.Join(filepath.Dir(dir), "shared")}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ListAllowed() by project = %v, want %v", got, want)
+	}
+}
+
+func TestResolveWritePath_ConfigFolder(t *testing.T) {
+	e := newExec(t)
+	writeTemp(t, e, ".config/agenvoy/config.json", "{}")
+	writeTemp(t, e, ".config/other/settings.json", "{}")
+
+	if _, err := write(e, ".config/agenvoy/config.json", `{"allowed_paths": ["/"]}`); !errors.Is(err, ErrConfigPath) {
+		t.Errorf("write = %v, want ErrConfigPath", err)
+	}
+	if _, err := write(e, ".config/agenvoy/mcp.json", `{}`); !errors.Is(err, ErrConfigPath) {
+		t.Errorf("write new file = %v, want ErrConfigPath", err)
+	}
+	if _, err := patch(e, ".config/agenvoy/config.json", "{}", `{"allowed_paths": ["/"]}`); !errors.Is(err, ErrConfigPath) {
+		t.Errorf("patch = %v, want ErrConfigPath", err)
+	}
+	if _, err := write(e, ".config/other/settings.json", `{"a": 1}`); err != nil {
+		t.Errorf("write beside the config folder: %v", err)
+	}
+	// * reading stays allowed
+	if _, err := ResolvePath(e, ".config/agenvoy/config.json"); err != nil {
+		t.Errorf("ResolvePath = %v", err)
+	}
+
+	link := filepath.Join(e.WorkPath, "cfg")
+	if err := os.Symlink(filepath.Join(e.WorkPath, ".config", "agenvoy"), link); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	if _, err := write(e, "cfg/config.json", `{}`); !errors.Is(err, ErrConfigPath) {
+		t.Errorf("write through symlink = %v, want ErrConfigPath", err)
+	}
+}
@@ -11,10 +11,12 @@ import (
 )
 
 func list(e *toolTypes.Executor, path string, recursive bool) (string, error) {
-	fullPath := getFullPath(e, path)
+	fullPath, err := ResolvePath(e, path)
+	if err != nil {
+		return "", fmt.Errorf("list files — %w", err)
+	}
 
 	var files []string
-	var err error
 	if recursive {
 		files, err = walkFiles(e, fullPath)
 	} else {
@@ -9,7 +9,10 @@ import (
 )
 
 func patch(e *toolTypes.Executor, path, oldString, newString string) (string, error) {
-	fullPath := getFullPath(e, path)
+	fullPath, err := ResolveWritePath(e, path)
+	if err != nil {
+		return "", err
+	}
 
 	if isExclude(e, fullPath) {
 		return "", fmt.Errorf("path is excluded: %s", path)
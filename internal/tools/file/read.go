@@ -10,7 +10,10 @@ import (
 )
 
 func read(e *toolTypes.Executor, path string) (string, error) {
-	fullPath := getFullPath(e, path)
+	fullPath, err := ResolvePath(e, path)
+	if err != nil {
+		return "", err
+	}
 
 	if isExclude(e, fullPath) {
 		return "", fmt.Errorf("path is excluded: %s", path)
@@ -1,83 +0,0 @@
-package file
-
-import (
-	"encoding/json"
-	"fmt"
-
-	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
-)
-
-func Routes(e *toolTypes.Executor, name string, args json.RawMessage) (string, error) {
-	switch name {
-	case "read_file":
-		var params struct {
-			Path string `json:"path"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return read(e, params.Path)
-
-	case "list_files":
-		var params struct {
-			Path      string `json:"path"`
-			Recursive bool   `json:"recursive"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return list(e, params.Path, params.Recursive)
-
-	case "glob_files":
-		var params struct {
-			Pattern string `json:"pattern"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return glob(e, params.Pattern)
-
-	case "search_content":
-		var params struct {
-			Pattern     string `json:"pattern"`
-			FilePattern string `json:"file_pattern"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return search(e, params.Pattern, params.FilePattern)
-
-	case "search_history":
-		var params struct {
-			Keyword   string `json:"keyword"`
-			TimeRange string `json:"time_range"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return searchHistory(e.SessionID, params.Keyword, params.TimeRange)
-
-	case "write_file":
-		var params struct {
-			Path    string `json:"path"`
-			Content string `json:"content"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return write(e, params.Path, params.Content)
-
-	case "patch_edit":
-		var params struct {
-			Path      string `json:"path"`
-			OldString string `json:"old_string"`
-			NewString string `json:"new_string"`
-		}
-		if err := json.Unmarshal(args, &params); err != nil {
-			return "", fmt.Errorf("json.Unmarshal: %w", err)
-		}
-		return patch(e, params.Path, params.OldString, params.NewString)
-	default:
-		return "", fmt.Errorf("unknown tool: %s", name)
-	}
-}
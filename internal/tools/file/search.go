@@ -59,6 +59,11 @@ func search(e *toolTypes.Executor, pattern, filePattern string) (string, error)
 			}
 		}
 
+		// * symlinked file may point outside allowed folders
+		if _, err := ResolvePath(e, path); err != nil {
+			return nil
+		}
+
 		data, err := os.ReadFile(path)
 		if err != nil {
 			slog.Warn("failed to read file during search",
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
 	"github.com/pardnchiu/agenvoy/internal/utils"
 )
 
@@ -40,21 +41,58 @@ func extractSec(content string) (int64, string) {
 	return ts, rest[idx+1:]
 }
 
-func searchHistory(sessionID, keyword, timeRange string) (string, error) {
-	const limit = 10
+const (
+	historyLimit = 10
+	// * the latest entries are already in context
+	historySkip = 4
+)
+
+// * store index when the session store has one, otherwise a scan of history.json
+func searchSessionHistory(e *toolTypes.Executor, keyword, timeRange string) (string, error) {
+	if e.SearchHistory == nil {
+		return searchHistory(e.SessionDir, e.SessionID, keyword, timeRange)
+	}
 	if keyword == "" {
 		return "", fmt.Errorf("keyword is required")
 	}
-	if sessionID == "" {
+	if e.SessionID == "" {
 		return "", fmt.Errorf("sessionID is required")
 	}
 
-	configDir, err := utils.GetConfigDir("sessions")
+	var after time.Time
+	if d, ok := historyTimeRanges[timeRange]; ok {
+		after = time.Now().Add(-d)
+	}
+	found, err := e.SearchHistory(e.SessionID, keyword, after, historySkip, historyLimit)
 	if err != nil {
-		return "", fmt.Errorf("utils.ConfigDir: %w", err)
+		return "", fmt.Errorf("e.SearchHistory: %w", err)
 	}
 
-	historyPath := filepath.Join(configDir.Home, sessionID, "history.json")
+	matches := make([]historyEntry, 0, len(found))
+	for _, entry := range found {
+		matches = append(matches, historyEntry{Role: entry.Role, Content: entry.Content})
+	}
+	return formatHistory(keyword, matches), nil
+}
+
+// * sessionDir empty falls back to ~/.config/agenvoy/sessions
+func searchHistory(sessionDir, sessionID, keyword, timeRange string) (string, error) {
+	if keyword == "" {
+		return "", fmt.Errorf("keyword is required")
+	}
+	if sessionID == "" {
+		return "", fmt.Errorf("sessionID is required")
+	}
+
+	if sessionDir == "" {
+		configDir, err := utils.GetConfigDir("sessions")
+		if err != nil {
+			return "", fmt.Errorf("utils.ConfigDir: %w", err)
+		}
+		sessionDir = configDir.Home
+	}
+
+	historyPath := filepath.Join(sessionDir, sessionID, "history.json")
 
 	data, err := os.ReadFile(historyPath)
 	if err != nil {
@@ -77,7 +115,7 @@ func searchHistory(sessionID, keyword, timeRange string) (string, error) {
 	lower := strings.ToLower(keyword)
 	var matches []historyEntry
 
-	for i := len(entries) - 5; i >= 0; i-- {
+	for i := len(entries) - historySkip - 1; i >= 0; i-- {
 		entry := entries[i]
 		ts, body := extractSec(entry.Content)
 		if after > 0 && ts > 0 && ts < after {
@@ -85,19 +123,23 @@ func searchHistory(sessionID, keyword, timeRange string) (string, error) {
 		}
 		if strings.Contains(strings.ToLower(body), lower) {
 			matches = append(matches, entry)
-			if len(matches) >= limit {
+			if len(matches) >= historyLimit {
 				break
 			}
 		}
 	}
 
+	return formatHistory(keyword, matches), nil
+}
+
+func formatHistory(keyword string, matches []historyEntry) string {
 	if len(matches) == 0 {
-		return fmt.Sprintf("No matches found for keyword: %s", keyword), nil
+		return fmt.Sprintf("No matches found for keyword: %s", keyword)
 	}
 
 	var result strings.Builder
 	for _, m := range matches {
 		result.WriteString(fmt.Sprintf("[%s] %s\n", m.Role, m.Content))
 	}
-	return result.String(), nil
+	return result.String()
 }
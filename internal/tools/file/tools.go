@@ -0,0 +1,114 @@
+package file
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+//go:embed embed/tools.json
+var toolsJSON []byte
+
+func Tools() ([]toolTypes.Tool, error) {
+	return toolTypes.Bind(toolsJSON, map[string]toolTypes.Binding{
+		"read_file": {
+			Flags: toolTypes.FlagReadOnly,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Path string `json:"path"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return read(e, params.Path)
+			},
+		},
+
+		"list_files": {
+			Flags: toolTypes.FlagReadOnly,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Path      string `json:"path"`
+					Recursive bool   `json:"recursive"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return list(e, params.Path, params.Recursive)
+			},
+		},
+
+		"glob_files": {
+			Flags: toolTypes.FlagReadOnly,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Pattern string `json:"pattern"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return glob(e, params.Pattern)
+			},
+		},
+
+		"search_content": {
+			Flags: toolTypes.FlagReadOnly,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Pattern     string `json:"pattern"`
+					FilePattern string `json:"file_pattern"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return search(e, params.Pattern, params.FilePattern)
+			},
+		},
+
+		"search_history": {
+			Flags: toolTypes.FlagReadOnly,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Keyword   string `json:"keyword"`
+					TimeRange string `json:"time_range"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return searchSessionHistory(e, params.Keyword, params.TimeRange)
+			},
+		},
+
+		"write_file": {
+			Flags: 0,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Path    string `json:"path"`
+					Content string `json:"content"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return write(e, params.Path, params.Content)
+			},
+		},
+
+		"patch_edit": {
+			Flags: 0,
+			Handler: func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+				var params struct {
+					Path      string `json:"path"`
+					OldString string `json:"old_string"`
+					NewString string `json:"new_string"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return "", fmt.Errorf("json.Unmarshal: %w", err)
+				}
+				return patch(e, params.Path, params.OldString, params.NewString)
+			},
+		},
+	})
+}
@@ -0,0 +1,222 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pardnchiu/agenvoy/internal/mcp"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"github.com/pardnchiu/agenvoy/internal/utils"
+)
+
+const (
+	mcpPrefix      = "mcp_"
+	maxToolNameLen = 64
+)
+
+var (
+	invalidToolName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+	// * servers stay connected for the whole process, keyed by config dirs
+	mcpMu    sync.Mutex
+	mcpCache = make(map[string]*mcpEntry)
+
+	// * mcpMu is held while listing, so a stalled server must not block it; replaced in tests
+	mcpListTimeout = mcp.ConnectTimeout
+)
+
+type mcpEntry struct {
+	clients []*mcp.Client
+	tools   []toolTypes.Tool
+}
+
+// * connect servers from mcp.json once and mount their tools as mcp_{server}_{tool}
+func mcpTools(configDir *utils.ConfigDirData) []toolTypes.Tool {
+	key := strings.Join(configDir.Dirs, "\x00")
+
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+
+	if entry, ok := mcpCache[key]; ok {
+		return entry.tools
+	}
+
+	servers := mcp.LoadTrusted(configDir.Home, configDir.Work)
+	entry := &mcpEntry{}
+	if len(servers) > 0 {
+		entry.clients, entry.tools = bridgeAll(mcp.ConnectAll(context.Background(), servers), servers)
+	}
+	mcpCache[key] = entry
+	return entry.tools
+}
+
+// * close servers started for the given config dirs, all of them when none given
+func CloseMCP(configDirs ...*utils.ConfigDirData) {
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+
+	keys := make(map[string]bool, len(configDirs))
+	for _, configDir := range configDirs {
+		keys[strings.Join(configDir.Dirs, "\x00")] = true
+	}
+
+	for key, entry := range mcpCache {
+		if len(keys) > 0 && !keys[key] {
+			continue
+		}
+		for _, client := range entry.clients {
+			_ = client.Close()
+		}
+		delete(mcpCache, key)
+	}
+}
+
+// * lists the tools of each client within mcpListTimeout; a server that fails or stalls
+// * is closed and skipped with a warning, like a failed connect
+func bridgeAll(clients []*mcp.Client, servers map[string]mcp.ServerConfig) ([]*mcp.Client, []toolTypes.Tool) {
+	var kept []*mcp.Client
+	var tools []toolTypes.Tool
+	for _, client := range clients {
+		ctx, cancel := context.WithTimeout(context.Background(), mcpListTimeout)
+		list, err := bridgeMCP(ctx, client, servers[client.Name])
+		cancel()
+		if err != nil {
+			slog.Warn("failed to list mcp tools",
+				slog.String("name", client.Name),
+				slog.String("error", err.Error()))
+			_ = client.Close()
+			continue
+		}
+		kept = append(kept, client)
+		tools = append(tools, list...)
+	}
+	return kept, tools
+}
+
+func bridgeMCP(ctx context.Context, client *mcp.Client, cfg mcp.ServerConfig) ([]toolTypes.Tool, error) {
+	var base toolTypes.ToolFlag
+	if cfg.URL != "" {
+		base |= toolTypes.FlagNetwork
+	}
+
+	var result []toolTypes.Tool
+	if client.Info.Capabilities.Tools != nil {
+		list, err := client.ListTools(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("client.ListTools: %w", err)
+		}
+		for _, tool := range list {
+			result = append(result, newMCPTool(client, tool, base))
+		}
+	}
+
+	if client.Info.Capabilities.Resources != nil {
+		resources, err := client.ListResources(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil, fmt.Errorf("client.ListResources: %w", ctx.Err())
+		case err == nil && len(resources) > 0:
+			result = append(result, newMCPResourceTool(client, resources, base))
+		}
+	}
+	return result, nil
+}
+
+// * names over the limit keep a hash of the full name, so two long names sharing a prefix stay apart
+func mcpToolName(server, tool string) string {
+	name := mcpPrefix + invalidToolName.ReplaceAllString(server, "_") + "_" + invalidToolName.ReplaceAllString(tool, "_")
+	if len(name) > maxToolNameLen {
+		sum := fmt.Sprintf("%x", sha256.Sum256([]byte(server+"\x00"+tool)))[:8]
+		name = name[:maxToolNameLen-len(sum)-1] + "_" + sum
+	}
+	return name
+}
+
+// * annotations come from the server itself, readOnlyHint only lets the tool run concurrently
+// * and every mcp tool still asks for confirmation
+func newMCPTool(client *mcp.Client, tool mcp.Tool, flags toolTypes.ToolFlag) toolTypes.Tool {
+	flags |= toolTypes.FlagConfirm
+	if a := tool.Annotations; a != nil {
+		if a.ReadOnlyHint != nil && *a.ReadOnlyHint {
+			flags |= toolTypes.FlagReadOnly
+		}
+		if a.OpenWorldHint != nil && *a.OpenWorldHint {
+			flags |= toolTypes.FlagNetwork
+		}
+	}
+
+	def := toolTypes.ToolDef{
+		Type: "function",
+		Function: toolTypes.ToolFunction{
+			Name:        mcpToolName(client.Name, tool.Name),
+			Description: fmt.Sprintf("[%s] %s", client.Name, tool.Description),
+			Parameters:  tool.InputSchema,
+		},
+	}
+
+	name := tool.Name
+	return toolTypes.NewTool(def, flags, func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+		result, err := client.CallTool(ctx, name, args)
+		if err != nil {
+			return "", fmt.Errorf("client.CallTool: %w", err)
+		}
+		if result.IsError {
+			return "Error: " + result.Text(), nil
+		}
+		return result.Text(), nil
+	})
+}
+
+func newMCPResourceTool(client *mcp.Client, resources []mcp.Resource, flags toolTypes.ToolFlag) toolTypes.Tool {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] Read a resource by uri. Available resources:", client.Name)
+	for i, r := range resources {
+		if i >= 50 {
+			fmt.Fprintf(&sb, "\n- … %d more", len(resources)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "\n- %s", r.URI)
+		if r.Description != "" {
+			fmt.Fprintf(&sb, ": %s", r.Description)
+		} else if r.Name != "" {
+			fmt.Fprintf(&sb, ": %s", r.Name)
+		}
+	}
+
+	def := toolTypes.ToolDef{
+		Type: "function",
+		Function: toolTypes.ToolFunction{
+			Name:        mcpToolName(client.Name, "read_resource"),
+			Description: sb.String(),
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"uri":{"type":"string","description":"resource uri"}},"required":["uri"]}`),
+		},
+	}
+
+	return toolTypes.NewTool(def, flags|toolTypes.FlagReadOnly|toolTypes.FlagConfirm, func(ctx context.Context, e *toolTypes.Executor, args json.RawMessage) (string, error) {
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		result, err := client.ReadResource(ctx, params.URI)
+		if err != nil {
+			return "", fmt.Errorf("client.ReadResource: %w", err)
+		}
+		parts := make([]string, 0, len(result.Contents))
+		for _, c := range result.Contents {
+			if c.Text != "" {
+				parts = append(parts, c.Text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[%s %s]", c.URI, c.MimeType))
+			}
+		}
+		return strings.Join(parts, "\n"), nil
+	})
+}
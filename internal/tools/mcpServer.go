@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pardnchiu/agenvoy/internal/mcp"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+// * need a chat session, which an mcp host does not have
+var mcpServerSkip = map[string]bool{
+	"search_history": true,
+}
+
+// * publish every tool of the executor on s, flags become annotations for the host
+func ServeTools(s *mcp.Server, e *toolTypes.Executor) {
+	for _, def := range e.Registry.Definitions() {
+		name := def.Function.Name
+		if mcpServerSkip[name] {
+			continue
+		}
+		flags, _ := e.Registry.Flags(name)
+
+		s.AddTool(mcp.Tool{
+			Name:        name,
+			Description: def.Function.Description,
+			InputSchema: def.Function.Parameters,
+			Annotations: mcpAnnotations(flags),
+		}, func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+			if len(args) == 0 {
+				args = json.RawMessage(`{}`)
+			}
+			text, err := Execute(ctx, e, name, args)
+			if err != nil {
+				return nil, err
+			}
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{{Type: "text", Text: text}},
+			}, nil
+		})
+	}
+}
+
+func mcpAnnotations(flags toolTypes.ToolFlag) *mcp.ToolAnnotations {
+	readOnly := flags.Has(toolTypes.FlagReadOnly)
+	openWorld := flags.Has(toolTypes.FlagNetwork)
+	annotations := &mcp.ToolAnnotations{
+		ReadOnlyHint:  &readOnly,
+		OpenWorldHint: &openWorld,
+	}
+	if !readOnly {
+		destructive := true
+		annotations.DestructiveHint = &destructive
+	}
+	return annotations
+}
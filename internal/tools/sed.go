@@ -0,0 +1,270 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pardnchiu/agenvoy/internal/tools/file"
+	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+)
+
+// * sed can run commands with e and write files with w, W or -i, the name check alone misses that
+func checkSed(e *toolTypes.Executor, args []string) error {
+	var scripts, operands []string
+	inPlace := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg, "=")
+			switch name {
+			// * a script file cannot be checked
+			case "--file":
+				return fmt.Errorf("sed %s is not allowed", name)
+			case "--expression":
+				if !hasValue {
+					if i++; i == len(args) {
+						return fmt.Errorf("sed %s needs a script", name)
+					}
+					value = args[i]
+				}
+				scripts = append(scripts, value)
+			case "--in-place":
+				inPlace = true
+			case "--line-length":
+				if !hasValue {
+					i++
+				}
+			}
+
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			// * clustered short options, ex. -ne 'p' or -i.bak
+			for j := 1; j < len(arg); j++ {
+				switch arg[j] {
+				case 'f':
+					return fmt.Errorf("sed -f is not allowed")
+				case 'e', 'l':
+					value := arg[j+1:]
+					if value == "" {
+						if i++; i == len(args) {
+							return fmt.Errorf("sed -%c needs a value", arg[j])
+						}
+						value = args[i]
+					}
+					if arg[j] == 'e' {
+						scripts = append(scripts, value)
+					}
+					j = len(arg)
+				case 'i':
+					// * the rest is the backup suffix
+					inPlace = true
+					j = len(arg)
+				}
+			}
+
+		default:
+			operands = append(operands, arg)
+		}
+	}
+
+	// * without -e the first operand is the script
+	if len(scripts) == 0 {
+		if len(operands) == 0 {
+			return nil
+		}
+		scripts, operands = operands[:1], operands[1:]
+	}
+	for _, script := range scripts {
+		if err := checkSedScript(script); err != nil {
+			return err
+		}
+	}
+
+	// * -i writes every input file, same check as write_file
+	if inPlace {
+		for _, path := range operands {
+			if strings.HasPrefix(path, "~") {
+				return fmt.Errorf("sed -i outside work path: %s", path)
+			}
+			if _, err := file.ResolveWritePath(e, path); err != nil {
+				if errors.Is(err, file.ErrConfigPath) {
+					return fmt.Errorf("sed -i inside the agenvoy config folder: %s", path)
+				}
+				return fmt.Errorf("sed -i outside work path: %s", path)
+			}
+		}
+	}
+	return nil
+}
+
+// * walks the commands of a script, anything it cannot follow is rejected rather than guessed
+func checkSedScript(script string) error {
+	s := sedScanner{text: script}
+	for {
+		s.skip(" \t\n;")
+		if s.done() {
+			return nil
+		}
+		if err := s.address(); err != nil {
+			return err
+		}
+		s.skip(" \t")
+		if s.peek() == '!' {
+			s.pos++
+			s.skip(" \t")
+		}
+		if s.done() {
+			return fmt.Errorf("sed script is missing a command: %s", script)
+		}
+
+		cmd := s.next()
+		switch cmd {
+		case '{', '}', '=', 'd', 'D', 'g', 'G', 'h', 'H', 'n', 'N', 'p', 'P', 'x', 'z', 'F':
+		case 'e':
+			return fmt.Errorf("sed e command is not allowed")
+		case 'w', 'W':
+			return fmt.Errorf("sed %c command is not allowed", cmd)
+		case '#', 'a', 'i', 'c', 'r', 'R':
+			// * comment, text or file name up to the end of the line
+			s.line()
+		case ':', 'b', 't', 'T', 'v':
+			// * label, ex. :a;N;$!ba
+			s.upTo("\n;")
+		case 'q', 'Q', 'l', 'L':
+			s.skip(" \t0123456789")
+		case 'y':
+			delim := s.next()
+			if err := s.part(delim); err != nil {
+				return err
+			}
+			if err := s.part(delim); err != nil {
+				return err
+			}
+		case 's':
+			delim := s.next()
+			if err := s.part(delim); err != nil {
+				return err
+			}
+			if err := s.part(delim); err != nil {
+				return err
+			}
+			for !s.done() && !strings.ContainsRune(" \t\n;}", rune(s.peek())) {
+				switch flag := s.next(); {
+				case flag == 'e':
+					return fmt.Errorf("sed s///e is not allowed")
+				case flag == 'w':
+					return fmt.Errorf("sed s///w is not allowed")
+				case !strings.ContainsRune("gpiImM0123456789", rune(flag)):
+					return fmt.Errorf("sed s flag %c cannot be checked", flag)
+				}
+			}
+		default:
+			return fmt.Errorf("sed command %c cannot be checked", cmd)
+		}
+	}
+}
+
+type sedScanner struct {
+	text string
+	pos  int
+}
+
+func (s *sedScanner) done() bool {
+	return s.pos >= len(s.text)
+}
+
+func (s *sedScanner) peek() byte {
+	if s.done() {
+		return 0
+	}
+	return s.text[s.pos]
+}
+
+func (s *sedScanner) next() byte {
+	c := s.peek()
+	s.pos++
+	return c
+}
+
+func (s *sedScanner) skip(chars string) {
+	for !s.done() && strings.IndexByte(chars, s.peek()) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *sedScanner) upTo(chars string) {
+	for !s.done() && strings.IndexByte(chars, s.peek()) < 0 {
+		s.pos++
+	}
+}
+
+// * to the end of the line, a trailing backslash continues it
+func (s *sedScanner) line() {
+	for !s.done() {
+		switch s.next() {
+		case '\\':
+			s.pos++
+		case '\n':
+			return
+		}
+	}
+}
+
+// * text up to an unescaped delim, ex. the regex or replacement of s
+func (s *sedScanner) part(delim byte) error {
+	if delim == 0 || delim == '\\' || delim == '\n' {
+		return fmt.Errorf("sed script has an invalid delimiter: %s", s.text)
+	}
+	for !s.done() {
+		switch s.next() {
+		case '\\':
+			s.pos++
+		case delim:
+			return nil
+		}
+	}
+	return fmt.Errorf("sed script is not terminated: %s", s.text)
+}
+
+// * line number, $, /regex/ or \cregexc, with ~step, and a second one after a comma
+func (s *sedScanner) address() error {
+	for range 2 {
+		switch c := s.peek(); {
+		case c == '$':
+			s.pos++
+		case c >= '0' && c <= '9':
+			s.skip("0123456789")
+			if s.peek() == '~' {
+				s.pos++
+				s.skip("0123456789")
+			}
+		case c == '/':
+			s.pos++
+			if err := s.part('/'); err != nil {
+				return err
+			}
+			s.skip("IM")
+		case c == '\\':
+			s.pos++
+			if err := s.part(s.next()); err != nil {
+				return err
+			}
+			s.skip("IM")
+		case c == '+' || c == '~':
+			s.pos++
+			s.skip("0123456789")
+		}
+		if s.peek() != ',' {
+			return nil
+		}
+		s.pos++
+		s.skip(" \t")
+	}
+	return nil
+}
@@ -2,19 +2,43 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/pardnchiu/agenvoy/internal/tools/file"
 	toolTypes "github.com/pardnchiu/agenvoy/internal/tools/types"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
 )
 
 var (
-// * template allow all for testing
-// disallowed = regexp.MustCompile(`[;&|` + "`" + `$(){}!<>\\]`)
+	// * redirect targets which are always safe to write
+	safeTargets = map[string]bool{
+		"/dev/null":   true,
+		"/dev/stdout": true,
+		"/dev/stderr": true,
+	}
+	// * find actions which run other commands, write or delete files
+	findActions = map[string]bool{
+		"-exec":    true,
+		"-execdir": true,
+		"-ok":      true,
+		"-okdir":   true,
+		"-delete":  true,
+		"-fls":     true,
+		"-fprint":  true,
+		"-fprint0": true,
+		"-fprintf": true,
+	}
+	awkSystem   = regexp.MustCompile(`\bsystem\s*\(`)
+	awkPipe     = regexp.MustCompile(`(^|[^|])\|($|[^|])`)
+	awkRedirect = regexp.MustCompile(`\bprintf?\b[^;{}]*>`)
 )
 
 func runCommand(ctx context.Context, e *toolTypes.Executor, command string) (string, error) {
@@ -23,35 +47,14 @@ func runCommand(ctx context.Context, e *toolTypes.Executor, command string) (str
 		return "", fmt.Errorf("failed to run command: command is empty")
 	}
 
-	// * template allow all for testing
-	// if disallowed.MatchString(command) {
-	// 	return "", fmt.Errorf("failed to run command: disallowed characters")
-	// }
-
-	hasShellOps := strings.ContainsAny(command, "|><&")
-
-	var binary string
-	var args []string
-
-	if hasShellOps {
-		binary = "sh"
-		args = []string{"-c", command}
-
-		firstCmd := strings.Fields(command)[0]
-		if !e.AllowedCommand[filepath.Base(firstCmd)] {
-			return "", fmt.Errorf("failed to run command: %s is not allowed", firstCmd)
-		}
-	} else {
-		args = strings.Fields(command)
-		binary = filepath.Base(args[0])
-
-		if !e.AllowedCommand[binary] {
-			return "", fmt.Errorf("failed to run command: %s is not allowed", binary)
-		}
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return "", fmt.Errorf("failed to run command: invalid syntax: %w", err)
+	}
 
-		if binary == "rm" {
-			return moveToTrash(ctx, e, args[1:])
-		}
+	// * check everything before running anything
+	if err := checkCommand(e, file); err != nil {
+		return "", fmt.Errorf("failed to run command: %w", err)
 	}
 
 	// TODO: need to change to dynamic timeout based on command complexity
@@ -59,10 +62,13 @@ func runCommand(ctx context.Context, e *toolTypes.Executor, command string) (str
 	defer cancel()
 
 	var cmd *exec.Cmd
-	if hasShellOps {
-		cmd = exec.CommandContext(ctx, binary, args...)
-	} else {
+	if args, ok := simpleArgs(file); ok {
+		if args[0] == "rm" {
+			return moveToTrash(ctx, e, args[1:])
+		}
 		cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
 	}
 	cmd.Dir = e.WorkPath
 
@@ -74,6 +80,226 @@ func runCommand(ctx context.Context, e *toolTypes.Executor, command string) (str
 	return string(output), nil
 }
 
+// * walk every command in pipelines, lists, subshells and substitutions
+func checkCommand(e *toolTypes.Executor, file *syntax.File) error {
+	_, simple := simpleArgs(file)
+
+	var err error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if err != nil {
+			return false
+		}
+
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			// * assignment only, ex. FOO=bar
+			if len(n.Args) == 0 {
+				return true
+			}
+			name, ok := literalWord(n.Args[0])
+			if !ok {
+				err = fmt.Errorf("command name must be a literal: %s", wordString(n.Args[0]))
+				return false
+			}
+			if strings.Contains(name, "/") {
+				err = fmt.Errorf("command must be a bare name, not a path: %s", name)
+				return false
+			}
+			if !e.AllowedCommand[name] {
+				err = fmt.Errorf("%s is not allowed", name)
+				return false
+			}
+			if argsErr := checkArgs(e, name, n.Args[1:]); argsErr != nil {
+				err = argsErr
+				return false
+			}
+			// * rm is only redirected to .Trash when run on its own
+			if name == "rm" && !simple {
+				err = fmt.Errorf("rm is only allowed as a standalone command")
+				return false
+			}
+
+		case *syntax.Redirect:
+			if redirectErr := checkRedirect(e, n); redirectErr != nil {
+				err = redirectErr
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// * find, awk and sed can run commands or write files by themselves, the name check alone misses that
+func checkArgs(e *toolTypes.Executor, name string, words []*syntax.Word) error {
+	if name != "find" && name != "awk" && name != "sed" {
+		return nil
+	}
+
+	if name == "sed" {
+		args := make([]string, 0, len(words))
+		for _, word := range words {
+			arg, ok := literalWord(word)
+			if !ok {
+				return fmt.Errorf("sed arguments must be literals: %s", wordString(word))
+			}
+			args = append(args, arg)
+		}
+		return checkSed(e, args)
+	}
+
+	skipNext := false
+	for _, word := range words {
+		arg, ok := literalWord(word)
+		if !ok {
+			return fmt.Errorf("%s arguments must be literals: %s", name, wordString(word))
+		}
+		if skipNext {
+			skipNext = false
+			continue
+		}
+
+		if name == "find" {
+			if findActions[arg] {
+				return fmt.Errorf("find %s is not allowed", arg)
+			}
+			continue
+		}
+
+		switch {
+		// * field separator and variable values are data, not program
+		case arg == "-F" || arg == "-v":
+			skipNext = true
+		case strings.HasPrefix(arg, "-F") || strings.HasPrefix(arg, "-v") ||
+			strings.HasPrefix(arg, "--field-separator") || strings.HasPrefix(arg, "--assign"):
+		// * a program file cannot be checked
+		case strings.HasPrefix(arg, "-f") || strings.HasPrefix(arg, "--file") ||
+			strings.HasPrefix(arg, "-E") || strings.HasPrefix(arg, "--exec"):
+			return fmt.Errorf("awk %s is not allowed", arg)
+		case awkSystem.MatchString(arg):
+			return fmt.Errorf("awk system() is not allowed")
+		case awkPipe.MatchString(arg):
+			return fmt.Errorf("awk pipes are not allowed")
+		case awkRedirect.MatchString(arg):
+			return fmt.Errorf("awk output redirection is not allowed")
+		}
+	}
+	return nil
+}
+
+func checkRedirect(e *toolTypes.Executor, redirect *syntax.Redirect) error {
+	switch redirect.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrInOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll, syntax.DplOut:
+	default:
+		return nil
+	}
+
+	target, ok := literalWord(redirect.Word)
+	if !ok {
+		return fmt.Errorf("redirect target must be a literal: %s", wordString(redirect.Word))
+	}
+
+	// * >&2 or >&- only duplicate / close file descriptor
+	if redirect.Op == syntax.DplOut && (target == "-" || isDigits(target)) {
+		return nil
+	}
+
+	if safeTargets[target] {
+		return nil
+	}
+
+	if strings.HasPrefix(target, "~") {
+		return fmt.Errorf("redirect outside work path: %s", target)
+	}
+
+	// * same check as write_file, symlinks inside the work path cannot lead out of it
+	if _, err := file.ResolveWritePath(e, target); err != nil {
+		if errors.Is(err, file.ErrConfigPath) {
+			return fmt.Errorf("redirect inside the agenvoy config folder: %s", target)
+		}
+		return fmt.Errorf("redirect outside work path: %s", target)
+	}
+	return nil
+}
+
+// * single command without redirects, expansions or globs, can run without sh
+func simpleArgs(file *syntax.File) ([]string, bool) {
+	if len(file.Stmts) != 1 {
+		return nil, false
+	}
+	stmt := file.Stmts[0]
+	if stmt.Negated || stmt.Background || stmt.Coprocess || len(stmt.Redirs) > 0 {
+		return nil, false
+	}
+
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Assigns) > 0 || len(call.Args) == 0 {
+		return nil, false
+	}
+
+	args := make([]string, 0, len(call.Args))
+	for _, word := range call.Args {
+		value, ok := literalWord(word)
+		if !ok {
+			return nil, false
+		}
+		// * unquoted glob or tilde need the shell to expand
+		if lit := word.Lit(); lit != "" && (strings.ContainsAny(lit, "*?[") || strings.HasPrefix(lit, "~")) {
+			return nil, false
+		}
+		args = append(args, value)
+	}
+	return args, true
+}
+
+// * word without parameter, command, arithmetic or process expansion
+func literalWord(word *syntax.Word) (string, bool) {
+	if word == nil {
+		return "", false
+	}
+
+	literal := true
+	syntax.Walk(word, func(node syntax.Node) bool {
+		switch node.(type) {
+		case *syntax.ParamExp, *syntax.CmdSubst, *syntax.ArithmExp, *syntax.ProcSubst, *syntax.ExtGlob:
+			literal = false
+		}
+		return literal
+	})
+	if !literal {
+		return "", false
+	}
+
+	fields, err := expand.Fields(nil, word)
+	if err != nil || len(fields) != 1 {
+		return "", false
+	}
+	return fields[0], true
+}
+
+func wordString(word *syntax.Word) string {
+	if word == nil {
+		return ""
+	}
+	var sb strings.Builder
+	if err := syntax.NewPrinter().Print(&sb, word); err != nil {
+		return ""
+	}
+	return sb.String()
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func moveToTrash(ctx context.Context, e *toolTypes.Executor, args []string) (string, error) {
 	trashPath := filepath.Join(e.WorkPath, ".Trash")
 	if err := os.MkdirAll(trashPath, 0755); err != nil {
@@ -2,6 +2,7 @@ package toolTypes
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/pardnchiu/agenvoy/internal/tools/apiAdapter"
 )
@@ -9,19 +10,31 @@ import (
 type Executor struct {
 	WorkPath       string
 	SessionID      string
-	Allowed        []string // limit to these folders to use
+	SessionDir     string        // root of session folders, ex. ~/.config/agenvoy/sessions
+	SearchHistory  HistorySearch // nil scans {SessionDir}/{SessionID}/history.json
+	Allowed        []string      // limit to these folders to use
 	AllowedCommand map[string]bool
 	Exclude        []Exclude
-	Tools          []Tool
+	Registry       *Registry
 	APIToolbox     *apiAdapter.Translator
 }
 
+// * indexed history search of the session store, newest first;
+// * the last skip entries are already in context, zero after means no time filter
+type HistorySearch func(sessionID, keyword string, after time.Time, skip, limit int) ([]HistoryEntry, error)
+
+type HistoryEntry struct {
+	Role    string
+	Content string
+}
+
 type Exclude struct {
 	File   string
 	Negate bool
 }
 
-type Tool struct {
+// * schema sent to the model
+type ToolDef struct {
 	Type     string       `json:"type"`
 	Function ToolFunction `json:"function"`
 }
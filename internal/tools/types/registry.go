@@ -0,0 +1,91 @@
+package toolTypes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		tools: make(map[string]Tool),
+	}
+}
+
+func (r *Registry) Register(tools ...Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tool := range tools {
+		name := tool.Name()
+		if name == "" {
+			return fmt.Errorf("tool name is required")
+		}
+		if _, ok := r.tools[name]; ok {
+			return fmt.Errorf("tool already exists: %s", name)
+		}
+		r.tools[name] = tool
+		r.order = append(r.order, name)
+	}
+	return nil
+}
+
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// * drops every tool keep says no to, ex. outside a skill's allowed-tools
+func (r *Registry) Filter(keep func(name string) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.order[:0]
+	for _, name := range r.order {
+		if keep(name) {
+			order = append(order, name)
+		} else {
+			delete(r.tools, name)
+		}
+	}
+	r.order = order
+}
+
+// * in registration order
+func (r *Registry) Definitions() []ToolDef {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]ToolDef, 0, len(r.order))
+	for _, name := range r.order {
+		defs = append(defs, r.tools[name].Definition())
+	}
+	return defs
+}
+
+// * unknown tools are treated as unsafe
+func (r *Registry) Flags(name string) (ToolFlag, bool) {
+	tool, ok := r.Get(name)
+	if !ok {
+		return 0, false
+	}
+	return tool.Flags(), true
+}
+
+func (r *Registry) Execute(ctx context.Context, e *Executor, name string, args json.RawMessage) (string, error) {
+	tool, ok := r.Get(name)
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	return tool.Execute(ctx, e, args)
+}
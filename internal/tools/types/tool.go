@@ -0,0 +1,103 @@
+package toolTypes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type ToolFlag uint8
+
+const (
+	// * no side effect on the work path
+	FlagReadOnly ToolFlag = 1 << iota
+	// * talk to remote services
+	FlagNetwork
+	// * always ask the user, even when read-only
+	FlagConfirm
+)
+
+func (f ToolFlag) Has(flag ToolFlag) bool {
+	return f&flag != 0
+}
+
+// * read-only and network tools can run at the same time
+func (f ToolFlag) Concurrent() bool {
+	return f.Has(FlagReadOnly) || f.Has(FlagNetwork)
+}
+
+// * only local read-only tools skip the confirm prompt
+func (f ToolFlag) NeedsConfirm() bool {
+	return f.Has(FlagConfirm) || f.Has(FlagNetwork) || !f.Has(FlagReadOnly)
+}
+
+type Handler func(ctx context.Context, e *Executor, args json.RawMessage) (string, error)
+
+type Tool interface {
+	Name() string
+	Definition() ToolDef
+	Flags() ToolFlag
+	Execute(ctx context.Context, e *Executor, args json.RawMessage) (string, error)
+}
+
+type funcTool struct {
+	def     ToolDef
+	flags   ToolFlag
+	handler Handler
+}
+
+func NewTool(def ToolDef, flags ToolFlag, handler Handler) Tool {
+	if def.Type == "" {
+		def.Type = "function"
+	}
+	if len(def.Function.Parameters) == 0 {
+		def.Function.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return &funcTool{
+		def:     def,
+		flags:   flags,
+		handler: handler,
+	}
+}
+
+func (t *funcTool) Name() string {
+	return t.def.Function.Name
+}
+
+func (t *funcTool) Definition() ToolDef {
+	return t.def
+}
+
+func (t *funcTool) Flags() ToolFlag {
+	return t.flags
+}
+
+func (t *funcTool) Execute(ctx context.Context, e *Executor, args json.RawMessage) (string, error) {
+	return t.handler(ctx, e, args)
+}
+
+type Binding struct {
+	Flags   ToolFlag
+	Handler Handler
+}
+
+// * pair embedded schemas with their handlers, both sides must match
+func Bind(schemas []byte, bindings map[string]Binding) ([]Tool, error) {
+	var defs []ToolDef
+	if err := json.Unmarshal(schemas, &defs); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	tools := make([]Tool, 0, len(defs))
+	for _, def := range defs {
+		binding, ok := bindings[def.Function.Name]
+		if !ok || binding.Handler == nil {
+			return nil, fmt.Errorf("no handler for tool: %s", def.Function.Name)
+		}
+		tools = append(tools, NewTool(def, binding.Flags, binding.Handler))
+	}
+	if len(tools) != len(bindings) {
+		return nil, fmt.Errorf("bindings without schema: got %d schemas for %d bindings", len(tools), len(bindings))
+	}
+	return tools, nil
+}
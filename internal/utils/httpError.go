@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// * non-2xx response of POST / POSTStream, body is cut to the first 4KB
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration // * zero when the response has no Retry-After
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Body:       strings.TrimSpace(string(data)),
+	}
+}
+
+// * seconds or an http date, ex. "120" or "Wed, 21 Oct 2026 07:28:00 GMT"
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxStreamLine = 4 * 1024 * 1024
+)
+
+// * read text/event-stream response, fn receive event name (may be empty) and joined data lines
+func POSTStream(ctx context.Context, client *http.Client, api string, header map[string]string, body map[string]any, fn func(event string, data []byte) error) (int, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", api, bytes.NewReader(requestBody))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	if client == nil {
+		client = NewHTTPClient()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send: %w", err)
+	}
+	defer resp.Body.Close()
+
+	statusCode := resp.StatusCode
+
+	if statusCode < 200 || statusCode >= 300 {
+		return statusCode, newHTTPError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+
+	var event string
+	var data [][]byte
+	dispatch := func() error {
+		defer func() {
+			event = ""
+			data = data[:0]
+		}()
+		if len(data) == 0 {
+			return nil
+		}
+		return fn(event, bytes.Join(data, []byte("\n")))
+	}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		switch {
+		case len(line) == 0:
+			if err := dispatch(); err != nil {
+				return statusCode, err
+			}
+		case line[0] == ':':
+			// * comment / keep-alive
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = strings.TrimSpace(string(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			value := bytes.TrimPrefix(line[len("data:"):], []byte(" "))
+			if string(value) == "[DONE]" {
+				return statusCode, nil
+			}
+			data = append(data, append([]byte(nil), value...))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return statusCode, fmt.Errorf("failed to read: %w", err)
+	}
+
+	// * flush last event when stream ends without a trailing blank line
+	if err := dispatch(); err != nil {
+		return statusCode, err
+	}
+	return statusCode, nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	transportMu sync.RWMutex
+	transport   http.RoundTripper
+)
+
+// * replaces the transport behind every client from NewHTTPClient, including ones already made;
+// * nil restores http.DefaultTransport, ex. a replay.Recorder or replay.Player in tests
+func SetTransport(rt http.RoundTripper) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	transport = rt
+}
+
+// * client used when no client is given to GET, POST, POSTStream and the providers
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: sharedTransport{}}
+}
+
+type sharedTransport struct{}
+
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transportMu.RLock()
+	rt := transport
+	transportMu.RUnlock()
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return rt.RoundTrip(req)
+}
@@ -17,7 +17,7 @@ func GET[T any](ctx context.Context, client *http.Client, api string, header map
 	var result T
 
 	if client == nil {
-		client = &http.Client{}
+		client = NewHTTPClient()
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", api, nil)
@@ -36,6 +36,10 @@ func GET[T any](ctx context.Context, client *http.Client, api string, header map
 
 	statusCode := resp.StatusCode
 
+	if statusCode < 200 || statusCode >= 300 {
+		return result, statusCode, newHTTPError(resp)
+	}
+
 	if s, ok := any(&result).(*string); ok {
 		b, err := io.ReadAll(resp.Body)
 		if err != nil {
@@ -98,7 +102,7 @@ func POST[T any](ctx context.Context, client *http.Client, api string, header ma
 	}
 
 	if client == nil {
-		client = &http.Client{}
+		client = NewHTTPClient()
 	}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -109,7 +113,7 @@ func POST[T any](ctx context.Context, client *http.Client, api string, header ma
 	statusCode := resp.StatusCode
 
 	if statusCode < 200 || statusCode >= 300 {
-		return result, statusCode, nil
+		return result, statusCode, newHTTPError(resp)
 	}
 
 	if s, ok := any(&result).(*string); ok {
@@ -148,8 +152,18 @@ func GetConfigDir(path ...string) (*ConfigDirData, error) {
 		return nil, fmt.Errorf("os.Getwd: %w", err)
 	}
 
-	homeDir = strings.TrimSpace(filepath.Join(append([]string{homeDir, ".config", projectName}, path...)...))
-	workDir = strings.TrimSpace(filepath.Join(append([]string{workDir, ".config", projectName}, path...)...))
+	return NewConfigDir(ProjectDir(homeDir), ProjectDir(workDir), path...)
+}
+
+// * {base}/.config/agenvoy
+func ProjectDir(base string) string {
+	return filepath.Join(base, ".config", projectName)
+}
+
+// * home and work are the root config dirs, ex. ~/.config/agenvoy and {workDir}/.config/agenvoy
+func NewConfigDir(home, work string, path ...string) (*ConfigDirData, error) {
+	homeDir := strings.TrimSpace(filepath.Join(append([]string{home}, path...)...))
+	workDir := strings.TrimSpace(filepath.Join(append([]string{work}, path...)...))
 
 	config := &ConfigDirData{
 		Home: homeDir,
@@ -160,7 +174,7 @@ func GetConfigDir(path ...string) (*ConfigDirData, error) {
 		},
 	}
 
-	err = os.MkdirAll(config.Home, 0755)
+	err := os.MkdirAll(config.Home, 0755)
 	if err != nil {
 		return nil, fmt.Errorf("os.MkdirAll: %w", err)
 	}
@@ -172,3 +186,7 @@ func GetConfigDir(path ...string) (*ConfigDirData, error) {
 
 	return config, nil
 }
+
+func (c *ConfigDirData) Sub(path ...string) (*ConfigDirData, error) {
+	return NewConfigDir(c.Home, c.Work, path...)
+}